@@ -0,0 +1,104 @@
+package client
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// access levels a grant gives on objects
+const (
+	AccessRead      = "read"
+	AccessReadWrite = "read-write"
+)
+
+// Grant gives a bot, or every bot of an owner, access to the objects under Prefix of a bot or owner scope
+type Grant struct {
+	Grantee string `json:"grantee"`
+	Prefix  string `json:"prefix"`
+	Access  string `json:"access"`
+}
+
+// BotGrantee names a single bot as a grantee
+func BotGrantee(botID string) string {
+	return "bot:" + strings.ToLower(botID)
+}
+
+// OwnerGrantee names every bot of an owner as a grantee
+func OwnerGrantee(owner string) string {
+	return "owner:" + strings.ToLower(owner)
+}
+
+// ACL manages who else can read or write the objects of the client's bot and owner scopes.
+// Grantees use Shared to reach the objects; scanner scopes cannot be shared.
+type ACL interface {
+	// Grant gives grantee access to the objects under prefix, replacing any grant it has on that prefix
+	Grant(scope Scope, grantee, prefix, access string) error
+	Revoke(scope Scope, grantee, prefix string) error
+	Grants(scope Scope) ([]Grant, error)
+}
+
+// ACLClient is implemented by clients that can manage grants
+type ACLClient interface {
+	ACL() ACL
+}
+
+var _ ACLClient = (*client)(nil)
+
+type aclClient struct {
+	c *client
+}
+
+// ACL returns the grant management API, using the same authentication as the client
+func (c *client) ACL() ACL {
+	return &aclClient{c: c}
+}
+
+func (a *aclClient) url(scope Scope) string {
+	return fmt.Sprintf("%s/acl/%s", a.c.apiHost, scope)
+}
+
+func (a *aclClient) Grant(scope Scope, grantee, prefix, access string) error {
+	body, err := json.Marshal(&Grant{Grantee: grantee, Prefix: prefix, Access: access})
+	if err != nil {
+		return err
+	}
+	header := http.Header{}
+	header.Set("Content-Type", "application/json")
+	resp, err := a.c.do("PUT", a.url(scope), body, header)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	return checkStatus(resp)
+}
+
+func (a *aclClient) Revoke(scope Scope, grantee, prefix string) error {
+	u := fmt.Sprintf("%s?grantee=%s&prefix=%s", a.url(scope), url.QueryEscape(grantee), url.QueryEscape(prefix))
+	resp, err := a.c.do("DELETE", u, nil, nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	return checkStatus(resp)
+}
+
+func (a *aclClient) Grants(scope Scope) ([]Grant, error) {
+	resp, err := a.c.do("GET", a.url(scope), nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if err := checkStatus(resp); err != nil {
+		return nil, err
+	}
+	var res struct {
+		Grants []Grant `json:"grants"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&res); err != nil {
+		return nil, err
+	}
+	return res.Grants, nil
+}
@@ -0,0 +1,63 @@
+package client
+
+import (
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"testing"
+)
+
+func TestACLRequests(t *testing.T) {
+	c, s := newTestClient(t)
+	var grants []Grant
+	s.handle("/acl/bot", func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case "PUT":
+			var g Grant
+			if r.Header.Get("Content-Type") != "application/json" {
+				t.Errorf("unexpected content type %q", r.Header.Get("Content-Type"))
+			}
+			if err := json.NewDecoder(r.Body).Decode(&g); err != nil {
+				t.Error(err)
+			}
+			grants = append(grants, g)
+		case "DELETE":
+			q := r.URL.Query()
+			for i, g := range grants {
+				if g.Grantee == q.Get("grantee") && g.Prefix == q.Get("prefix") {
+					grants = append(grants[:i], grants[i+1:]...)
+					return
+				}
+			}
+			w.WriteHeader(http.StatusNotFound)
+		case "GET":
+			json.NewEncoder(w).Encode(map[string]interface{}{"grants": grants})
+		}
+	})
+
+	acl := c.ACL()
+	if err := acl.Grant(ScopeBot, BotGrantee("0xABC"), "labels/a b&c", AccessRead); err != nil {
+		t.Fatal(err)
+	}
+	if err := acl.Grant(ScopeBot, OwnerGrantee("0xDEF"), "", AccessReadWrite); err != nil {
+		t.Fatal(err)
+	}
+	got, err := acl.Grants(ScopeBot)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Grant{
+		{Grantee: "bot:0xabc", Prefix: "labels/a b&c", Access: AccessRead},
+		{Grantee: "owner:0xdef", Prefix: "", Access: AccessReadWrite},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %+v, want %+v", got, want)
+	}
+	// the grantee and prefix are escaped in the query
+	if err := acl.Revoke(ScopeBot, "bot:0xabc", "labels/a b&c"); err != nil {
+		t.Fatal(err)
+	}
+	if got, err := acl.Grants(ScopeBot); err != nil || !reflect.DeepEqual(got, want[1:]) {
+		t.Fatalf("got %+v, %v", got, err)
+	}
+}
@@ -0,0 +1,254 @@
+package client
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+)
+
+// batchKey addresses the batch endpoint
+const batchKey = "_batch"
+
+// maxBatchOperations is the server's limit per request; larger batches are split
+const maxBatchOperations = 100
+
+// BatchClient reads and writes several objects of a scope per request
+type BatchClient interface {
+	BatchGet(scope Scope, objIDs []string) (map[string][]byte, error)
+	BatchPut(scope Scope, objects map[string][]byte) error
+	BatchDel(scope Scope, objIDs []string) error
+}
+
+var _ BatchClient = (*client)(nil)
+
+// BatchError reports the keys that failed in a batch call
+type BatchError struct {
+	Errors map[string]error
+}
+
+func (e *BatchError) Error() string {
+	var keys []string
+	for k := range e.Errors {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	var msgs []string
+	for _, k := range keys {
+		msgs = append(msgs, fmt.Sprintf("%s: %v", k, e.Errors[k]))
+	}
+	return fmt.Sprintf("batch failed for %d keys (%s)", len(keys), strings.Join(msgs, ", "))
+}
+
+type batchOperation struct {
+	Op      string            `json:"op"`
+	Key     string            `json:"key"`
+	Value   string            `json:"value,omitempty"`
+	Headers map[string]string `json:"headers,omitempty"`
+}
+
+type batchResult struct {
+	Key     string            `json:"key"`
+	Status  int               `json:"status"`
+	Value   string            `json:"value,omitempty"`
+	Headers map[string]string `json:"headers,omitempty"`
+	Error   string            `json:"error,omitempty"`
+}
+
+func (r *batchResult) err() error {
+	if r.Status == http.StatusNotFound {
+		return ErrNotFound
+	}
+	if r.Status >= 400 {
+		return &StatusError{StatusCode: r.Status}
+	}
+	return nil
+}
+
+// header returns a result header regardless of its case
+func (r *batchResult) header(name string) string {
+	for k, v := range r.Headers {
+		if strings.EqualFold(k, name) {
+			return v
+		}
+	}
+	return ""
+}
+
+// batch sends operations in requests of at most maxBatchOperations
+func (c *client) batch(scope Scope, ops []batchOperation) ([]*batchResult, error) {
+	var results []*batchResult
+	for start := 0; start < len(ops); start += maxBatchOperations {
+		end := start + maxBatchOperations
+		if end > len(ops) {
+			end = len(ops)
+		}
+		body, err := json.Marshal(map[string]interface{}{"operations": ops[start:end]})
+		if err != nil {
+			return nil, err
+		}
+		header := http.Header{}
+		header.Set("Content-Type", "application/json")
+		resp, err := c.do("POST", c.objURL(scope, batchKey), body, header)
+		if err != nil {
+			return nil, err
+		}
+		err = checkStatus(resp)
+		if err == nil {
+			var res struct {
+				Results []*batchResult `json:"results"`
+			}
+			var b []byte
+			b, err = io.ReadAll(resp.Body)
+			if err == nil {
+				err = json.Unmarshal(b, &res)
+			}
+			results = append(results, res.Results...)
+		}
+		resp.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+	}
+	return results, nil
+}
+
+// BatchGet fetches several objects in as few requests as possible.  Missing objects are left out of the result.
+// Objects that do not fit in the server's batch response are fetched on their own.
+func (c *client) BatchGet(scope Scope, objIDs []string) (map[string][]byte, error) {
+	var ops []batchOperation
+	for _, objID := range objIDs {
+		ops = append(ops, batchOperation{Op: "get", Key: objID})
+	}
+	results, err := c.batch(scope, ops)
+	if err != nil {
+		return nil, err
+	}
+
+	objects := make(map[string][]byte)
+	errs := make(map[string]error)
+	for _, r := range results {
+		if r.Status == http.StatusRequestEntityTooLarge {
+			b, err := c.Get(scope, r.Key)
+			if errors.Is(err, ErrNotFound) {
+				continue
+			} else if err != nil {
+				errs[r.Key] = err
+				continue
+			}
+			objects[r.Key] = b
+			continue
+		}
+		if err := r.err(); errors.Is(err, ErrNotFound) {
+			continue
+		} else if err != nil {
+			errs[r.Key] = err
+			continue
+		}
+		b, err := c.decodeResult(scope, r)
+		if err != nil {
+			errs[r.Key] = err
+			continue
+		}
+		objects[r.Key] = b
+	}
+	if len(errs) > 0 {
+		return objects, &BatchError{Errors: errs}
+	}
+	return objects, nil
+}
+
+func (c *client) decodeResult(scope Scope, r *batchResult) ([]byte, error) {
+	b, err := base64.StdEncoding.DecodeString(r.Value)
+	if err != nil {
+		return nil, err
+	}
+	if sum := r.header("X-Checksum-SHA256"); sum != "" && sum != checksumSHA256(b) {
+		return nil, ErrChecksumMismatch
+	}
+	encoding := r.header(encodingHeader)
+	if isManifest(b) {
+		b, encoding, err = c.reassemble(scope, r.Key, b)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return c.decode(scope, r.Key, b, encoding)
+}
+
+// BatchPut stores several objects in as few requests as possible.
+// With chunking enabled each object is written with Put, so large payloads are still chunked.
+func (c *client) BatchPut(scope Scope, objects map[string][]byte) error {
+	errs := make(map[string]error)
+	var ops []batchOperation
+	for objID, payload := range objects {
+		if c.chunkSize > 0 {
+			if err := c.Put(scope, objID, payload); err != nil {
+				errs[objID] = err
+			}
+			continue
+		}
+		b, encoding, err := c.encode(scope, objID, payload)
+		if err != nil {
+			errs[objID] = err
+			continue
+		}
+		headers := map[string]string{"X-Checksum-SHA256": checksumSHA256(b)}
+		if encoding != CodecNone {
+			headers[encodingHeader] = encoding
+		}
+		ops = append(ops, batchOperation{Op: "put", Key: objID, Value: base64.StdEncoding.EncodeToString(b), Headers: headers})
+	}
+
+	if len(ops) > 0 {
+		results, err := c.batch(scope, ops)
+		if err != nil {
+			return err
+		}
+		for _, r := range results {
+			if err := r.err(); err != nil {
+				errs[r.Key] = err
+			}
+		}
+	}
+	if len(errs) > 0 {
+		return &BatchError{Errors: errs}
+	}
+	return nil
+}
+
+// BatchDel deletes several objects in as few requests as possible.
+// With chunking enabled each object is deleted with Del, so parts are cleaned up.
+func (c *client) BatchDel(scope Scope, objIDs []string) error {
+	errs := make(map[string]error)
+	var ops []batchOperation
+	for _, objID := range objIDs {
+		if c.chunkSize > 0 {
+			if err := c.Del(scope, objID); err != nil {
+				errs[objID] = err
+			}
+			continue
+		}
+		ops = append(ops, batchOperation{Op: "delete", Key: objID})
+	}
+
+	if len(ops) > 0 {
+		results, err := c.batch(scope, ops)
+		if err != nil {
+			return err
+		}
+		for _, r := range results {
+			if err := r.err(); err != nil && !errors.Is(err, ErrNotFound) {
+				errs[r.Key] = err
+			}
+		}
+	}
+	if len(errs) > 0 {
+		return &BatchError{Errors: errs}
+	}
+	return nil
+}
@@ -0,0 +1,92 @@
+package client
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"testing"
+)
+
+func TestBatchGetFetchesOversizedResultsAlone(t *testing.T) {
+	c, s := newTestClient(t)
+	if err := c.Put(ScopeBot, "big.json", []byte(`"big"`)); err != nil {
+		t.Fatal(err)
+	}
+	s.handle("/database/bot/_batch", func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Operations []batchOperation `json:"operations"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Error(err)
+		}
+		if len(req.Operations) != 2 || req.Operations[0].Op != "get" {
+			t.Errorf("unexpected operations %+v", req.Operations)
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"results": []batchResult{
+			{Key: "small.json", Status: http.StatusOK, Value: "InNtYWxsIg=="},
+			{Key: "big.json", Status: http.StatusRequestEntityTooLarge, Error: "the result does not fit in the batch response"},
+		}})
+	})
+
+	objects, err := c.BatchGet(ScopeBot, []string{"small.json", "big.json"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(objects["small.json"]) != `"small"` || string(objects["big.json"]) != `"big"` {
+		t.Fatalf("got %q", objects)
+	}
+}
+
+func TestBatchPutAndDelRequests(t *testing.T) {
+	c, s := newTestClient(t)
+	var ops []batchOperation
+	s.handle("/database/bot/_batch", func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Operations []batchOperation `json:"operations"`
+		}
+		if r.Method != "POST" || r.Header.Get("Content-Type") != "application/json" {
+			t.Errorf("unexpected request %s %s", r.Method, r.URL)
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Error(err)
+		}
+		ops = append(ops, req.Operations...)
+		var results []batchResult
+		for _, op := range req.Operations {
+			status := http.StatusOK
+			if op.Key == "denied.json" {
+				status = http.StatusForbidden
+			}
+			results = append(results, batchResult{Key: op.Key, Status: status})
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"results": results})
+	})
+
+	if err := c.BatchPut(ScopeBot, map[string][]byte{"a.json": []byte(`"a"`)}); err != nil {
+		t.Fatal(err)
+	}
+	err := c.BatchDel(ScopeBot, []string{"a.json", "denied.json"})
+	var batchErr *BatchError
+	if !errors.As(err, &batchErr) || len(batchErr.Errors) != 1 {
+		t.Fatalf("got %v, want an error for denied.json", err)
+	}
+	var statusErr *StatusError
+	if !errors.As(batchErr.Errors["denied.json"], &statusErr) || statusErr.StatusCode != http.StatusForbidden {
+		t.Fatalf("got %v for denied.json", batchErr.Errors["denied.json"])
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(ops) != 3 {
+		t.Fatalf("got %+v", ops)
+	}
+	put := ops[0]
+	if put.Op != "put" || put.Key != "a.json" || put.Value != base64.StdEncoding.EncodeToString([]byte(`"a"`)) ||
+		put.Headers["X-Checksum-SHA256"] != checksumSHA256([]byte(`"a"`)) {
+		t.Fatalf("got put %+v", put)
+	}
+	if ops[1].Op != "delete" || ops[1].Key != "a.json" || ops[2].Key != "denied.json" {
+		t.Fatalf("got deletes %+v", ops[1:])
+	}
+}
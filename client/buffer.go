@@ -0,0 +1,167 @@
+package client
+
+import (
+	"encoding/json"
+	"sync"
+	"time"
+)
+
+// BufferOptions configures when a BufferedValue is written
+type BufferOptions struct {
+	// FlushInterval writes dirty state periodically (0 disables)
+	FlushInterval time.Duration
+	// MaxPending writes dirty state once this many updates have accumulated (0 disables)
+	MaxPending int
+	// MaxRetries is the number of extra attempts for a failed write
+	MaxRetries int
+	// RetryBackoff is the initial delay between attempts, doubled after each one (default 1s)
+	RetryBackoff time.Duration
+	// OnError is called when a background flush fails after all retries
+	OnError func(error)
+}
+
+// BufferedValue holds a value in memory and writes it behind through Put, coalescing updates.
+// Failed writes keep the value dirty, so nothing is lost until the next successful flush.
+type BufferedValue[T any] struct {
+	c     Client
+	scope Scope
+	objID string
+	opts  BufferOptions
+
+	mu      sync.Mutex
+	value   T
+	dirty   bool
+	pending int
+
+	flushMu   sync.Mutex
+	kick      chan struct{}
+	stop      chan struct{}
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+// NewBufferedValue starts buffering initial, which is considered clean
+func NewBufferedValue[T any](c Client, scope Scope, objID string, initial T, opts BufferOptions) *BufferedValue[T] {
+	if opts.RetryBackoff == 0 {
+		opts.RetryBackoff = time.Second
+	}
+	b := &BufferedValue[T]{
+		c:     c,
+		scope: scope,
+		objID: objID,
+		opts:  opts,
+		value: initial,
+		kick:  make(chan struct{}, 1),
+		stop:  make(chan struct{}),
+		done:  make(chan struct{}),
+	}
+	go b.run()
+	return b
+}
+
+// LoadBufferedValue starts buffering the stored value, or def if there is none
+func LoadBufferedValue[T any](c Client, scope Scope, objID string, def T, opts BufferOptions) (*BufferedValue[T], error) {
+	v, err := GetJSONOrDefault(c, scope, objID, def)
+	if err != nil {
+		return nil, err
+	}
+	return NewBufferedValue(c, scope, objID, v, opts), nil
+}
+
+// View calls fn with the current value; fn must not retain or modify it
+func (b *BufferedValue[T]) View(fn func(T)) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	fn(b.value)
+}
+
+// Update modifies the value and marks it dirty
+func (b *BufferedValue[T]) Update(fn func(*T)) {
+	b.mu.Lock()
+	fn(&b.value)
+	b.dirty = true
+	b.pending++
+	full := b.opts.MaxPending > 0 && b.pending >= b.opts.MaxPending
+	b.mu.Unlock()
+
+	if full {
+		select {
+		case b.kick <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// Dirty reports whether there are updates that have not been written
+func (b *BufferedValue[T]) Dirty() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.dirty
+}
+
+// Flush writes the value now if it is dirty
+func (b *BufferedValue[T]) Flush() error {
+	b.flushMu.Lock()
+	defer b.flushMu.Unlock()
+
+	b.mu.Lock()
+	if !b.dirty {
+		b.mu.Unlock()
+		return nil
+	}
+	payload, err := json.Marshal(b.value)
+	if err != nil {
+		b.mu.Unlock()
+		return err
+	}
+	b.dirty = false
+	b.pending = 0
+	b.mu.Unlock()
+
+	backoff := b.opts.RetryBackoff
+	for attempt := 0; ; attempt++ {
+		err = b.c.Put(b.scope, b.objID, payload)
+		if err == nil || attempt >= b.opts.MaxRetries {
+			break
+		}
+		time.Sleep(backoff)
+		backoff *= 2
+	}
+	if err != nil {
+		b.mu.Lock()
+		b.dirty = true
+		b.mu.Unlock()
+	}
+	return err
+}
+
+// Close stops background flushing and writes any remaining updates
+func (b *BufferedValue[T]) Close() error {
+	b.closeOnce.Do(func() {
+		close(b.stop)
+	})
+	<-b.done
+	return b.Flush()
+}
+
+func (b *BufferedValue[T]) run() {
+	defer close(b.done)
+
+	var tick <-chan time.Time
+	if b.opts.FlushInterval > 0 {
+		t := time.NewTicker(b.opts.FlushInterval)
+		defer t.Stop()
+		tick = t.C
+	}
+	for {
+		select {
+		case <-b.stop:
+			return
+		case <-tick:
+		case <-b.kick:
+		}
+		if err := b.Flush(); err != nil && b.opts.OnError != nil {
+			b.opts.OnError(err)
+		}
+	}
+}
@@ -0,0 +1,105 @@
+package client
+
+import (
+	"net/http"
+	"testing"
+	"time"
+)
+
+type bufferedCounter struct {
+	Count int `json:"count"`
+}
+
+// eventually polls cond until it holds or a second has passed
+func eventually(t *testing.T, cond func() bool) {
+	t.Helper()
+	for deadline := time.Now().Add(time.Second); time.Now().Before(deadline); time.Sleep(5 * time.Millisecond) {
+		if cond() {
+			return
+		}
+	}
+	t.Fatal("condition not met in time")
+}
+
+func TestBufferedValueCoalesces(t *testing.T) {
+	c, s := newTestClient(t)
+	b := NewBufferedValue(c, ScopeBot, "state.json", bufferedCounter{}, BufferOptions{})
+	for i := 0; i < 10; i++ {
+		b.Update(func(v *bufferedCounter) { v.Count++ })
+	}
+	if s.count("PUT") != 0 || !b.Dirty() {
+		t.Fatal("updates were written before a flush")
+	}
+	if err := b.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if n := s.count("PUT"); n != 1 {
+		t.Fatalf("%d writes, want 1", n)
+	}
+
+	loaded, err := LoadBufferedValue(c, ScopeBot, "state.json", bufferedCounter{}, BufferOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer loaded.Close()
+	loaded.View(func(v bufferedCounter) {
+		if v.Count != 10 {
+			t.Fatalf("loaded count %d, want 10", v.Count)
+		}
+	})
+	if loaded.Dirty() {
+		t.Fatal("a loaded value is dirty")
+	}
+}
+
+func TestBufferedValueMaxPending(t *testing.T) {
+	c, s := newTestClient(t)
+	b := NewBufferedValue(c, ScopeBot, "state.json", bufferedCounter{}, BufferOptions{MaxPending: 3})
+	defer b.Close()
+	for i := 0; i < 3; i++ {
+		b.Update(func(v *bufferedCounter) { v.Count++ })
+	}
+	eventually(t, func() bool { return s.count("PUT") == 1 && !b.Dirty() })
+}
+
+func TestBufferedValueFlushInterval(t *testing.T) {
+	c, s := newTestClient(t)
+	b := NewBufferedValue(c, ScopeBot, "state.json", bufferedCounter{}, BufferOptions{FlushInterval: 10 * time.Millisecond})
+	defer b.Close()
+	b.Update(func(v *bufferedCounter) { v.Count++ })
+	eventually(t, func() bool { return s.count("PUT") == 1 && !b.Dirty() })
+}
+
+func TestBufferedValueRetries(t *testing.T) {
+	c, s := newTestClient(t)
+	failures := 0
+	s.mu.Lock()
+	s.status = func(r *http.Request) int {
+		if r.Method == "PUT" && failures < 3 {
+			failures++
+			return http.StatusInternalServerError
+		}
+		return 0
+	}
+	s.mu.Unlock()
+
+	b := NewBufferedValue(c, ScopeBot, "state.json", bufferedCounter{}, BufferOptions{MaxRetries: 1, RetryBackoff: time.Millisecond})
+	b.Update(func(v *bufferedCounter) { v.Count++ })
+	// two attempts both fail, so the value stays dirty
+	if err := b.Flush(); err == nil {
+		t.Fatal("flush succeeded against a failing server")
+	}
+	if !b.Dirty() {
+		t.Fatal("a failed write cleared the dirty flag")
+	}
+	// the third failure is retried
+	if err := b.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if b.Dirty() {
+		t.Fatal("value is dirty after a successful flush")
+	}
+	if n := s.count("PUT"); n != 4 {
+		t.Fatalf("%d write attempts, want 4", n)
+	}
+}
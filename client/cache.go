@@ -0,0 +1,360 @@
+package client
+
+import (
+	"container/list"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// ErrNotModified is returned by conditional gets when the object still has the given ETag
+var ErrNotModified = errors.New("not modified")
+
+// ConditionalGetter is implemented by clients that can revalidate a cached copy of an object.
+// An empty etag fetches unconditionally.
+type ConditionalGetter interface {
+	GetIfNoneMatch(scope Scope, objID string, etag string) ([]byte, string, error)
+}
+
+// CacheEntry is a decoded object and the ETag it was fetched with
+type CacheEntry struct {
+	ETag    string `json:"etag"`
+	Payload []byte `json:"payload"`
+}
+
+// CacheStore holds cached objects for NewCachingClient
+type CacheStore interface {
+	Load(key string) (*CacheEntry, bool)
+	Store(key string, e *CacheEntry) error
+	Delete(key string) error
+}
+
+// DefaultMemoryCacheSize is the payload budget of NewMemoryCache
+const DefaultMemoryCacheSize = 64 << 20
+
+type memoryCache struct {
+	mu      sync.Mutex
+	maxSize int
+	size    int
+	// lru holds *memoryEntry, most recently used first
+	lru     *list.List
+	entries map[string]*list.Element
+}
+
+type memoryEntry struct {
+	key   string
+	entry CacheEntry
+}
+
+// NewMemoryCache returns a CacheStore that lives as long as the process, holding up to DefaultMemoryCacheSize bytes
+func NewMemoryCache() CacheStore {
+	return NewMemoryCacheWithSize(DefaultMemoryCacheSize)
+}
+
+// NewMemoryCacheWithSize returns a memory CacheStore that evicts the least recently used objects once their payloads
+// exceed maxSize bytes.  Objects larger than maxSize are not cached.
+func NewMemoryCacheWithSize(maxSize int) CacheStore {
+	return &memoryCache{maxSize: maxSize, lru: list.New(), entries: make(map[string]*list.Element)}
+}
+
+func copyEntry(e *CacheEntry) *CacheEntry {
+	return &CacheEntry{ETag: e.ETag, Payload: append([]byte(nil), e.Payload...)}
+}
+
+func (m *memoryCache) Load(key string) (*CacheEntry, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	el, ok := m.entries[key]
+	if !ok {
+		return nil, false
+	}
+	m.lru.MoveToFront(el)
+	return copyEntry(&el.Value.(*memoryEntry).entry), true
+}
+
+func (m *memoryCache) Store(key string, e *CacheEntry) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.remove(key)
+	if len(e.Payload) > m.maxSize {
+		return nil
+	}
+	m.entries[key] = m.lru.PushFront(&memoryEntry{key: key, entry: *copyEntry(e)})
+	m.size += len(e.Payload)
+	for m.size > m.maxSize {
+		m.remove(m.lru.Back().Value.(*memoryEntry).key)
+	}
+	return nil
+}
+
+func (m *memoryCache) Delete(key string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.remove(key)
+	return nil
+}
+
+// DeletePrefix drops every entry whose key starts with prefix
+func (m *memoryCache) DeletePrefix(prefix string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for key := range m.entries {
+		if strings.HasPrefix(key, prefix) {
+			m.remove(key)
+		}
+	}
+	return nil
+}
+
+func (m *memoryCache) remove(key string) {
+	el, ok := m.entries[key]
+	if !ok {
+		return
+	}
+	m.size -= len(el.Value.(*memoryEntry).entry.Payload)
+	m.lru.Remove(el)
+	delete(m.entries, key)
+}
+
+type diskCache struct {
+	dir string
+}
+
+// NewDiskCache returns a CacheStore that keeps one file per object under dir, so it survives restarts.
+// Payloads are stored decoded (and decrypted, if the client encrypts).
+func NewDiskCache(dir string) (CacheStore, error) {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, err
+	}
+	return &diskCache{dir: dir}, nil
+}
+
+func (d *diskCache) path(key string) string {
+	h := sha256.Sum256([]byte(key))
+	return filepath.Join(d.dir, hex.EncodeToString(h[:]))
+}
+
+func (d *diskCache) Load(key string) (*CacheEntry, bool) {
+	b, err := os.ReadFile(d.path(key))
+	if err != nil {
+		return nil, false
+	}
+	var e CacheEntry
+	if err := json.Unmarshal(b, &e); err != nil {
+		return nil, false
+	}
+	return &e, true
+}
+
+func (d *diskCache) Store(key string, e *CacheEntry) error {
+	b, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	// write then rename, so a crash never leaves a partial entry
+	f, err := os.CreateTemp(d.dir, "tmp-")
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(b); err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return err
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(f.Name())
+		return err
+	}
+	return os.Rename(f.Name(), d.path(key))
+}
+
+func (d *diskCache) Delete(key string) error {
+	err := os.Remove(d.path(key))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	return err
+}
+
+type cachingClient struct {
+	Client
+	cache CacheStore
+}
+
+var _ InfoClient = (*cachingClient)(nil)
+var _ VersionedClient = (*cachingClient)(nil)
+var _ BatchClient = (*cachingClient)(nil)
+var _ CopyClient = (*cachingClient)(nil)
+var _ PrefixClient = (*cachingClient)(nil)
+
+// NewCachingClient wraps c with a read-through cache.  Cached objects are revalidated with their ETag on every Get,
+// so a read only transfers the object when it has changed.  If c is not a ConditionalGetter, every Get is a full fetch.
+// The caching client also implements InfoClient, VersionedClient, BatchClient, CopyClient and PrefixClient, returning
+// ErrNotSupported where c does not; other APIs are used through c itself.  Writes through the caching client drop
+// the entries they touch; DelPrefix drops matching entries from stores that can enumerate them (the memory cache),
+// and the revalidation catches the rest.
+func NewCachingClient(c Client, cache CacheStore) Client {
+	return &cachingClient{Client: c, cache: cache}
+}
+
+func cacheKey(scope Scope, objID string) string {
+	return string(scope) + "/" + objID
+}
+
+// prefixDeleter is implemented by cache stores that can drop entries by key prefix
+type prefixDeleter interface {
+	DeletePrefix(prefix string) error
+}
+
+func (c *cachingClient) Get(scope Scope, objID string) ([]byte, error) {
+	cg, ok := c.Client.(ConditionalGetter)
+	if !ok {
+		return c.Client.Get(scope, objID)
+	}
+
+	key := cacheKey(scope, objID)
+	var etag string
+	cached, ok := c.cache.Load(key)
+	if ok {
+		etag = cached.ETag
+	}
+	b, newETag, err := cg.GetIfNoneMatch(scope, objID, etag)
+	if errors.Is(err, ErrNotModified) && ok {
+		return cached.Payload, nil
+	}
+	if errors.Is(err, ErrNotFound) {
+		_ = c.cache.Delete(key)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if newETag != "" {
+		_ = c.cache.Store(key, &CacheEntry{ETag: newETag, Payload: b})
+	}
+	return b, nil
+}
+
+func (c *cachingClient) Put(scope Scope, objID string, payload []byte) error {
+	_ = c.cache.Delete(cacheKey(scope, objID))
+	return c.Client.Put(scope, objID, payload)
+}
+
+func (c *cachingClient) Del(scope Scope, objID string) error {
+	_ = c.cache.Delete(cacheKey(scope, objID))
+	return c.Client.Del(scope, objID)
+}
+
+func (c *cachingClient) PutWithOptions(scope Scope, objID string, payload []byte, opts *PutOptions) error {
+	ic, ok := c.Client.(InfoClient)
+	if !ok {
+		return ErrNotSupported
+	}
+	_ = c.cache.Delete(cacheKey(scope, objID))
+	return ic.PutWithOptions(scope, objID, payload, opts)
+}
+
+func (c *cachingClient) GetWithInfo(scope Scope, objID string) ([]byte, *ObjectInfo, error) {
+	ic, ok := c.Client.(InfoClient)
+	if !ok {
+		return nil, nil, ErrNotSupported
+	}
+	return ic.GetWithInfo(scope, objID)
+}
+
+func (c *cachingClient) Stat(scope Scope, objID string) (*ObjectInfo, error) {
+	ic, ok := c.Client.(InfoClient)
+	if !ok {
+		return nil, ErrNotSupported
+	}
+	return ic.Stat(scope, objID)
+}
+
+func (c *cachingClient) Exists(scope Scope, objID string) (bool, error) {
+	ic, ok := c.Client.(InfoClient)
+	if !ok {
+		return false, ErrNotSupported
+	}
+	return ic.Exists(scope, objID)
+}
+
+func (c *cachingClient) GetVersioned(scope Scope, objID string) ([]byte, string, error) {
+	vc, ok := c.Client.(VersionedClient)
+	if !ok {
+		return nil, "", ErrNotSupported
+	}
+	return vc.GetVersioned(scope, objID)
+}
+
+func (c *cachingClient) PutIfVersion(scope Scope, objID string, payload []byte, version string) error {
+	vc, ok := c.Client.(VersionedClient)
+	if !ok {
+		return ErrNotSupported
+	}
+	_ = c.cache.Delete(cacheKey(scope, objID))
+	return vc.PutIfVersion(scope, objID, payload, version)
+}
+
+func (c *cachingClient) BatchGet(scope Scope, objIDs []string) (map[string][]byte, error) {
+	bc, ok := c.Client.(BatchClient)
+	if !ok {
+		return nil, ErrNotSupported
+	}
+	return bc.BatchGet(scope, objIDs)
+}
+
+func (c *cachingClient) BatchPut(scope Scope, objects map[string][]byte) error {
+	bc, ok := c.Client.(BatchClient)
+	if !ok {
+		return ErrNotSupported
+	}
+	for objID := range objects {
+		_ = c.cache.Delete(cacheKey(scope, objID))
+	}
+	return bc.BatchPut(scope, objects)
+}
+
+func (c *cachingClient) BatchDel(scope Scope, objIDs []string) error {
+	bc, ok := c.Client.(BatchClient)
+	if !ok {
+		return ErrNotSupported
+	}
+	for _, objID := range objIDs {
+		_ = c.cache.Delete(cacheKey(scope, objID))
+	}
+	return bc.BatchDel(scope, objIDs)
+}
+
+func (c *cachingClient) Copy(srcScope Scope, srcID string, dstScope Scope, dstID string) error {
+	cc, ok := c.Client.(CopyClient)
+	if !ok {
+		return ErrNotSupported
+	}
+	_ = c.cache.Delete(cacheKey(dstScope, dstID))
+	return cc.Copy(srcScope, srcID, dstScope, dstID)
+}
+
+func (c *cachingClient) Move(srcScope Scope, srcID string, dstScope Scope, dstID string) error {
+	cc, ok := c.Client.(CopyClient)
+	if !ok {
+		return ErrNotSupported
+	}
+	_ = c.cache.Delete(cacheKey(srcScope, srcID))
+	_ = c.cache.Delete(cacheKey(dstScope, dstID))
+	return cc.Move(srcScope, srcID, dstScope, dstID)
+}
+
+func (c *cachingClient) DelPrefix(scope Scope, prefix string) (int, error) {
+	pc, ok := c.Client.(PrefixClient)
+	if !ok {
+		return 0, ErrNotSupported
+	}
+	if pd, ok := c.cache.(prefixDeleter); ok {
+		_ = pd.DeletePrefix(cacheKey(scope, prefix))
+	}
+	return pc.DelPrefix(scope, prefix)
+}
@@ -0,0 +1,148 @@
+package client
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestMemoryCacheCopies(t *testing.T) {
+	m := NewMemoryCache()
+	payload := []byte("abc")
+	if err := m.Store("k", &CacheEntry{ETag: "1", Payload: payload}); err != nil {
+		t.Fatal(err)
+	}
+	payload[0] = 'x'
+	e, ok := m.Load("k")
+	if !ok || string(e.Payload) != "abc" {
+		t.Fatalf("stored entry changed with the caller's slice: %q", e.Payload)
+	}
+	e.Payload[0] = 'y'
+	if e, _ = m.Load("k"); string(e.Payload) != "abc" {
+		t.Fatalf("stored entry changed with a loaded slice: %q", e.Payload)
+	}
+}
+
+func TestMemoryCacheEvicts(t *testing.T) {
+	m := NewMemoryCacheWithSize(10)
+	m.Store("a", &CacheEntry{Payload: []byte("aaaa")})
+	m.Store("b", &CacheEntry{Payload: []byte("bbbb")})
+	m.Load("a")
+	// c pushes out b, the least recently used
+	m.Store("c", &CacheEntry{Payload: []byte("cccc")})
+	if _, ok := m.Load("b"); ok {
+		t.Error("b was not evicted")
+	}
+	for _, key := range []string{"a", "c"} {
+		if _, ok := m.Load(key); !ok {
+			t.Errorf("%s was evicted", key)
+		}
+	}
+	m.Store("big", &CacheEntry{Payload: make([]byte, 11)})
+	if _, ok := m.Load("big"); ok {
+		t.Error("entry larger than the cache was stored")
+	}
+	if mc := m.(*memoryCache); mc.size != 8 || mc.lru.Len() != 2 {
+		t.Errorf("size %d with %d entries, want 8 with 2", mc.size, mc.lru.Len())
+	}
+}
+
+func TestCachingClientInvalidates(t *testing.T) {
+	// chunking makes Copy and Move read and write through the client, which the test server supports
+	inner, _ := newTestClient(t, WithChunking(1024))
+	cache := NewMemoryCache()
+	c := NewCachingClient(inner, cache).(*cachingClient)
+
+	cached := func(objID string) bool {
+		_, ok := cache.Load(cacheKey(ScopeBot, objID))
+		return ok
+	}
+	fill := func(objIDs ...string) {
+		for _, objID := range objIDs {
+			if err := c.Put(ScopeBot, objID, []byte(objID)); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := c.Get(ScopeBot, objID); err != nil {
+				t.Fatal(err)
+			}
+			if !cached(objID) {
+				t.Fatalf("%s was not cached", objID)
+			}
+		}
+	}
+
+	tests := []struct {
+		name  string
+		write func() error
+		gone  []string
+	}{
+		{"Put", func() error { return c.Put(ScopeBot, "a", []byte("new")) }, []string{"a"}},
+		{"PutWithOptions", func() error {
+			return c.PutWithOptions(ScopeBot, "a", []byte("new"), &PutOptions{ContentType: "text/plain"})
+		}, []string{"a"}},
+		{"PutIfVersion", func() error {
+			_, version, err := c.GetVersioned(ScopeBot, "a")
+			if err != nil {
+				return err
+			}
+			return c.PutIfVersion(ScopeBot, "a", []byte("new"), version)
+		}, []string{"a"}},
+		{"Del", func() error { return c.Del(ScopeBot, "a") }, []string{"a"}},
+		{"Copy", func() error { return c.Copy(ScopeBot, "a", ScopeBot, "b") }, []string{"b"}},
+		{"Move", func() error { return c.Move(ScopeBot, "a", ScopeBot, "b") }, []string{"a", "b"}},
+		{"DelPrefix", func() error {
+			_, err := c.DelPrefix(ScopeBot, "dir/")
+			return err
+		}, []string{"dir/a", "dir/b"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fill("a", "b", "dir/a", "dir/b", "other")
+			if err := tt.write(); err != nil {
+				t.Fatal(err)
+			}
+			for _, objID := range tt.gone {
+				if cached(objID) {
+					t.Errorf("%s is still cached", objID)
+				}
+			}
+			if !cached("other") {
+				t.Error("an untouched object was dropped")
+			}
+		})
+	}
+}
+
+// mapClient implements nothing but Client
+type mapClient map[string][]byte
+
+func (m mapClient) Get(scope Scope, objID string) ([]byte, error) {
+	b, ok := m[cacheKey(scope, objID)]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return b, nil
+}
+
+func (m mapClient) Put(scope Scope, objID string, payload []byte) error {
+	m[cacheKey(scope, objID)] = payload
+	return nil
+}
+
+func (m mapClient) Del(scope Scope, objID string) error {
+	delete(m, cacheKey(scope, objID))
+	return nil
+}
+
+func TestCachingClientWrapsPlainClient(t *testing.T) {
+	c := NewCachingClient(mapClient{}, NewMemoryCache())
+	if err := c.(CopyClient).Copy(ScopeBot, "a", ScopeBot, "b"); !errors.Is(err, ErrNotSupported) {
+		t.Fatalf("got %v, want ErrNotSupported", err)
+	}
+	// Update falls back to an unconditional write
+	if err := Update(c, ScopeBot, "n.json", func(n *int) error { *n++; return nil }); err != nil {
+		t.Fatal(err)
+	}
+	if n, err := GetJSON[int](c, ScopeBot, "n.json"); err != nil || n != 1 {
+		t.Fatalf("got %d, %v", n, err)
+	}
+}
@@ -0,0 +1,240 @@
+package client
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// manifestMagic prefixes the manifest object of a chunked payload
+var manifestMagic = []byte("FBDBCHUNK1\n")
+
+// MaxChunkSize keeps a part under the 6 MB API Gateway payload limit once the body is base64 encoded
+const MaxChunkSize = 4 * 1024 * 1024
+
+// DefaultChunkSize is the largest part size the API accepts
+const DefaultChunkSize = MaxChunkSize
+
+// chunksMetaKey marks a manifest with "{writeId}:{parts}", so overwrites find the parts to clean up with a HEAD
+const chunksMetaKey = "chunked-parts"
+
+// maxManifestSize bounds manifests written before they were marked, which are only recognised by their content
+const maxManifestSize = 256 * 1024
+
+// maxPartFetches bounds concurrent part downloads while reassembling
+const maxPartFetches = 4
+
+// ErrChunkIntegrity is returned when a reassembled payload does not match its manifest
+var ErrChunkIntegrity = errors.New("chunk integrity check failed")
+
+// WithChunking splits stored payloads larger than size bytes (at most MaxChunkSize) into parts plus a manifest.
+// Chunked objects are reassembled by any client, whether or not it enables chunking.
+func WithChunking(size int) Option {
+	return func(c *client) {
+		c.chunkSize = size
+	}
+}
+
+type manifestPart struct {
+	Size   int    `json:"size"`
+	SHA256 string `json:"sha256"`
+}
+
+type manifest struct {
+	WriteID  string         `json:"writeId"`
+	Encoding string         `json:"encoding"`
+	Size     int            `json:"size"`
+	SHA256   string         `json:"sha256"`
+	Parts    []manifestPart `json:"parts"`
+}
+
+func isManifest(b []byte) bool {
+	return bytes.HasPrefix(b, manifestMagic)
+}
+
+func parseManifest(b []byte) (*manifest, error) {
+	var m manifest
+	if err := json.Unmarshal(b[len(manifestMagic):], &m); err != nil {
+		return nil, err
+	}
+	return &m, nil
+}
+
+// partKey names parts after the write that produced them, so a new write never touches parts a reader may be using
+func partKey(objID, writeID string, i int) string {
+	return fmt.Sprintf("%s.part-%s-%05d", objID, writeID, i)
+}
+
+func sha256Hex(b []byte) string {
+	h := sha256.Sum256(b)
+	return hex.EncodeToString(h[:])
+}
+
+// currentManifest returns the manifest stored at objID, or nil if the object is missing or not chunked.
+// Only the parts are filled in, which is all deleting them needs.
+func (c *client) currentManifest(scope Scope, objID string) (*manifest, error) {
+	resp, err := c.do("HEAD", c.objURL(scope, objID), nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp.Body.Close()
+	err = checkStatus(resp)
+	if errors.Is(err, ErrNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if v := resp.Header.Get(metaPrefix + chunksMetaKey); v != "" {
+		i := strings.LastIndex(v, ":")
+		parts, err := strconv.Atoi(v[i+1:])
+		if i < 0 || err != nil {
+			return nil, fmt.Errorf("invalid chunk marker %q", v)
+		}
+		return &manifest{WriteID: v[:i], Parts: make([]manifestPart, parts)}, nil
+	}
+	if size, _ := strconv.ParseInt(resp.Header.Get("X-Object-Size"), 10, 64); size > maxManifestSize {
+		return nil, nil
+	}
+	b, _, err := c.getRaw(scope, objID, http.Header{})
+	if errors.Is(err, ErrNotFound) {
+		return nil, nil
+	}
+	if err != nil || !isManifest(b) {
+		return nil, err
+	}
+	return parseManifest(b)
+}
+
+// deleteParts removes the parts of a replaced or deleted manifest
+func (c *client) deleteParts(scope Scope, objID string, m *manifest) error {
+	for i := range m.Parts {
+		if err := c.delRaw(scope, partKey(objID, m.WriteID, i)); err != nil && !errors.Is(err, ErrNotFound) {
+			return err
+		}
+	}
+	return nil
+}
+
+// putChunked writes the parts first and the manifest last, so readers only ever see complete payloads.
+// header carries the object's metadata, which is stored on the manifest.
+func (c *client) putChunked(scope Scope, objID string, b []byte, encoding string, header http.Header) error {
+	previous, err := c.currentManifest(scope, objID)
+	if err != nil {
+		return err
+	}
+
+	if len(b) <= c.chunkSize {
+		if encoding != CodecNone {
+			header.Set(encodingHeader, encoding)
+		}
+		if err := c.putRaw(scope, objID, b, header); err != nil {
+			return err
+		}
+	} else {
+		id := make([]byte, 8)
+		if _, err := rand.Read(id); err != nil {
+			return err
+		}
+		m := &manifest{
+			WriteID:  hex.EncodeToString(id),
+			Encoding: encoding,
+			Size:     len(b),
+			SHA256:   sha256Hex(b),
+		}
+		for i := 0; i*c.chunkSize < len(b); i++ {
+			end := (i + 1) * c.chunkSize
+			if end > len(b) {
+				end = len(b)
+			}
+			part := b[i*c.chunkSize : end]
+			if err := c.putRaw(scope, partKey(objID, m.WriteID, i), part, http.Header{}); err != nil {
+				return err
+			}
+			m.Parts = append(m.Parts, manifestPart{Size: len(part), SHA256: sha256Hex(part)})
+		}
+		mb, err := json.Marshal(m)
+		if err != nil {
+			return err
+		}
+		header.Set(metaPrefix+chunksMetaKey, fmt.Sprintf("%s:%d", m.WriteID, len(m.Parts)))
+		if err := c.putRaw(scope, objID, append(append([]byte(nil), manifestMagic...), mb...), header); err != nil {
+			if errors.Is(err, ErrVersionMismatch) {
+				// the write lost to another one, so its parts are never referenced
+				_ = c.deleteParts(scope, objID, m)
+			}
+			return err
+		}
+	}
+
+	if previous != nil {
+		return c.deleteParts(scope, objID, previous)
+	}
+	return nil
+}
+
+// delChunked deletes the manifest before its parts, so readers never see a manifest with missing parts
+func (c *client) delChunked(scope Scope, objID string) error {
+	m, err := c.currentManifest(scope, objID)
+	if err != nil {
+		return err
+	}
+	if err := c.delRaw(scope, objID); err != nil {
+		return err
+	}
+	if m != nil {
+		return c.deleteParts(scope, objID, m)
+	}
+	return nil
+}
+
+// reassemble fetches and verifies the parts of a manifest, returning the encoded payload and its encoding
+func (c *client) reassemble(scope Scope, objID string, b []byte) ([]byte, string, error) {
+	m, err := parseManifest(b)
+	if err != nil {
+		return nil, "", err
+	}
+
+	parts := make([][]byte, len(m.Parts))
+	errs := make([]error, len(m.Parts))
+	sem := make(chan struct{}, maxPartFetches)
+	var wg sync.WaitGroup
+	for i := range m.Parts {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+			part, _, err := c.getRaw(scope, partKey(objID, m.WriteID, i), http.Header{})
+			if err != nil {
+				errs[i] = err
+				return
+			}
+			if len(part) != m.Parts[i].Size || sha256Hex(part) != m.Parts[i].SHA256 {
+				errs[i] = ErrChunkIntegrity
+				return
+			}
+			parts[i] = part
+		}(i)
+	}
+	wg.Wait()
+	for _, err := range errs {
+		if err != nil {
+			return nil, "", err
+		}
+	}
+
+	payload := bytes.Join(parts, nil)
+	if len(payload) != m.Size || sha256Hex(payload) != m.SHA256 {
+		return nil, "", ErrChunkIntegrity
+	}
+	return payload, m.Encoding, nil
+}
@@ -0,0 +1,87 @@
+package client
+
+import (
+	"bytes"
+	"errors"
+	"math/rand"
+	"strings"
+	"testing"
+)
+
+func TestChunkedRoundTrip(t *testing.T) {
+	c, s := newTestClient(t, WithChunking(1024))
+	payload := make([]byte, 3000)
+	rand.New(rand.NewSource(1)).Read(payload)
+
+	if err := c.Put(ScopeBot, "big.bin", payload); err != nil {
+		t.Fatal(err)
+	}
+	// three parts plus the manifest
+	if n := len(s.objects); n != 4 {
+		t.Fatalf("stored %d objects, want 4", n)
+	}
+	got, err := c.Get(ScopeBot, "big.bin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, payload) {
+		t.Fatal("reassembled payload differs")
+	}
+
+	// a client without chunking reads it too
+	plain := &client{apiHost: c.apiHost, jwtProviderUrl: c.jwtProviderUrl, codec: CodecNone}
+	if got, err = plain.Get(ScopeBot, "big.bin"); err != nil || !bytes.Equal(got, payload) {
+		t.Fatal("client without chunking could not read the payload", err)
+	}
+
+	// overwriting finds the old parts from the manifest's metadata, without downloading it
+	gets := s.count("GET")
+	if err := c.Put(ScopeBot, "big.bin", payload[:2000]); err != nil {
+		t.Fatal(err)
+	}
+	if s.count("GET") != gets {
+		t.Fatal("overwrite downloaded the current object")
+	}
+	if n := len(s.objects); n != 3 {
+		t.Fatalf("stored %d objects after overwrite, want 3", n)
+	}
+	info, err := c.Stat(ScopeBot, "big.bin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := info.Metadata[chunksMetaKey]; ok {
+		t.Fatal("chunk marker is visible in metadata")
+	}
+
+	if err := c.Del(ScopeBot, "big.bin"); err != nil {
+		t.Fatal(err)
+	}
+	if n := len(s.objects); n != 0 {
+		t.Fatalf("%d objects left after delete", n)
+	}
+}
+
+func TestChunkIntegrity(t *testing.T) {
+	c, s := newTestClient(t, WithChunking(1024))
+	payload := bytes.Repeat([]byte("abcdefgh"), 400)
+	if err := c.Put(ScopeBot, "big.bin", payload); err != nil {
+		t.Fatal(err)
+	}
+	for key, obj := range s.objects {
+		if strings.HasSuffix(key, "-00001") {
+			obj.body[0] ^= 0xff
+		}
+	}
+	if _, err := c.Get(ScopeBot, "big.bin"); !errors.Is(err, ErrChunkIntegrity) {
+		t.Fatalf("got %v, want ErrChunkIntegrity", err)
+	}
+}
+
+func TestChunkSizeLimit(t *testing.T) {
+	if _, err := NewClient("http://localhost", "localhost", "1", WithChunking(MaxChunkSize+1)); err == nil {
+		t.Fatal("chunk size above MaxChunkSize was accepted")
+	}
+	if _, err := NewClient("http://localhost", "localhost", "1", WithChunking(DefaultChunkSize)); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -2,13 +2,16 @@ package client
 
 import (
 	"bytes"
-	"compress/gzip"
+	"crypto/sha256"
+	"encoding/base64"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
 	"net/http"
+	"net/url"
 	"os"
+	"strconv"
 	"strings"
 
 	log "github.com/sirupsen/logrus"
@@ -18,12 +21,36 @@ const urlPattern = "%s/database/%s/%s"
 
 var ErrNotFound = errors.New("not found")
 
+// ErrForbidden is returned when another bot or owner has not granted access to an object
+var ErrForbidden = errors.New("forbidden")
+
+// Client is the core object API.  Everything else is offered through optional interfaces, so implementations of
+// Client outside this package keep compiling as features are added.  The clients returned by NewClient and
+// clienttest implement all of them: InfoClient, RangeClient, VersionedClient, ConditionalGetter, BatchClient,
+// CopyClient, PrefixClient, WatchClient, SharingClient, KVClient, LockClient, StreamClient, ACLClient, InboxClient
+// and RateLimitClient.
 type Client interface {
 	Get(scope Scope, objID string) ([]byte, error)
 	Put(scope Scope, objID string, payload []byte) error
 	Del(scope Scope, objID string) error
 }
 
+// InfoClient reads and writes object metadata
+type InfoClient interface {
+	PutWithOptions(scope Scope, objID string, payload []byte, opts *PutOptions) error
+	GetWithInfo(scope Scope, objID string) ([]byte, *ObjectInfo, error)
+	Stat(scope Scope, objID string) (*ObjectInfo, error)
+	Exists(scope Scope, objID string) (bool, error)
+}
+
+// RangeClient reads part of an object
+type RangeClient interface {
+	GetRange(scope Scope, objID string, offset, length int64) ([]byte, error)
+}
+
+// ErrNotSupported is returned by wrappers such as NewCachingClient when the client they wrap lacks a feature
+var ErrNotSupported = errors.New("not supported by the wrapped client")
+
 type Scope string
 
 var ScopeBot Scope = "bot"
@@ -31,126 +58,408 @@ var ScopeScanner Scope = "scanner"
 
 var ScopeOwner Scope = "owner"
 
+// ScopePublic is written by the bot and readable by every bot, see Shared
+var ScopePublic Scope = "public"
+
 type client struct {
 	apiHost        string
 	jwtProviderUrl string
+	codec          string
+	keys           KeyProvider
+	chunkSize      int
+	// namespace is the bot or owner whose scope a Shared client addresses
+	namespace string
+}
+
+// Option configures optional client behavior
+type Option func(*client)
+
+// WithCodec sets the codec used for keys whose suffix does not select one (e.g. .gz, .zst, .sz)
+func WithCodec(name string) Option {
+	return func(c *client) {
+		c.codec = name
+	}
 }
 
-func gzipBytes(b []byte) ([]byte, error) {
-	var buf bytes.Buffer
-	zw := gzip.NewWriter(&buf)
-	_, err := zw.Write(b)
+func (c *client) objURL(scope Scope, objID string) string {
+	if c.namespace != "" && scope == ScopePublic {
+		return fmt.Sprintf("%s/database/public/%s/%s", c.apiHost, c.namespace, objID)
+	}
+	u := fmt.Sprintf(urlPattern, c.apiHost, scope, objID)
+	if c.namespace != "" && (scope == ScopeBot || scope == ScopeOwner) {
+		// ?bot= or ?owner=
+		u = addQuery(u, string(scope)+"="+url.QueryEscape(c.namespace))
+	}
+	return u
+}
+
+// addQuery appends an encoded query to u, which may already have one
+func addQuery(u, query string) string {
+	if strings.Contains(u, "?") {
+		return u + "&" + query
+	}
+	return u + "?" + query
+}
+
+func (c *client) do(method, url string, body []byte, header http.Header) (*http.Response, error) {
+	var r io.Reader
+	if body != nil {
+		r = bytes.NewReader(body)
+	}
+	req, err := http.NewRequest(method, url, r)
 	if err != nil {
 		return nil, err
 	}
-	if err := zw.Close(); err != nil {
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	if err := c.addAuth(req); err != nil {
 		return nil, err
 	}
-	return buf.Bytes(), nil
+
+	hc := &http.Client{}
+	return hc.Do(req)
+}
+
+// StatusError is returned when the API responds with an unexpected status code
+type StatusError struct {
+	StatusCode int
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("response %d", e.StatusCode)
+}
+
+func checkStatus(resp *http.Response) error {
+	if resp.StatusCode == 404 {
+		return ErrNotFound
+	}
+	if resp.StatusCode == 403 {
+		return ErrForbidden
+	}
+	if resp.StatusCode >= 400 {
+		return &StatusError{StatusCode: resp.StatusCode}
+	}
+	return nil
 }
 
-func gunzipBytes(b []byte) ([]byte, error) {
-	r, err := gzip.NewReader(bytes.NewReader(b))
+// encode compresses (and optionally encrypts) a payload, returning the body and its encoding
+func (c *client) encode(scope Scope, objID string, payload []byte) ([]byte, string, error) {
+	name := codecForKey(objID)
+	if name == "" {
+		name = c.codec
+	}
+	codec, err := getCodec(name)
 	if err != nil {
-		return nil, err
+		return nil, "", err
+	}
+	b, err := codec.Encode(payload)
+	if err != nil {
+		return nil, "", err
+	}
+	if c.keys == nil {
+		return b, codec.Name(), nil
 	}
-	defer r.Close()
-	return io.ReadAll(r)
+	// the codec travels inside the envelope, so the server only sees opaque bytes
+	b, err = encrypt(c.keys, scope, objID, codec.Name(), b)
+	if err != nil {
+		return nil, "", err
+	}
+	return b, CodecNone, nil
 }
 
-func (c *client) Put(scope Scope, objID string, payload []byte) error {
-	pl := payload
-	if strings.HasSuffix(objID, ".gz") {
-		gzipPayload, err := gzipBytes(payload)
+// decode reverses encode for a stored body
+func (c *client) decode(scope Scope, objID string, b []byte, encoding string) ([]byte, error) {
+	switch {
+	case isEnvelope(b) && c.keys == nil:
+		return nil, ErrEncrypted
+	case isEnvelope(b):
+		pt, enc, err := decrypt(c.keys, scope, objID, b)
 		if err != nil {
-			return err
+			return nil, err
 		}
-		pl = gzipPayload
+		b, encoding = pt, enc
+	case c.keys != nil:
+		return nil, ErrNotEncrypted
 	}
 
-	req, err := http.NewRequest("PUT", fmt.Sprintf(urlPattern, c.apiHost, scope, objID), bytes.NewReader(pl))
+	if encoding == "" && strings.HasSuffix(objID, ".gz") {
+		// objects written before the encoding was recorded
+		encoding = CodecGzip
+	}
+	codec, err := getCodec(encoding)
 	if err != nil {
-		return err
+		return nil, err
 	}
-	if err := c.addAuth(req); err != nil {
+	return codec.Decode(b)
+}
+
+// ErrChecksumMismatch is returned when a payload does not match its checksum
+var ErrChecksumMismatch = errors.New("checksum mismatch")
+
+func checksumSHA256(b []byte) string {
+	sum := sha256.Sum256(b)
+	return base64.StdEncoding.EncodeToString(sum[:])
+}
+
+// putRaw stores an already encoded body
+func (c *client) putRaw(scope Scope, objID string, body []byte, header http.Header) error {
+	// lets the server reject bodies that were corrupted or truncated on the way
+	header.Set("X-Checksum-SHA256", checksumSHA256(body))
+	resp, err := c.do("PUT", c.objURL(scope, objID), body, header)
+	if err != nil {
 		return err
 	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusPreconditionFailed {
+		return ErrVersionMismatch
+	}
+	return checkStatus(resp)
+}
 
-	hc := &http.Client{}
-	resp, err := hc.Do(req)
+func (c *client) delRaw(scope Scope, objID string) error {
+	resp, err := c.do("DELETE", c.objURL(scope, objID), nil, nil)
 	if err != nil {
 		return err
 	}
-	if resp.StatusCode == 404 {
-		return ErrNotFound
+	defer resp.Body.Close()
+	return checkStatus(resp)
+}
+
+// getRaw fetches a stored body without decoding it
+func (c *client) getRaw(scope Scope, objID string, header http.Header) ([]byte, http.Header, error) {
+	b, h, err := c.getURL(c.objURL(scope, objID), header)
+	var se *StatusError
+	if errors.As(err, &se) && se.StatusCode == http.StatusInternalServerError {
+		log.WithError(err).Error("500 error...coercing to 404")
+		return nil, nil, ErrNotFound
 	}
-	if resp.StatusCode >= 400 {
-		return fmt.Errorf("response %d", resp.StatusCode)
+	return b, h, err
+}
+
+// getURL is getRaw for object URLs with query parameters.  Unlike getRaw, server errors are returned as they are.
+func (c *client) getURL(url string, header http.Header) ([]byte, http.Header, error) {
+	header.Set("Accept-Encoding", acceptEncoding())
+	resp, err := c.do("GET", url, nil, header)
+	if err != nil {
+		return nil, nil, err
 	}
-	return nil
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotModified {
+		return nil, resp.Header, ErrNotModified
+	}
+	if err := checkStatus(resp); err != nil {
+		return nil, nil, err
+	}
+	b, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, nil, err
+	}
+	if sum := resp.Header.Get("X-Checksum-SHA256"); sum != "" && sum != checksumSHA256(b) {
+		return nil, nil, ErrChecksumMismatch
+	}
+	// the server compresses objects at rest and may send them that way; undo it so the body is as it was written
+	if enc := resp.Header.Get(serverEncodingHeader); enc != "" {
+		codec, err := getCodec(enc)
+		if err != nil {
+			return nil, nil, err
+		}
+		if b, err = codec.Decode(b); err != nil {
+			return nil, nil, err
+		}
+		resp.Header.Del("Content-Encoding")
+		resp.Header.Del(serverEncodingHeader)
+	}
+	return b, resp.Header, nil
 }
 
-func (c *client) Del(scope Scope, objID string) error {
-	req, err := http.NewRequest("DELETE", fmt.Sprintf(urlPattern, c.apiHost, scope, objID), nil)
+// serverEncodingHeader marks a Content-Encoding applied by the server rather than by the writer
+const serverEncodingHeader = "X-Server-Encoding"
+
+func (c *client) Put(scope Scope, objID string, payload []byte) error {
+	return c.PutWithOptions(scope, objID, payload, nil)
+}
+
+// PutWithOptions stores a payload along with its content type and custom metadata
+func (c *client) PutWithOptions(scope Scope, objID string, payload []byte, opts *PutOptions) error {
+	return c.put(scope, objID, payload, opts, http.Header{})
+}
+
+var _ VersionedClient = (*client)(nil)
+var _ InfoClient = (*client)(nil)
+var _ RangeClient = (*client)(nil)
+
+// GetVersioned returns the object along with its ETag, which is the version PutIfVersion compares
+func (c *client) GetVersioned(scope Scope, objID string) ([]byte, string, error) {
+	b, h, err := c.get(scope, objID, http.Header{})
 	if err != nil {
-		return err
+		return nil, "", err
 	}
-	if err := c.addAuth(req); err != nil {
-		return err
+	return b, h.Get("ETag"), nil
+}
+
+// PutIfVersion writes the object only if its ETag is still version, or if it does not exist when version is empty.
+// The server checks the condition atomically with the write and ErrVersionMismatch is returned when it fails.
+func (c *client) PutIfVersion(scope Scope, objID string, payload []byte, version string) error {
+	header := http.Header{}
+	if version == "" {
+		header.Set("If-None-Match", "*")
+	} else {
+		header.Set("If-Match", version)
 	}
+	return c.put(scope, objID, payload, nil, header)
+}
 
-	hc := &http.Client{}
-	resp, err := hc.Do(req)
+// put encodes and stores a payload; header may carry a write condition
+func (c *client) put(scope Scope, objID string, payload []byte, opts *PutOptions, header http.Header) error {
+	pl, encoding, err := c.encode(scope, objID, payload)
 	if err != nil {
 		return err
 	}
-	if resp.StatusCode == 404 {
-		return ErrNotFound
+
+	if opts != nil {
+		if opts.ContentType != "" {
+			header.Set("Content-Type", opts.ContentType)
+		}
+		for k, v := range opts.Metadata {
+			header.Set(metaPrefix+k, v)
+		}
 	}
-	if resp.StatusCode >= 400 {
-		return fmt.Errorf("response %d", resp.StatusCode)
+	if c.chunkSize > 0 {
+		return c.putChunked(scope, objID, pl, encoding, header)
 	}
-	return nil
+	if encoding != CodecNone {
+		header.Set(encodingHeader, encoding)
+	}
+	return c.putRaw(scope, objID, pl, header)
 }
 
-func (c *client) Get(scope Scope, objID string) ([]byte, error) {
-	req, err := http.NewRequest("GET", fmt.Sprintf(urlPattern, c.apiHost, scope, objID), nil)
+func (c *client) Del(scope Scope, objID string) error {
+	if c.chunkSize > 0 {
+		return c.delChunked(scope, objID)
+	}
+	return c.delRaw(scope, objID)
+}
+
+// get fetches, reassembles and decodes an object, returning the response headers alongside it
+func (c *client) get(scope Scope, objID string, header http.Header) ([]byte, http.Header, error) {
+	b, h, err := c.getRaw(scope, objID, header)
 	if err != nil {
-		return nil, err
+		return nil, h, err
 	}
-	if err := c.addAuth(req); err != nil {
-		return nil, err
+	b, err = c.decodeObject(scope, objID, b, h)
+	if err != nil {
+		return nil, nil, err
 	}
+	return b, h, nil
+}
 
-	hc := &http.Client{}
-	resp, err := hc.Do(req)
+// decodeObject reassembles and decodes an object as returned by getRaw
+func (c *client) decodeObject(scope Scope, objID string, b []byte, h http.Header) ([]byte, error) {
+	var err error
+	encoding := h.Get(encodingHeader)
+	if isManifest(b) {
+		b, encoding, err = c.reassemble(scope, objID, b)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return c.decode(scope, objID, b, encoding)
+}
+
+func (c *client) Get(scope Scope, objID string) ([]byte, error) {
+	b, _, err := c.get(scope, objID, http.Header{})
+	return b, err
+}
+
+// GetWithInfo fetches an object along with its metadata
+func (c *client) GetWithInfo(scope Scope, objID string) ([]byte, *ObjectInfo, error) {
+	b, h, err := c.get(scope, objID, http.Header{})
+	if err != nil {
+		return nil, nil, err
+	}
+	return b, objectInfo(h), nil
+}
+
+// GetRange reads length bytes starting at offset (length <= 0 reads to the end).
+// The bytes are returned as stored, so this is only meaningful for objects written without compression, encryption or chunking.
+func (c *client) GetRange(scope Scope, objID string, offset, length int64) ([]byte, error) {
+	header := http.Header{}
+	if length > 0 {
+		header.Set("Range", fmt.Sprintf("bytes=%d-%d", offset, offset+length-1))
+	} else {
+		header.Set("Range", fmt.Sprintf("bytes=%d-", offset))
+	}
+	b, h, err := c.getRaw(scope, objID, header)
 	if err != nil {
 		return nil, err
 	}
-	defer resp.Body.Close()
-	if resp.StatusCode == 404 {
-		return nil, ErrNotFound
+	if h.Get("Content-Range") == "" {
+		// the whole object came back
+		if offset >= int64(len(b)) {
+			return nil, &StatusError{StatusCode: http.StatusRequestedRangeNotSatisfiable}
+		}
+		b = b[offset:]
+		if length > 0 && length < int64(len(b)) {
+			b = b[:length]
+		}
 	}
-	if resp.StatusCode == 500 {
-		log.WithError(err).Error("500 error...coercing to 404")
-		return nil, ErrNotFound
+	return b, nil
+}
+
+func (c *client) GetIfNoneMatch(scope Scope, objID string, etag string) ([]byte, string, error) {
+	header := http.Header{}
+	if etag != "" {
+		header.Set("If-None-Match", etag)
 	}
-	if resp.StatusCode >= 400 {
-		return nil, fmt.Errorf("response %d", resp.StatusCode)
+	b, h, err := c.get(scope, objID, header)
+	if err != nil {
+		return nil, "", err
 	}
-	b, err := io.ReadAll(resp.Body)
+	return b, h.Get("ETag"), nil
+}
+
+// metaPrefix marks custom object metadata headers
+const metaPrefix = "X-Meta-"
+
+func objectInfo(h http.Header) *ObjectInfo {
+	info := &ObjectInfo{
+		ETag:            h.Get("ETag"),
+		ContentType:     h.Get("Content-Type"),
+		ContentEncoding: h.Get(encodingHeader),
+		ChecksumSHA256:  h.Get("X-Checksum-SHA256"),
+		Metadata:        map[string]string{},
+	}
+	info.Size, _ = strconv.ParseInt(h.Get("X-Object-Size"), 10, 64)
+	info.LastModified, _ = http.ParseTime(h.Get("Last-Modified"))
+	for k := range h {
+		name := strings.ToLower(strings.TrimPrefix(k, metaPrefix))
+		if strings.HasPrefix(k, metaPrefix) && name != chunksMetaKey {
+			info.Metadata[name] = h.Get(k)
+		}
+	}
+	return info
+}
+
+// Stat returns an object's metadata without fetching it.  For chunked objects, Size is that of the manifest.
+func (c *client) Stat(scope Scope, objID string) (*ObjectInfo, error) {
+	resp, err := c.do("HEAD", c.objURL(scope, objID), nil, nil)
 	if err != nil {
 		return nil, err
 	}
-	if strings.HasSuffix(objID, ".gz") {
-		bts, err := gunzipBytes(b)
-		if err != nil {
-			return nil, err
-		}
-		b = bts
+	defer resp.Body.Close()
+	if err := checkStatus(resp); err != nil {
+		return nil, err
 	}
+	return objectInfo(resp.Header), nil
+}
 
-	return b, nil
+func (c *client) Exists(scope Scope, objID string) (bool, error) {
+	_, err := c.Stat(scope, objID)
+	if errors.Is(err, ErrNotFound) {
+		return false, nil
+	}
+	return err == nil, err
 }
 
 func (c *client) addAuth(r *http.Request) error {
@@ -177,13 +486,24 @@ func (c *client) token() (string, error) {
 	return jwtResp.Token, nil
 }
 
-func NewDefaultClient(apiHost string) (Client, error) {
-	return NewClient(apiHost, os.Getenv("FORTA_JWT_PROVIDER_HOST"), os.Getenv("FORTA_JWT_PROVIDER_PORT"))
+func NewDefaultClient(apiHost string, opts ...Option) (Client, error) {
+	return NewClient(apiHost, os.Getenv("FORTA_JWT_PROVIDER_HOST"), os.Getenv("FORTA_JWT_PROVIDER_PORT"), opts...)
 }
 
-func NewClient(apiHost, jwtProviderHost, jwtProviderPort string) (Client, error) {
-	return &client{
+func NewClient(apiHost, jwtProviderHost, jwtProviderPort string, opts ...Option) (Client, error) {
+	c := &client{
 		apiHost:        apiHost,
 		jwtProviderUrl: fmt.Sprintf("http://%s:%s/create", jwtProviderHost, jwtProviderPort),
-	}, nil
+		codec:          CodecNone,
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	if _, err := getCodec(c.codec); err != nil {
+		return nil, err
+	}
+	if c.chunkSize > MaxChunkSize {
+		return nil, fmt.Errorf("chunk size must not exceed %d bytes", MaxChunkSize)
+	}
+	return c, nil
 }
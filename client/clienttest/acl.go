@@ -0,0 +1,220 @@
+package clienttest
+
+import (
+	"context"
+	"strings"
+
+	"forta-bot-db/client"
+)
+
+type acl struct {
+	c *Client
+}
+
+var _ client.ACL = (*acl)(nil)
+var _ client.ACLClient = (*Client)(nil)
+
+// ACL returns the fake's grant management API, which shares faults and call recording with the client
+func (c *Client) ACL() client.ACL {
+	return &acl{c: c}
+}
+
+// partition returns the key prefix of a shareable scope, which grants are stored under
+func (a *acl) partition(method string, scope client.Scope, id string) (string, error) {
+	if err := a.c.begin(method, id); err != nil {
+		return "", err
+	}
+	if scope != client.ScopeBot && scope != client.ScopeOwner {
+		return "", client.ErrScopeNotShared
+	}
+	return a.c.objectKey(scope, "")
+}
+
+// Grant records the grantee as the call's ObjID
+func (a *acl) Grant(scope client.Scope, grantee, prefix, access string) error {
+	err := a.grant(scope, grantee, prefix, access)
+	a.c.record("Grant", scope, grantee, err)
+	return err
+}
+
+func (a *acl) grant(scope client.Scope, grantee, prefix, access string) error {
+	key, err := a.partition("Grant", scope, grantee)
+	if err != nil {
+		return err
+	}
+	if access != client.AccessRead && access != client.AccessReadWrite {
+		return &client.StatusError{StatusCode: 400}
+	}
+	grantee = strings.ToLower(grantee)
+	a.c.store.mu.Lock()
+	defer a.c.store.mu.Unlock()
+	grants := a.c.store.grants[key]
+	for i, g := range grants {
+		if g.Grantee == grantee && g.Prefix == prefix {
+			grants[i].Access = access
+			return nil
+		}
+	}
+	a.c.store.grants[key] = append(grants, client.Grant{Grantee: grantee, Prefix: prefix, Access: access})
+	return nil
+}
+
+// Revoke records the grantee as the call's ObjID
+func (a *acl) Revoke(scope client.Scope, grantee, prefix string) error {
+	err := a.revoke(scope, grantee, prefix)
+	a.c.record("Revoke", scope, grantee, err)
+	return err
+}
+
+func (a *acl) revoke(scope client.Scope, grantee, prefix string) error {
+	key, err := a.partition("Revoke", scope, grantee)
+	if err != nil {
+		return err
+	}
+	grantee = strings.ToLower(grantee)
+	a.c.store.mu.Lock()
+	defer a.c.store.mu.Unlock()
+	grants := a.c.store.grants[key]
+	for i, g := range grants {
+		if g.Grantee == grantee && g.Prefix == prefix {
+			a.c.store.grants[key] = append(grants[:i:i], grants[i+1:]...)
+			break
+		}
+	}
+	return nil
+}
+
+func (a *acl) Grants(scope client.Scope) ([]client.Grant, error) {
+	grants, err := a.grants(scope)
+	a.c.record("Grants", scope, "", err)
+	return grants, err
+}
+
+func (a *acl) grants(scope client.Scope) ([]client.Grant, error) {
+	key, err := a.partition("Grants", scope, "")
+	if err != nil {
+		return nil, err
+	}
+	a.c.store.mu.Lock()
+	defer a.c.store.mu.Unlock()
+	return append([]client.Grant{}, a.c.store.grants[key]...), nil
+}
+
+// shared is a Shared client: the grantor's namespace is reached through a client with the grantor's identity,
+// after checking the caller's grants
+type shared struct {
+	c      *Client
+	target *Client
+	scope  client.Scope
+}
+
+var _ client.Shared = (*shared)(nil)
+var _ client.SharingClient = (*Client)(nil)
+
+// Shared returns a fake Shared client; calls are recorded on, and faults applied by, this client
+func (c *Client) Shared(scope client.Scope, id string) client.Shared {
+	id = strings.ToLower(id)
+	targetID := Identity{BotID: c.id.BotID, Scanner: c.id.Scanner, Owner: c.id.Owner}
+	switch scope {
+	case client.ScopeBot, client.ScopePublic:
+		targetID.BotID = id
+	case client.ScopeOwner:
+		targetID.Owner = id
+	}
+	return &shared{c: c, target: c.store.Client(targetID), scope: scope}
+}
+
+// authorize checks that a grant covers objID, like the server does for ?bot= and ?owner=,
+// or that only the publisher writes a public scope
+func (s *shared) authorize(method, objID string, write bool) error {
+	if err := s.c.begin(method, objID); err != nil {
+		return err
+	}
+	if s.scope == client.ScopeScanner {
+		return client.ErrScopeNotShared
+	}
+	if s.target.id == s.c.id {
+		// the caller's own namespace
+		return nil
+	}
+	if s.scope == client.ScopePublic {
+		if write {
+			return client.ErrForbidden
+		}
+		return nil
+	}
+	key, err := s.target.objectKey(s.scope, "")
+	if err != nil {
+		return err
+	}
+	grantees := []string{client.BotGrantee(s.c.id.BotID)}
+	if s.c.id.Owner != "" {
+		grantees = append(grantees, client.OwnerGrantee(s.c.id.Owner))
+	}
+	s.c.store.mu.Lock()
+	defer s.c.store.mu.Unlock()
+	for _, g := range s.c.store.grants[key] {
+		for _, grantee := range grantees {
+			if g.Grantee == grantee && strings.HasPrefix(objID, g.Prefix) && (g.Access == client.AccessReadWrite || !write) {
+				return nil
+			}
+		}
+	}
+	return client.ErrForbidden
+}
+
+func (s *shared) Get(objID string) ([]byte, error) {
+	b, _, err := s.GetWithInfo(objID)
+	return b, err
+}
+
+func (s *shared) GetWithInfo(objID string) ([]byte, *client.ObjectInfo, error) {
+	var b []byte
+	var info *client.ObjectInfo
+	err := s.authorize("SharedGet", objID, false)
+	if err == nil {
+		b, info, err = s.target.get(s.scope, objID)
+	}
+	s.c.record("SharedGet", s.scope, objID, err)
+	return b, info, err
+}
+
+func (s *shared) Put(objID string, payload []byte) error {
+	err := s.authorize("SharedPut", objID, true)
+	if err == nil {
+		err = s.target.put(s.scope, objID, payload, nil, nil)
+	}
+	s.c.record("SharedPut", s.scope, objID, err)
+	return err
+}
+
+func (s *shared) Del(objID string) error {
+	err := s.authorize("SharedDel", objID, true)
+	if err == nil {
+		err = s.target.Del(s.scope, objID)
+	}
+	s.c.record("SharedDel", s.scope, objID, err)
+	return err
+}
+
+func (s *shared) Stat(objID string) (*client.ObjectInfo, error) {
+	var info *client.ObjectInfo
+	err := s.authorize("SharedStat", objID, false)
+	if err == nil {
+		info, err = s.target.stat(s.scope, objID)
+	}
+	s.c.record("SharedStat", s.scope, objID, err)
+	return info, err
+}
+
+func (s *shared) Watch(ctx context.Context, objID string) <-chan *client.ObjectUpdate {
+	err := s.authorize("SharedWatch", objID, false)
+	s.c.record("SharedWatch", s.scope, objID, err)
+	if err != nil {
+		ch := make(chan *client.ObjectUpdate, 1)
+		ch <- &client.ObjectUpdate{Err: err}
+		close(ch)
+		return ch
+	}
+	return s.target.Watch(ctx, s.scope, objID)
+}
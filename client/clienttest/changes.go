@@ -0,0 +1,111 @@
+package clienttest
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"forta-bot-db/client"
+)
+
+// watchPoll is how often the fake's Watch checks the store
+var watchPoll = 10 * time.Millisecond
+
+// recordChange appends a write to the change feed of its scope, like the server does; the caller holds the store lock
+func (c *Client) recordChange(scope client.Scope, op, objID, etag string) {
+	prefix, err := c.objectKey(scope, "")
+	if err != nil {
+		return
+	}
+	feed := c.store.changes[prefix]
+	c.store.changes[prefix] = append(feed, client.Change{
+		Seq:   int64(len(feed) + 1),
+		Op:    op,
+		ObjID: objID,
+		ETag:  etag,
+		Time:  time.Now(),
+	})
+}
+
+// Changes records the prefix as the call's ObjID
+func (c *Client) Changes(scope client.Scope, prefix string, from int64, limit int) ([]client.Change, int64, error) {
+	changes, next, err := c.changes(scope, prefix, from, limit)
+	c.record("Changes", scope, prefix, err)
+	return changes, next, err
+}
+
+func (c *Client) changes(scope client.Scope, prefix string, from int64, limit int) ([]client.Change, int64, error) {
+	if err := c.begin("Changes", prefix); err != nil {
+		return nil, from, err
+	}
+	scopePrefix, err := c.objectKey(scope, "")
+	if err != nil {
+		return nil, from, err
+	}
+	if limit <= 0 {
+		limit = defaultReadLimit
+	}
+	if from < 1 {
+		from = 1
+	}
+	c.store.mu.Lock()
+	defer c.store.mu.Unlock()
+	feed := c.store.changes[scopePrefix]
+	next := from
+	changes := []client.Change{}
+	// like the server, the limit counts changes read rather than changes matching the prefix
+	for i := from - 1; i < int64(len(feed)) && i < from-1+int64(limit); i++ {
+		ch := feed[i]
+		next = ch.Seq + 1
+		if strings.HasPrefix(ch.ObjID, prefix) || (ch.Op == client.ChangeDelPrefix && strings.HasPrefix(prefix, ch.ObjID)) {
+			changes = append(changes, ch)
+		}
+	}
+	return changes, next, nil
+}
+
+// Watch polls the store, delivering updates like the real client does.  Faults do not apply to it.
+func (c *Client) Watch(ctx context.Context, scope client.Scope, objID string) <-chan *client.ObjectUpdate {
+	c.record("Watch", scope, objID, nil)
+	ch := make(chan *client.ObjectUpdate)
+	go func() {
+		defer close(ch)
+		key, err := c.objectKey(scope, objID)
+		if err != nil {
+			select {
+			case ch <- &client.ObjectUpdate{Err: err}:
+			case <-ctx.Done():
+			}
+			return
+		}
+		var version string
+		for {
+			var update *client.ObjectUpdate
+			c.store.mu.Lock()
+			obj, ok := c.store.objects[key]
+			switch {
+			case ok && obj.version != version:
+				version = obj.version
+				update = &client.ObjectUpdate{Payload: append([]byte(nil), obj.payload...), Info: obj.info()}
+			case !ok && version != "":
+				version = ""
+				update = &client.ObjectUpdate{Deleted: true}
+			}
+			c.store.mu.Unlock()
+
+			if update != nil {
+				select {
+				case ch <- update:
+				case <-ctx.Done():
+					return
+				}
+			}
+			select {
+			case <-time.After(watchPoll):
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return ch
+}
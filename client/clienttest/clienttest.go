@@ -0,0 +1,525 @@
+// Package clienttest provides an in-memory client.Client for bot unit tests.
+//
+// Clients created from the same Store share objects the way the API does:
+// `scanner` scope is private to one bot on one scanner, `bot` scope is shared by every scanner
+// running the bot, and `owner` scope is shared by every bot with the same owner.
+package clienttest
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"forta-bot-db/client"
+)
+
+// ErrUnavailable is a convenient 5xx error for fault injection
+var ErrUnavailable = &client.StatusError{StatusCode: 503}
+
+// Identity is the bot instance a Client acts as
+type Identity struct {
+	BotID   string
+	Scanner string
+	Owner   string
+}
+
+type object struct {
+	payload     []byte
+	version     string
+	modified    time.Time
+	contentType string
+	metadata    map[string]string
+}
+
+func (o *object) info() *client.ObjectInfo {
+	meta := map[string]string{}
+	for k, v := range o.metadata {
+		meta[k] = v
+	}
+	sum := sha256.Sum256(o.payload)
+	return &client.ObjectInfo{
+		Size:           int64(len(o.payload)),
+		ETag:           o.version,
+		LastModified:   o.modified,
+		ContentType:    o.contentType,
+		ChecksumSHA256: base64.StdEncoding.EncodeToString(sum[:]),
+		Metadata:       meta,
+	}
+}
+
+// Store is the shared backend for fake clients
+type Store struct {
+	mu      sync.Mutex
+	objects map[string]*object
+	items   map[string]*item
+	locks   map[string]*lock
+	streams map[string][]client.StreamRecord
+	changes map[string][]client.Change
+	grants  map[string][]client.Grant
+	inboxes map[string][]client.Message
+	buckets map[string]*bucket
+	seq     int64
+}
+
+func NewStore() *Store {
+	return &Store{objects: make(map[string]*object), items: make(map[string]*item), locks: make(map[string]*lock), streams: make(map[string][]client.StreamRecord),
+		changes: make(map[string][]client.Change), grants: make(map[string][]client.Grant), inboxes: make(map[string][]client.Message),
+		buckets: make(map[string]*bucket)}
+}
+
+// Client returns a fake client acting as the given bot instance
+func (s *Store) Client(id Identity) *Client {
+	return &Client{
+		store: s,
+		id: Identity{
+			BotID:   strings.ToLower(id.BotID),
+			Scanner: strings.ToLower(id.Scanner),
+			Owner:   strings.ToLower(id.Owner),
+		},
+	}
+}
+
+// Keys returns the fully scoped keys of every stored object, using the server's layout
+func (s *Store) Keys() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var keys []string
+	for k := range s.objects {
+		keys = append(keys, k)
+	}
+	return keys
+}
+
+func (s *Store) nextVersion() string {
+	s.seq++
+	return strconv.FormatInt(s.seq, 10)
+}
+
+// Call records one client invocation
+type Call struct {
+	Method string
+	Scope  client.Scope
+	ObjID  string
+	Err    error
+}
+
+// Fault makes matching calls fail or slow down.
+// Method is "Get", "Put", "Del", "Stat", "Copy", "Move" or "DelPrefix" (conditional variants count as Get and Put),
+// or "KVGet", "KVPut" (which also covers Incr and CompareAndSwap) and "KVDel" for the key-value API,
+// "LockAcquire", "LockRenew", "LockRelease" and "LockHolder" for locks, "StreamAppend" and "StreamRead" for streams,
+// "Changes" for the change feed, "Grant", "Revoke" and "Grants" for the ACL, "InboxSend", "InboxList" and "InboxAck" for the inbox,
+// "RateLimitTake" for rate limits, or "SharedGet", "SharedPut", "SharedDel", "SharedStat" and "SharedWatch" for Shared clients.
+// Empty Method or ObjID match anything; Times is the number of calls affected (0 means every call).
+type Fault struct {
+	Method  string
+	ObjID   string
+	Err     error
+	Latency time.Duration
+	Times   int
+}
+
+// Client is a thread-safe in-memory client.Client
+type Client struct {
+	store *Store
+	id    Identity
+
+	mu     sync.Mutex
+	faults []*Fault
+	calls  []Call
+}
+
+var _ client.Client = (*Client)(nil)
+var _ client.VersionedClient = (*Client)(nil)
+var _ client.ConditionalGetter = (*Client)(nil)
+var _ client.InfoClient = (*Client)(nil)
+var _ client.RangeClient = (*Client)(nil)
+var _ client.BatchClient = (*Client)(nil)
+var _ client.CopyClient = (*Client)(nil)
+var _ client.PrefixClient = (*Client)(nil)
+var _ client.WatchClient = (*Client)(nil)
+
+// InjectFault adds a fault; faults are checked in the order they were added
+func (c *Client) InjectFault(f Fault) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.faults = append(c.faults, &f)
+}
+
+func (c *Client) ClearFaults() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.faults = nil
+}
+
+// Calls returns every call made so far
+func (c *Client) Calls() []Call {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]Call(nil), c.calls...)
+}
+
+func (c *Client) ResetCalls() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.calls = nil
+}
+
+// begin applies any matching fault
+func (c *Client) begin(method string, objID string) error {
+	c.mu.Lock()
+	var fault *Fault
+	for i, f := range c.faults {
+		if (f.Method == "" || f.Method == method) && (f.ObjID == "" || f.ObjID == objID) {
+			fault = f
+			if f.Times > 0 {
+				f.Times--
+				if f.Times == 0 {
+					c.faults = append(c.faults[:i], c.faults[i+1:]...)
+				}
+			}
+			break
+		}
+	}
+	c.mu.Unlock()
+
+	if fault == nil {
+		return nil
+	}
+	if fault.Latency > 0 {
+		time.Sleep(fault.Latency)
+	}
+	return fault.Err
+}
+
+func (c *Client) record(method string, scope client.Scope, objID string, err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.calls = append(c.calls, Call{Method: method, Scope: scope, ObjID: objID, Err: err})
+}
+
+// objectKey mirrors the server's key layout
+func (c *Client) objectKey(scope client.Scope, objID string) (string, error) {
+	switch scope {
+	case client.ScopeScanner:
+		return fmt.Sprintf("%s/%s/%s", c.id.BotID, c.id.Scanner, objID), nil
+	case client.ScopeBot:
+		return fmt.Sprintf("%s/%s", c.id.BotID, objID), nil
+	case client.ScopeOwner:
+		return fmt.Sprintf("owner/%s/%s", c.id.Owner, objID), nil
+	case client.ScopePublic:
+		return fmt.Sprintf("public/%s/%s", strings.ToLower(c.id.BotID), objID), nil
+	default:
+		return "", client.ErrNotFound
+	}
+}
+
+func (c *Client) get(scope client.Scope, objID string) ([]byte, *client.ObjectInfo, error) {
+	if err := c.begin("Get", objID); err != nil {
+		return nil, nil, err
+	}
+	key, err := c.objectKey(scope, objID)
+	if err != nil {
+		return nil, nil, err
+	}
+	c.store.mu.Lock()
+	defer c.store.mu.Unlock()
+	obj, ok := c.store.objects[key]
+	if !ok {
+		return nil, nil, client.ErrNotFound
+	}
+	return append([]byte(nil), obj.payload...), obj.info(), nil
+}
+
+func (c *Client) put(scope client.Scope, objID string, payload []byte, opts *client.PutOptions, check func(*object) error) error {
+	if err := c.begin("Put", objID); err != nil {
+		return err
+	}
+	if objID == "_batch" || (scope == client.ScopePublic && (objID == "copy" || objID == "move")) {
+		// reserved by the server for its batch, copy and move routes
+		return &client.StatusError{StatusCode: 400}
+	}
+	key, err := c.objectKey(scope, objID)
+	if err != nil {
+		return err
+	}
+	c.store.mu.Lock()
+	defer c.store.mu.Unlock()
+	if check != nil {
+		if err := check(c.store.objects[key]); err != nil {
+			return err
+		}
+	}
+	obj := &object{
+		payload:  append([]byte(nil), payload...),
+		version:  c.store.nextVersion(),
+		modified: time.Now(),
+		metadata: map[string]string{},
+	}
+	if opts != nil {
+		obj.contentType = opts.ContentType
+		for k, v := range opts.Metadata {
+			obj.metadata[strings.ToLower(k)] = v
+		}
+	}
+	c.store.objects[key] = obj
+	c.recordChange(scope, client.ChangePut, objID, obj.version)
+	return nil
+}
+
+func (c *Client) Get(scope client.Scope, objID string) ([]byte, error) {
+	b, _, err := c.get(scope, objID)
+	c.record("Get", scope, objID, err)
+	return b, err
+}
+
+func (c *Client) Put(scope client.Scope, objID string, payload []byte) error {
+	err := c.put(scope, objID, payload, nil, nil)
+	c.record("Put", scope, objID, err)
+	return err
+}
+
+func (c *Client) PutWithOptions(scope client.Scope, objID string, payload []byte, opts *client.PutOptions) error {
+	err := c.put(scope, objID, payload, opts, nil)
+	c.record("PutWithOptions", scope, objID, err)
+	return err
+}
+
+func (c *Client) GetWithInfo(scope client.Scope, objID string) ([]byte, *client.ObjectInfo, error) {
+	b, info, err := c.get(scope, objID)
+	c.record("GetWithInfo", scope, objID, err)
+	return b, info, err
+}
+
+func (c *Client) Del(scope client.Scope, objID string) error {
+	err := c.begin("Del", objID)
+	if err == nil {
+		var key string
+		key, err = c.objectKey(scope, objID)
+		if err == nil {
+			c.store.mu.Lock()
+			delete(c.store.objects, key)
+			c.recordChange(scope, client.ChangeDelete, objID, "")
+			c.store.mu.Unlock()
+		}
+	}
+	c.record("Del", scope, objID, err)
+	return err
+}
+
+func (c *Client) GetVersioned(scope client.Scope, objID string) ([]byte, string, error) {
+	b, info, err := c.get(scope, objID)
+	c.record("GetVersioned", scope, objID, err)
+	if err != nil {
+		return nil, "", err
+	}
+	return b, info.ETag, nil
+}
+
+func (c *Client) PutIfVersion(scope client.Scope, objID string, payload []byte, version string) error {
+	err := c.put(scope, objID, payload, nil, func(obj *object) error {
+		current := ""
+		if obj != nil {
+			current = obj.version
+		}
+		if current != version {
+			return client.ErrVersionMismatch
+		}
+		return nil
+	})
+	c.record("PutIfVersion", scope, objID, err)
+	return err
+}
+
+func (c *Client) GetIfNoneMatch(scope client.Scope, objID string, etag string) ([]byte, string, error) {
+	b, info, err := c.get(scope, objID)
+	if err == nil && etag != "" && info.ETag == etag {
+		b, err = nil, client.ErrNotModified
+	}
+	c.record("GetIfNoneMatch", scope, objID, err)
+	if err != nil {
+		return nil, "", err
+	}
+	return b, info.ETag, nil
+}
+
+func (c *Client) Stat(scope client.Scope, objID string) (*client.ObjectInfo, error) {
+	info, err := c.stat(scope, objID)
+	c.record("Stat", scope, objID, err)
+	return info, err
+}
+
+func (c *Client) stat(scope client.Scope, objID string) (*client.ObjectInfo, error) {
+	if err := c.begin("Stat", objID); err != nil {
+		return nil, err
+	}
+	key, err := c.objectKey(scope, objID)
+	if err != nil {
+		return nil, err
+	}
+	c.store.mu.Lock()
+	defer c.store.mu.Unlock()
+	obj, ok := c.store.objects[key]
+	if !ok {
+		return nil, client.ErrNotFound
+	}
+	return obj.info(), nil
+}
+
+func (c *Client) Exists(scope client.Scope, objID string) (bool, error) {
+	_, err := c.stat(scope, objID)
+	exists := err == nil
+	if errors.Is(err, client.ErrNotFound) {
+		err = nil
+	}
+	c.record("Exists", scope, objID, err)
+	return exists, err
+}
+
+func (c *Client) GetRange(scope client.Scope, objID string, offset, length int64) ([]byte, error) {
+	b, _, err := c.get(scope, objID)
+	if err == nil {
+		if offset < 0 || offset >= int64(len(b)) {
+			b, err = nil, &client.StatusError{StatusCode: 416}
+		} else {
+			b = b[offset:]
+			if length > 0 && length < int64(len(b)) {
+				b = b[:length]
+			}
+		}
+	}
+	c.record("GetRange", scope, objID, err)
+	return b, err
+}
+
+// BatchGet records one call per key, like the server applies one operation per key
+func (c *Client) BatchGet(scope client.Scope, objIDs []string) (map[string][]byte, error) {
+	objects := make(map[string][]byte)
+	errs := make(map[string]error)
+	for _, objID := range objIDs {
+		b, err := c.Get(scope, objID)
+		if errors.Is(err, client.ErrNotFound) {
+			continue
+		}
+		if err != nil {
+			errs[objID] = err
+			continue
+		}
+		objects[objID] = b
+	}
+	if len(errs) > 0 {
+		return objects, &client.BatchError{Errors: errs}
+	}
+	return objects, nil
+}
+
+func (c *Client) BatchPut(scope client.Scope, objects map[string][]byte) error {
+	errs := make(map[string]error)
+	for objID, payload := range objects {
+		if err := c.Put(scope, objID, payload); err != nil {
+			errs[objID] = err
+		}
+	}
+	if len(errs) > 0 {
+		return &client.BatchError{Errors: errs}
+	}
+	return nil
+}
+
+func (c *Client) BatchDel(scope client.Scope, objIDs []string) error {
+	errs := make(map[string]error)
+	for _, objID := range objIDs {
+		if err := c.Del(scope, objID); err != nil {
+			errs[objID] = err
+		}
+	}
+	if len(errs) > 0 {
+		return &client.BatchError{Errors: errs}
+	}
+	return nil
+}
+
+// copy mirrors the server's copy: content type and metadata go with the object, which gets a new version
+func (c *Client) copy(method string, srcScope client.Scope, srcID string, dstScope client.Scope, dstID string) error {
+	if err := c.begin(method, srcID); err != nil {
+		return err
+	}
+	src, err := c.objectKey(srcScope, srcID)
+	if err != nil {
+		return err
+	}
+	dst, err := c.objectKey(dstScope, dstID)
+	if err != nil {
+		return err
+	}
+	if src == dst {
+		return client.ErrSameObject
+	}
+	c.store.mu.Lock()
+	defer c.store.mu.Unlock()
+	obj, ok := c.store.objects[src]
+	if !ok {
+		return client.ErrNotFound
+	}
+	cp := *obj
+	cp.version = c.store.nextVersion()
+	cp.modified = time.Now()
+	c.store.objects[dst] = &cp
+	c.recordChange(dstScope, client.ChangePut, dstID, cp.version)
+	if method == "Move" {
+		delete(c.store.objects, src)
+		c.recordChange(srcScope, client.ChangeDelete, srcID, "")
+	}
+	return nil
+}
+
+func (c *Client) Copy(srcScope client.Scope, srcID string, dstScope client.Scope, dstID string) error {
+	err := c.copy("Copy", srcScope, srcID, dstScope, dstID)
+	c.record("Copy", srcScope, srcID, err)
+	return err
+}
+
+func (c *Client) Move(srcScope client.Scope, srcID string, dstScope client.Scope, dstID string) error {
+	err := c.copy("Move", srcScope, srcID, dstScope, dstID)
+	c.record("Move", srcScope, srcID, err)
+	return err
+}
+
+// DelPrefix records the prefix as the call's ObjID
+// scannerDir matches the scanner directory that ScopeScanner objects are nested under in the bot's keys;
+// unlike on the server, scanner ids of any length match, so short test ids work
+var scannerDir = regexp.MustCompile(`^0x[0-9a-fA-F]+/`)
+
+// DelPrefix skips ScopeScanner objects when deleting from ScopeBot, like the server
+func (c *Client) DelPrefix(scope client.Scope, prefix string) (int, error) {
+	deleted := 0
+	err := c.begin("DelPrefix", prefix)
+	if err == nil {
+		var keyPrefix string
+		keyPrefix, err = c.objectKey(scope, prefix)
+		if err == nil {
+			c.store.mu.Lock()
+			for key := range c.store.objects {
+				if !strings.HasPrefix(key, keyPrefix) {
+					continue
+				}
+				if scope == client.ScopeBot && scannerDir.MatchString(strings.TrimPrefix(key, c.id.BotID+"/")) {
+					continue
+				}
+				delete(c.store.objects, key)
+				deleted++
+			}
+			if deleted > 0 {
+				c.recordChange(scope, client.ChangeDelPrefix, prefix, "")
+			}
+			c.store.mu.Unlock()
+		}
+	}
+	c.record("DelPrefix", scope, prefix, err)
+	return deleted, err
+}
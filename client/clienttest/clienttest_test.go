@@ -0,0 +1,186 @@
+package clienttest
+
+import (
+	"errors"
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+
+	"forta-bot-db/client"
+)
+
+var (
+	scanner1 = Identity{BotID: "0xB1", Scanner: "0xA1", Owner: "0xC1"}
+	scanner2 = Identity{BotID: "0xb1", Scanner: "0xa2", Owner: "0xc1"}
+	sibling  = Identity{BotID: "0xb2", Scanner: "0xa1", Owner: "0xc1"}
+	stranger = Identity{BotID: "0xb3", Scanner: "0xa3", Owner: "0xc2"}
+)
+
+func mustPut(t *testing.T, c client.Client, scope client.Scope, objID, payload string) {
+	t.Helper()
+	if err := c.Put(scope, objID, []byte(payload)); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func assertGet(t *testing.T, c client.Client, scope client.Scope, objID, want string) {
+	t.Helper()
+	b, err := c.Get(scope, objID)
+	if want == "" {
+		if !errors.Is(err, client.ErrNotFound) {
+			t.Fatalf("%s/%s: got %q, %v, want ErrNotFound", scope, objID, b, err)
+		}
+		return
+	}
+	if err != nil || string(b) != want {
+		t.Fatalf("%s/%s: got %q, %v, want %q", scope, objID, b, err, want)
+	}
+}
+
+func TestScopes(t *testing.T) {
+	s := NewStore()
+	a, b, c, d := s.Client(scanner1), s.Client(scanner2), s.Client(sibling), s.Client(stranger)
+
+	mustPut(t, a, client.ScopeScanner, "state", "scanner")
+	mustPut(t, a, client.ScopeBot, "state", "bot")
+	mustPut(t, a, client.ScopeOwner, "state", "owner")
+
+	// the scanner scope is private to one bot on one scanner
+	assertGet(t, b, client.ScopeScanner, "state", "")
+	assertGet(t, c, client.ScopeScanner, "state", "")
+	// the bot scope is shared by the bot's scanners, whatever the case of the ids
+	assertGet(t, b, client.ScopeBot, "state", "bot")
+	assertGet(t, c, client.ScopeBot, "state", "")
+	// the owner scope is shared by the owner's bots
+	assertGet(t, c, client.ScopeOwner, "state", "owner")
+	assertGet(t, d, client.ScopeOwner, "state", "")
+
+	// keys are laid out like on the server
+	keys := s.Keys()
+	sort.Strings(keys)
+	want := []string{"0xb1/0xa1/state", "0xb1/state", "owner/0xc1/state"}
+	if !reflect.DeepEqual(keys, want) {
+		t.Fatalf("keys %q, want %q", keys, want)
+	}
+
+	// deleting from the bot scope leaves the scanner objects nested in it alone
+	if _, err := a.DelPrefix(client.ScopeBot, ""); err != nil {
+		t.Fatal(err)
+	}
+	assertGet(t, a, client.ScopeScanner, "state", "scanner")
+	assertGet(t, a, client.ScopeBot, "state", "")
+}
+
+func TestSharing(t *testing.T) {
+	s := NewStore()
+	a, c, d := s.Client(scanner1), s.Client(sibling), s.Client(stranger)
+	mustPut(t, a, client.ScopeBot, "labels/latest", "labels")
+	mustPut(t, a, client.ScopeBot, "secrets", "secret")
+
+	// nothing is shared without a grant
+	if _, err := d.Shared(client.ScopeBot, scanner1.BotID).Get("labels/latest"); !errors.Is(err, client.ErrForbidden) {
+		t.Fatalf("got %v, want ErrForbidden", err)
+	}
+
+	// a read grant to another owner's bot covers its prefix only
+	if err := a.ACL().Grant(client.ScopeBot, client.BotGrantee(stranger.BotID), "labels/", client.AccessRead); err != nil {
+		t.Fatal(err)
+	}
+	shared := d.Shared(client.ScopeBot, "0XB1")
+	if b, err := shared.Get("labels/latest"); err != nil || string(b) != "labels" {
+		t.Fatalf("got %q, %v", b, err)
+	}
+	if _, err := shared.Get("secrets"); !errors.Is(err, client.ErrForbidden) {
+		t.Fatalf("outside the prefix: got %v, want ErrForbidden", err)
+	}
+	if err := shared.Put("labels/latest", []byte("x")); !errors.Is(err, client.ErrForbidden) {
+		t.Fatalf("write with a read grant: got %v, want ErrForbidden", err)
+	}
+
+	// an owner grant covers every bot of the owner, and read-write allows writes
+	if err := a.ACL().Grant(client.ScopeBot, client.OwnerGrantee(sibling.Owner), "", client.AccessReadWrite); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Shared(client.ScopeBot, scanner1.BotID).Put("labels/latest", []byte("updated")); err != nil {
+		t.Fatal(err)
+	}
+	assertGet(t, a, client.ScopeBot, "labels/latest", "updated")
+
+	// revoking takes the access away
+	if err := a.ACL().Revoke(client.ScopeBot, client.BotGrantee(stranger.BotID), "labels/"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := shared.Get("labels/latest"); !errors.Is(err, client.ErrForbidden) {
+		t.Fatalf("after revoking: got %v, want ErrForbidden", err)
+	}
+
+	// the public scope is readable by every bot but only written by its publisher
+	mustPut(t, a, client.ScopePublic, "feed", "public")
+	public := d.Shared(client.ScopePublic, scanner1.BotID)
+	if b, err := public.Get("feed"); err != nil || string(b) != "public" {
+		t.Fatalf("got %q, %v", b, err)
+	}
+	if err := public.Put("feed", []byte("x")); !errors.Is(err, client.ErrForbidden) {
+		t.Fatalf("got %v, want ErrForbidden", err)
+	}
+
+	// the scanner scope is never shared
+	if _, err := d.Shared(client.ScopeScanner, scanner1.BotID).Get("state"); !errors.Is(err, client.ErrScopeNotShared) {
+		t.Fatalf("got %v, want ErrScopeNotShared", err)
+	}
+}
+
+func TestFaults(t *testing.T) {
+	c := NewStore().Client(scanner1)
+	mustPut(t, c, client.ScopeBot, "a", "1")
+	mustPut(t, c, client.ScopeBot, "b", "2")
+
+	c.InjectFault(Fault{Method: "Get", ObjID: "a", Err: ErrUnavailable, Times: 1})
+	if _, err := c.Get(client.ScopeBot, "a"); !errors.Is(err, ErrUnavailable) {
+		t.Fatalf("got %v, want ErrUnavailable", err)
+	}
+	// the fault was used up, and it never matched other keys
+	assertGet(t, c, client.ScopeBot, "a", "1")
+	assertGet(t, c, client.ScopeBot, "b", "2")
+
+	// an empty Method matches every call, until the faults are cleared
+	c.InjectFault(Fault{Err: ErrUnavailable})
+	if err := c.Put(client.ScopeBot, "a", []byte("x")); !errors.Is(err, ErrUnavailable) {
+		t.Fatalf("got %v, want ErrUnavailable", err)
+	}
+	if _, err := c.KV().Get(client.ScopeBot, "counter"); !errors.Is(err, ErrUnavailable) {
+		t.Fatalf("got %v, want ErrUnavailable", err)
+	}
+	c.ClearFaults()
+	assertGet(t, c, client.ScopeBot, "a", "1")
+
+	c.InjectFault(Fault{Method: "Stat", Latency: 20 * time.Millisecond})
+	start := time.Now()
+	if _, err := c.Stat(client.ScopeBot, "a"); err != nil {
+		t.Fatal(err)
+	}
+	if time.Since(start) < 20*time.Millisecond {
+		t.Fatal("the latency was not applied")
+	}
+}
+
+func TestCalls(t *testing.T) {
+	c := NewStore().Client(scanner1)
+	mustPut(t, c, client.ScopeBot, "a", "1")
+	c.Get(client.ScopeScanner, "missing")
+	c.Del(client.ScopeOwner, "a")
+
+	want := []Call{
+		{Method: "Put", Scope: client.ScopeBot, ObjID: "a"},
+		{Method: "Get", Scope: client.ScopeScanner, ObjID: "missing", Err: client.ErrNotFound},
+		{Method: "Del", Scope: client.ScopeOwner, ObjID: "a"},
+	}
+	if calls := c.Calls(); !reflect.DeepEqual(calls, want) {
+		t.Fatalf("calls %+v, want %+v", calls, want)
+	}
+	c.ResetCalls()
+	if calls := c.Calls(); len(calls) != 0 {
+		t.Fatalf("calls %+v after reset", calls)
+	}
+}
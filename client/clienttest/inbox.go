@@ -0,0 +1,103 @@
+package clienttest
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"forta-bot-db/client"
+)
+
+type inbox struct {
+	c *Client
+}
+
+var _ client.Inbox = (*inbox)(nil)
+var _ client.InboxClient = (*Client)(nil)
+
+// Inbox returns the fake's messaging API.  Messages never expire, and bots of the same store can message each other.
+func (c *Client) Inbox() client.Inbox {
+	return &inbox{c: c}
+}
+
+// Send records the target bot as the call's ObjID
+func (i *inbox) Send(botID string, body []byte) (string, error) {
+	id, err := i.send(botID, body)
+	i.c.record("InboxSend", "", botID, err)
+	return id, err
+}
+
+func (i *inbox) send(botID string, body []byte) (string, error) {
+	if len(body) > client.MaxKVValueSize {
+		return "", client.ErrValueTooLarge
+	}
+	if err := i.c.begin("InboxSend", botID); err != nil {
+		return "", err
+	}
+	i.c.store.mu.Lock()
+	defer i.c.store.mu.Unlock()
+	botID = strings.ToLower(botID)
+	t := time.Now()
+	// ids sort in the order messages were sent, like the server's
+	id := fmt.Sprintf("%013d%s", t.UnixMilli(), i.c.store.nextVersion())
+	i.c.store.inboxes[botID] = append(i.c.store.inboxes[botID], client.Message{
+		ID:      id,
+		From:    i.c.id.BotID,
+		Scanner: i.c.id.Scanner,
+		Body:    append([]byte(nil), body...),
+		SentAt:  t,
+	})
+	return id, nil
+}
+
+// List records the after id as the call's ObjID
+func (i *inbox) List(after string, limit int) ([]client.Message, string, error) {
+	msgs, next, err := i.list(after, limit)
+	i.c.record("InboxList", "", after, err)
+	return msgs, next, err
+}
+
+func (i *inbox) list(after string, limit int) ([]client.Message, string, error) {
+	if err := i.c.begin("InboxList", after); err != nil {
+		return nil, "", err
+	}
+	if limit <= 0 {
+		limit = defaultReadLimit
+	}
+	i.c.store.mu.Lock()
+	defer i.c.store.mu.Unlock()
+	msgs := []client.Message{}
+	for _, msg := range i.c.store.inboxes[i.c.id.BotID] {
+		if msg.ID <= after {
+			continue
+		}
+		if len(msgs) == limit {
+			return msgs, msgs[len(msgs)-1].ID, nil
+		}
+		msg.Body = append([]byte(nil), msg.Body...)
+		msgs = append(msgs, msg)
+	}
+	return msgs, "", nil
+}
+
+func (i *inbox) Ack(id string) error {
+	err := i.ack(id)
+	i.c.record("InboxAck", "", id, err)
+	return err
+}
+
+func (i *inbox) ack(id string) error {
+	if err := i.c.begin("InboxAck", id); err != nil {
+		return err
+	}
+	i.c.store.mu.Lock()
+	defer i.c.store.mu.Unlock()
+	msgs := i.c.store.inboxes[i.c.id.BotID]
+	for j, msg := range msgs {
+		if msg.ID == id {
+			i.c.store.inboxes[i.c.id.BotID] = append(msgs[:j:j], msgs[j+1:]...)
+			break
+		}
+	}
+	return nil
+}
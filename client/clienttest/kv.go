@@ -0,0 +1,163 @@
+package clienttest
+
+import (
+	"bytes"
+	"strconv"
+
+	"forta-bot-db/client"
+)
+
+// item is a key-value entry; items live apart from objects, like on the server
+type item struct {
+	value   []byte
+	counter *int64
+	version int64
+}
+
+type kv struct {
+	c *Client
+}
+
+var _ client.KV = (*kv)(nil)
+var _ client.KVClient = (*Client)(nil)
+
+// KV returns the fake's key-value API, which shares faults and call recording with the client
+func (c *Client) KV() client.KV {
+	return &kv{c: c}
+}
+
+func (k *kv) Get(scope client.Scope, key string) ([]byte, error) {
+	b, err := k.get(scope, key)
+	k.c.record("KVGet", scope, key, err)
+	return b, err
+}
+
+func (k *kv) get(scope client.Scope, key string) ([]byte, error) {
+	if err := k.c.begin("KVGet", key); err != nil {
+		return nil, err
+	}
+	itemKey, err := k.c.objectKey(scope, key)
+	if err != nil {
+		return nil, err
+	}
+	k.c.store.mu.Lock()
+	defer k.c.store.mu.Unlock()
+	it, ok := k.c.store.items[itemKey]
+	if !ok {
+		return nil, client.ErrNotFound
+	}
+	if it.counter != nil {
+		return []byte(strconv.FormatInt(*it.counter, 10)), nil
+	}
+	return append([]byte(nil), it.value...), nil
+}
+
+func (k *kv) Put(scope client.Scope, key string, value []byte) error {
+	err := k.put(scope, key, value)
+	k.c.record("KVPut", scope, key, err)
+	return err
+}
+
+func (k *kv) put(scope client.Scope, key string, value []byte) error {
+	if len(value) > client.MaxKVValueSize {
+		return client.ErrValueTooLarge
+	}
+	if err := k.c.begin("KVPut", key); err != nil {
+		return err
+	}
+	itemKey, err := k.c.objectKey(scope, key)
+	if err != nil {
+		return err
+	}
+	k.c.store.mu.Lock()
+	defer k.c.store.mu.Unlock()
+	it, ok := k.c.store.items[itemKey]
+	if !ok {
+		it = &item{}
+		k.c.store.items[itemKey] = it
+	}
+	it.value = append([]byte(nil), value...)
+	it.counter = nil
+	it.version++
+	return nil
+}
+
+func (k *kv) Del(scope client.Scope, key string) error {
+	err := k.c.begin("KVDel", key)
+	if err == nil {
+		var itemKey string
+		itemKey, err = k.c.objectKey(scope, key)
+		if err == nil {
+			k.c.store.mu.Lock()
+			delete(k.c.store.items, itemKey)
+			k.c.store.mu.Unlock()
+		}
+	}
+	k.c.record("KVDel", scope, key, err)
+	return err
+}
+
+func (k *kv) Incr(scope client.Scope, key string, by int64) (int64, error) {
+	n, err := k.incr(scope, key, by)
+	k.c.record("KVIncr", scope, key, err)
+	return n, err
+}
+
+func (k *kv) incr(scope client.Scope, key string, by int64) (int64, error) {
+	if err := k.c.begin("KVPut", key); err != nil {
+		return 0, err
+	}
+	itemKey, err := k.c.objectKey(scope, key)
+	if err != nil {
+		return 0, err
+	}
+	k.c.store.mu.Lock()
+	defer k.c.store.mu.Unlock()
+	it, ok := k.c.store.items[itemKey]
+	if !ok {
+		it = &item{counter: new(int64)}
+		k.c.store.items[itemKey] = it
+	}
+	if it.counter == nil {
+		return 0, client.ErrNotCounter
+	}
+	*it.counter += by
+	it.version++
+	return *it.counter, nil
+}
+
+func (k *kv) CompareAndSwap(scope client.Scope, key string, old, new []byte) (bool, error) {
+	ok, err := k.cas(scope, key, old, new)
+	k.c.record("KVCompareAndSwap", scope, key, err)
+	return ok, err
+}
+
+func (k *kv) cas(scope client.Scope, key string, old, new []byte) (bool, error) {
+	if len(new) > client.MaxKVValueSize {
+		return false, client.ErrValueTooLarge
+	}
+	if err := k.c.begin("KVPut", key); err != nil {
+		return false, err
+	}
+	itemKey, err := k.c.objectKey(scope, key)
+	if err != nil {
+		return false, err
+	}
+	k.c.store.mu.Lock()
+	defer k.c.store.mu.Unlock()
+	it, ok := k.c.store.items[itemKey]
+	switch {
+	case old == nil && ok:
+		return false, nil
+	case old != nil && (!ok || it.counter != nil || !bytes.Equal(it.value, old)):
+		return false, nil
+	}
+	if !ok {
+		it = &item{}
+		k.c.store.items[itemKey] = it
+	}
+	it.value = append([]byte(nil), new...)
+	it.counter = nil
+	it.version++
+	return true, nil
+}
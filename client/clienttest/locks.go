@@ -0,0 +1,114 @@
+package clienttest
+
+import (
+	"time"
+
+	"forta-bot-db/client"
+)
+
+// lock is a lease; like on the server it is never deleted, so tokens keep increasing
+type lock struct {
+	holder    string
+	token     int64
+	expiresAt time.Time
+}
+
+type locks struct {
+	c *Client
+}
+
+var _ client.Locks = (*locks)(nil)
+var _ client.LockClient = (*Client)(nil)
+
+// Locks returns the fake's lock API.  The holder is the client's scanner, so clients of the same bot on
+// different scanners compete for ScopeBot locks.
+func (c *Client) Locks() client.Locks {
+	return &locks{c: c}
+}
+
+func (l *locks) grant(lk *lock) *client.LockGrant {
+	return &client.LockGrant{Holder: lk.holder, Token: lk.token, ExpiresAt: lk.expiresAt}
+}
+
+// update runs fn on the lock under the store lock, creating the lock if it does not exist
+func (l *locks) update(method string, scope client.Scope, name string, fn func(lk *lock, now time.Time) error) (*client.LockGrant, error) {
+	if err := l.c.begin(method, name); err != nil {
+		return nil, err
+	}
+	key, err := l.c.objectKey(scope, name)
+	if err != nil {
+		return nil, err
+	}
+	l.c.store.mu.Lock()
+	defer l.c.store.mu.Unlock()
+	lk, ok := l.c.store.locks[key]
+	if !ok {
+		lk = &lock{}
+		l.c.store.locks[key] = lk
+	}
+	if err := fn(lk, time.Now()); err != nil {
+		return nil, err
+	}
+	return l.grant(lk), nil
+}
+
+func (l *locks) Acquire(scope client.Scope, name string, ttl time.Duration) (*client.LockGrant, error) {
+	g, err := l.update("LockAcquire", scope, name, func(lk *lock, now time.Time) error {
+		if lk.holder != "" && lk.holder != l.c.id.Scanner && now.Before(lk.expiresAt) {
+			return client.ErrLockHeld
+		}
+		lk.holder = l.c.id.Scanner
+		lk.token++
+		lk.expiresAt = now.Add(ttl)
+		return nil
+	})
+	l.c.record("LockAcquire", scope, name, err)
+	return g, err
+}
+
+func (l *locks) Renew(scope client.Scope, name string, token int64, ttl time.Duration) (*client.LockGrant, error) {
+	g, err := l.update("LockRenew", scope, name, func(lk *lock, now time.Time) error {
+		if lk.holder != l.c.id.Scanner || lk.token != token || !now.Before(lk.expiresAt) {
+			return client.ErrLeaseLost
+		}
+		lk.expiresAt = now.Add(ttl)
+		return nil
+	})
+	l.c.record("LockRenew", scope, name, err)
+	return g, err
+}
+
+func (l *locks) Release(scope client.Scope, name string, token int64) error {
+	_, err := l.update("LockRelease", scope, name, func(lk *lock, now time.Time) error {
+		if lk.holder != l.c.id.Scanner || lk.token != token {
+			return client.ErrLeaseLost
+		}
+		lk.expiresAt = time.Time{}
+		return nil
+	})
+	l.c.record("LockRelease", scope, name, err)
+	return err
+}
+
+func (l *locks) Holder(scope client.Scope, name string) (*client.LockGrant, error) {
+	g, err := l.holder(scope, name)
+	l.c.record("LockHolder", scope, name, err)
+	return g, err
+}
+
+func (l *locks) holder(scope client.Scope, name string) (*client.LockGrant, error) {
+	if err := l.c.begin("LockHolder", name); err != nil {
+		return nil, err
+	}
+	key, err := l.c.objectKey(scope, name)
+	if err != nil {
+		return nil, err
+	}
+	l.c.store.mu.Lock()
+	defer l.c.store.mu.Unlock()
+	lk, ok := l.c.store.locks[key]
+	if !ok || !time.Now().Before(lk.expiresAt) {
+		return nil, client.ErrNotFound
+	}
+	return l.grant(lk), nil
+}
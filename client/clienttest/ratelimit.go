@@ -0,0 +1,74 @@
+package clienttest
+
+import (
+	"math"
+	"time"
+
+	"forta-bot-db/client"
+)
+
+// bucket is a token bucket as of updated; like on the server, a new bucket is full, keeps the limit it was created
+// with and is forgotten once it is full again
+type bucket struct {
+	tokens  float64
+	updated time.Time
+	rate    float64
+	burst   int
+}
+
+type rateLimits struct {
+	c *Client
+}
+
+var _ client.RateLimits = (*rateLimits)(nil)
+var _ client.RateLimitClient = (*Client)(nil)
+
+// RateLimits returns the fake's rate limit API; clients that see the same scope share buckets
+func (c *Client) RateLimits() client.RateLimits {
+	return &rateLimits{c: c}
+}
+
+func (r *rateLimits) Take(scope client.Scope, name string, n int, limit client.RateLimit) (time.Duration, error) {
+	wait, err := r.take(scope, name, n, limit)
+	r.c.record("RateLimitTake", scope, name, err)
+	return wait, err
+}
+
+func (r *rateLimits) take(scope client.Scope, name string, n int, limit client.RateLimit) (time.Duration, error) {
+	if err := r.c.begin("RateLimitTake", name); err != nil {
+		return 0, err
+	}
+	burst := limit.Burst
+	if burst == 0 {
+		burst = int(math.Ceil(limit.Rate))
+	}
+	if !(limit.Rate >= client.MinRate) || n < 1 || n > burst {
+		return 0, &client.StatusError{StatusCode: 400}
+	}
+	key, err := r.c.objectKey(scope, name)
+	if err != nil {
+		return 0, err
+	}
+	r.c.store.mu.Lock()
+	defer r.c.store.mu.Unlock()
+	now := time.Now()
+	b, ok := r.c.store.buckets[key]
+	if ok && b.tokens+now.Sub(b.updated).Seconds()*b.rate >= float64(b.burst) {
+		delete(r.c.store.buckets, key)
+		ok = false
+	}
+	if !ok {
+		b = &bucket{tokens: float64(burst), updated: now, rate: limit.Rate, burst: burst}
+		r.c.store.buckets[key] = b
+	}
+	if b.rate != limit.Rate || b.burst != burst {
+		return 0, client.ErrLimitMismatch
+	}
+	b.tokens = math.Min(float64(burst), b.tokens+now.Sub(b.updated).Seconds()*limit.Rate)
+	b.updated = now
+	if b.tokens < float64(n) {
+		return time.Duration(math.Ceil((float64(n)-b.tokens)/limit.Rate*1000)) * time.Millisecond, nil
+	}
+	b.tokens -= float64(n)
+	return 0, nil
+}
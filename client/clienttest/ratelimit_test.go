@@ -0,0 +1,68 @@
+package clienttest
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"forta-bot-db/client"
+)
+
+func TestRateLimitBucket(t *testing.T) {
+	s := NewStore()
+	a := s.Client(Identity{BotID: "0xbot", Scanner: "0xscanner1", Owner: "0xowner"})
+	b := s.Client(Identity{BotID: "0xother", Scanner: "0xscanner2", Owner: "0xowner"})
+	limit := client.RateLimit{Rate: 1, Burst: 2}
+
+	// a new bucket is full, and the owner's bots share it
+	for _, c := range []*Client{a, b} {
+		wait, err := c.RateLimits().Take(client.ScopeOwner, "api", 1, limit)
+		if err != nil || wait != 0 {
+			t.Fatalf("take from a full bucket: wait %v, err %v", wait, err)
+		}
+	}
+	wait, err := a.RateLimits().Take(client.ScopeOwner, "api", 1, limit)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if wait <= 0 || wait > time.Second {
+		t.Fatalf("empty bucket: wait %v, want up to 1s", wait)
+	}
+
+	// another bot's bot scope has its own bucket
+	if wait, err := b.RateLimits().Take(client.ScopeBot, "api", 2, limit); err != nil || wait != 0 {
+		t.Fatalf("take from another bucket: wait %v, err %v", wait, err)
+	}
+
+	// the bucket keeps the limit it was created with
+	if _, err := b.RateLimits().Take(client.ScopeOwner, "api", 1, client.RateLimit{Rate: 100, Burst: 2}); !errors.Is(err, client.ErrLimitMismatch) {
+		t.Fatalf("got %v, want ErrLimitMismatch", err)
+	}
+
+	// invalid limits are rejected like on the server
+	for _, l := range []client.RateLimit{{Rate: 0}, {Rate: client.MinRate / 2}, {Rate: 1, Burst: 0}} {
+		n := 1
+		if l.Rate == 1 {
+			n = 2
+		}
+		var se *client.StatusError
+		if _, err := a.RateLimits().Take(client.ScopeBot, "invalid", n, l); !errors.As(err, &se) || se.StatusCode != 400 {
+			t.Fatalf("limit %+v: got %v, want 400", l, err)
+		}
+	}
+}
+
+func TestRateLimitBucketExpiresOnceFull(t *testing.T) {
+	s := NewStore()
+	c := s.Client(Identity{BotID: "0xbot", Scanner: "0xscanner", Owner: "0xowner"})
+	fast := client.RateLimit{Rate: 1000, Burst: 1}
+	if _, err := c.RateLimits().Take(client.ScopeBot, "api", 1, fast); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(5 * time.Millisecond)
+
+	// once full again the bucket is gone, so a new limit can take its place
+	if wait, err := c.RateLimits().Take(client.ScopeBot, "api", 1, client.RateLimit{Rate: 1}); err != nil || wait != 0 {
+		t.Fatalf("wait %v, err %v", wait, err)
+	}
+}
@@ -0,0 +1,84 @@
+package clienttest
+
+import (
+	"time"
+
+	"forta-bot-db/client"
+)
+
+// defaultReadLimit matches the server's page size when Read is called without a limit
+const defaultReadLimit = 100
+
+type streams struct {
+	c *Client
+}
+
+var _ client.Streams = (*streams)(nil)
+var _ client.StreamClient = (*Client)(nil)
+
+// Streams returns the fake's stream API, which shares faults and call recording with the client
+func (c *Client) Streams() client.Streams {
+	return &streams{c: c}
+}
+
+func (s *streams) Append(scope client.Scope, name string, value []byte) (int64, error) {
+	seq, err := s.append(scope, name, value)
+	s.c.record("StreamAppend", scope, name, err)
+	return seq, err
+}
+
+func (s *streams) append(scope client.Scope, name string, value []byte) (int64, error) {
+	if len(value) > client.MaxKVValueSize {
+		return 0, client.ErrValueTooLarge
+	}
+	if err := s.c.begin("StreamAppend", name); err != nil {
+		return 0, err
+	}
+	key, err := s.c.objectKey(scope, name)
+	if err != nil {
+		return 0, err
+	}
+	s.c.store.mu.Lock()
+	defer s.c.store.mu.Unlock()
+	seq := int64(len(s.c.store.streams[key]) + 1)
+	s.c.store.streams[key] = append(s.c.store.streams[key], client.StreamRecord{
+		Seq:   seq,
+		Value: append([]byte(nil), value...),
+		Time:  time.Now(),
+	})
+	return seq, nil
+}
+
+func (s *streams) Read(scope client.Scope, name string, from int64, limit int) ([]client.StreamRecord, int64, error) {
+	recs, next, err := s.read(scope, name, from, limit)
+	s.c.record("StreamRead", scope, name, err)
+	return recs, next, err
+}
+
+func (s *streams) read(scope client.Scope, name string, from int64, limit int) ([]client.StreamRecord, int64, error) {
+	if err := s.c.begin("StreamRead", name); err != nil {
+		return nil, from, err
+	}
+	key, err := s.c.objectKey(scope, name)
+	if err != nil {
+		return nil, from, err
+	}
+	if limit <= 0 {
+		limit = defaultReadLimit
+	}
+	if from < 1 {
+		from = 1
+	}
+	s.c.store.mu.Lock()
+	defer s.c.store.mu.Unlock()
+	stream := s.c.store.streams[key]
+	next := from
+	var recs []client.StreamRecord
+	for i := from - 1; i < int64(len(stream)) && len(recs) < limit; i++ {
+		rec := stream[i]
+		rec.Value = append([]byte(nil), rec.Value...)
+		recs = append(recs, rec)
+		next = rec.Seq + 1
+	}
+	return recs, next, nil
+}
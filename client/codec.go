@@ -0,0 +1,175 @@
+package client
+
+import (
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/klauspost/compress/snappy"
+	"github.com/klauspost/compress/zstd"
+)
+
+// Codec compresses payloads before they are stored.  Name is recorded as the object's encoding (X-Object-Encoding).
+type Codec interface {
+	Name() string
+	Encode(b []byte) ([]byte, error)
+	Decode(b []byte) ([]byte, error)
+}
+
+const (
+	CodecNone   = "identity"
+	CodecGzip   = "gzip"
+	CodecZstd   = "zstd"
+	CodecSnappy = "snappy"
+)
+
+// encodingHeader carries the codec name.  It is not Content-Encoding, which HTTP clients undo on their own, so
+// readers that decode the payload themselves would decode it twice.
+const encodingHeader = "X-Object-Encoding"
+
+// MaxDecodedSize bounds how large a payload the built-in codecs will decompress, guarding against compression bombs
+const MaxDecodedSize = 256 << 20
+
+// ErrDecodedTooLarge is returned when a payload decompresses to more than MaxDecodedSize
+var ErrDecodedTooLarge = errors.New("decoded payload is too large")
+
+var codecsMu sync.RWMutex
+
+var codecs = map[string]Codec{
+	CodecNone:   noneCodec{},
+	CodecGzip:   gzipCodec{},
+	CodecZstd:   zstdCodec{},
+	CodecSnappy: snappyCodec{},
+}
+
+// codecSuffixes selects a codec from the object key, which takes precedence over the client default
+var codecSuffixes = map[string]string{
+	".gz":  CodecGzip,
+	".zst": CodecZstd,
+	".sz":  CodecSnappy,
+}
+
+// RegisterCodec adds (or replaces) a codec so it can be selected by name
+func RegisterCodec(c Codec) {
+	codecsMu.Lock()
+	defer codecsMu.Unlock()
+	codecs[c.Name()] = c
+}
+
+func getCodec(name string) (Codec, error) {
+	codecsMu.RLock()
+	defer codecsMu.RUnlock()
+	if name == "" {
+		name = CodecNone
+	}
+	c, ok := codecs[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown codec %q", name)
+	}
+	return c, nil
+}
+
+// codecForKey returns the codec implied by the key suffix, or "" if there is none
+func codecForKey(objID string) string {
+	for suffix, name := range codecSuffixes {
+		if strings.HasSuffix(objID, suffix) {
+			return name
+		}
+	}
+	return ""
+}
+
+// acceptEncoding lists every registered codec, so that the http transport does not decode gzip on our behalf
+func acceptEncoding() string {
+	codecsMu.RLock()
+	defer codecsMu.RUnlock()
+	var names []string
+	for name := range codecs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return strings.Join(names, ", ")
+}
+
+type noneCodec struct{}
+
+func (noneCodec) Name() string                    { return CodecNone }
+func (noneCodec) Encode(b []byte) ([]byte, error) { return b, nil }
+func (noneCodec) Decode(b []byte) ([]byte, error) { return b, nil }
+
+type gzipCodec struct{}
+
+func (gzipCodec) Name() string { return CodecGzip }
+
+func (gzipCodec) Encode(b []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	_, err := zw.Write(b)
+	if err != nil {
+		return nil, err
+	}
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (gzipCodec) Decode(b []byte) ([]byte, error) {
+	r, err := gzip.NewReader(bytes.NewReader(b))
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	b, err = io.ReadAll(io.LimitReader(r, MaxDecodedSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(b) > MaxDecodedSize {
+		return nil, ErrDecodedTooLarge
+	}
+	return b, nil
+}
+
+type zstdCodec struct{}
+
+// zstd encoders and decoders are safe for concurrent use of EncodeAll/DecodeAll
+var zstdEncoder, _ = zstd.NewWriter(nil)
+var zstdDecoder, _ = zstd.NewReader(nil, zstd.WithDecoderMaxMemory(MaxDecodedSize))
+
+func (zstdCodec) Name() string { return CodecZstd }
+
+func (zstdCodec) Encode(b []byte) ([]byte, error) {
+	return zstdEncoder.EncodeAll(b, nil), nil
+}
+
+func (zstdCodec) Decode(b []byte) ([]byte, error) {
+	out, err := zstdDecoder.DecodeAll(b, nil)
+	if errors.Is(err, zstd.ErrDecoderSizeExceeded) || errors.Is(err, zstd.ErrWindowSizeExceeded) {
+		return nil, ErrDecodedTooLarge
+	}
+	return out, err
+}
+
+type snappyCodec struct{}
+
+func (snappyCodec) Name() string { return CodecSnappy }
+
+func (snappyCodec) Encode(b []byte) ([]byte, error) {
+	return snappy.Encode(nil, b), nil
+}
+
+func (snappyCodec) Decode(b []byte) ([]byte, error) {
+	n, err := snappy.DecodedLen(b)
+	if err != nil {
+		return nil, err
+	}
+	if n > MaxDecodedSize {
+		return nil, ErrDecodedTooLarge
+	}
+	return snappy.Decode(nil, b)
+}
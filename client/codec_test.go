@@ -0,0 +1,107 @@
+package client
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"errors"
+	"testing"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// reverseCodec is a toy codec for testing the registry
+type reverseCodec struct{}
+
+func (reverseCodec) Name() string { return "reverse" }
+
+func (reverseCodec) Encode(b []byte) ([]byte, error) {
+	out := make([]byte, len(b))
+	for i, c := range b {
+		out[len(b)-1-i] = c
+	}
+	return out, nil
+}
+
+func (r reverseCodec) Decode(b []byte) ([]byte, error) {
+	return r.Encode(b)
+}
+
+func TestCodecRoundTrip(t *testing.T) {
+	payload := bytes.Repeat([]byte("forta bot db "), 100)
+	for _, name := range []string{CodecNone, CodecGzip, CodecZstd, CodecSnappy} {
+		codec, err := getCodec(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		b, err := codec.Encode(payload)
+		if err != nil {
+			t.Fatal(name, err)
+		}
+		got, err := codec.Decode(b)
+		if err != nil {
+			t.Fatal(name, err)
+		}
+		if !bytes.Equal(got, payload) {
+			t.Fatalf("%s: payload differs after roundtrip", name)
+		}
+	}
+}
+
+func TestRegisterCodec(t *testing.T) {
+	if _, err := NewClient("http://localhost", "localhost", "1", WithCodec("reverse")); err == nil {
+		t.Fatal("unregistered codec was accepted")
+	}
+	RegisterCodec(reverseCodec{})
+
+	c, s := newTestClient(t, WithCodec("reverse"))
+	if err := c.Put(ScopeBot, "a", []byte("abc")); err != nil {
+		t.Fatal(err)
+	}
+	var obj *testObject
+	for _, o := range s.objects {
+		obj = o
+	}
+	if string(obj.body) != "cba" || obj.header.Get("X-Object-Encoding") != "reverse" || obj.header.Get("Content-Encoding") != "" {
+		t.Fatalf("stored %q with encoding %q", obj.body, obj.header.Get("X-Object-Encoding"))
+	}
+	got, err := c.Get(ScopeBot, "a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != "abc" {
+		t.Fatalf("got %q", got)
+	}
+
+	// the key suffix takes precedence over the client codec
+	if err := c.Put(ScopeBot, "b.zst", []byte("abc")); err != nil {
+		t.Fatal(err)
+	}
+	if got, err := c.Get(ScopeBot, "b.zst"); err != nil || string(got) != "abc" {
+		t.Fatal("suffix codec roundtrip failed", err)
+	}
+}
+
+func TestDecodeBombs(t *testing.T) {
+	zeros := make([]byte, MaxDecodedSize+1)
+
+	var gz bytes.Buffer
+	zw, _ := gzip.NewWriterLevel(&gz, gzip.BestSpeed)
+	zw.Write(zeros)
+	zw.Close()
+	if _, err := (gzipCodec{}).Decode(gz.Bytes()); !errors.Is(err, ErrDecodedTooLarge) {
+		t.Errorf("gzip: got %v, want ErrDecodedTooLarge", err)
+	}
+
+	enc, _ := zstd.NewWriter(nil, zstd.WithEncoderLevel(zstd.SpeedFastest))
+	if _, err := (zstdCodec{}).Decode(enc.EncodeAll(zeros, nil)); !errors.Is(err, ErrDecodedTooLarge) {
+		t.Errorf("zstd: got %v, want ErrDecodedTooLarge", err)
+	}
+
+	// a snappy block starts with its decoded length
+	sz := make([]byte, binary.MaxVarintLen64)
+	n := binary.PutUvarint(sz, MaxDecodedSize+1)
+	if _, err := (snappyCodec{}).Decode(append(sz[:n], 0)); !errors.Is(err, ErrDecodedTooLarge) {
+		t.Errorf("snappy: got %v, want ErrDecodedTooLarge", err)
+	}
+}
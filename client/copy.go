@@ -0,0 +1,119 @@
+package client
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+)
+
+// CopyClient copies and moves objects on the server
+type CopyClient interface {
+	Copy(srcScope Scope, srcID string, dstScope Scope, dstID string) error
+	Move(srcScope Scope, srcID string, dstScope Scope, dstID string) error
+}
+
+var _ CopyClient = (*client)(nil)
+
+// ErrSameObject is returned when copying or moving an object onto itself
+var ErrSameObject = errors.New("source and destination are the same object")
+
+func (c *client) copyURL(scope Scope, objID, action string, dstScope Scope, dstID string) string {
+	return fmt.Sprintf("%s/%s?to=%s", c.objURL(scope, objID), action, url.QueryEscape(string(dstScope)+"/"+dstID))
+}
+
+func (c *client) copyRaw(action string, srcScope Scope, srcID string, dstScope Scope, dstID string) error {
+	resp, err := c.do("POST", c.copyURL(srcScope, srcID, action, dstScope, dstID), nil, nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	return checkStatus(resp)
+}
+
+// serverCopy reports whether the object can be copied byte for byte.  Encrypted objects are bound to their scope and
+// key, and chunked objects to their parts, so those are read and written again instead.  Another client may have
+// written the object chunked or encrypted, so the stored object is checked as well as this client's own options.
+func (c *client) serverCopy(scope Scope, objID string) (bool, error) {
+	if c.keys != nil || c.chunkSize > 0 {
+		return false, nil
+	}
+	bound, err := c.boundToKey(scope, objID)
+	return !bound, err
+}
+
+// boundToKey reports whether the stored object only reads back at its own key: it is a chunk manifest or an
+// encryption envelope.  Marked manifests are recognised with a HEAD; otherwise the leading bytes are read.
+func (c *client) boundToKey(scope Scope, objID string) (bool, error) {
+	resp, err := c.do("HEAD", c.objURL(scope, objID), nil, nil)
+	if err != nil {
+		return false, err
+	}
+	resp.Body.Close()
+	if err := checkStatus(resp); err != nil {
+		return false, err
+	}
+	if resp.Header.Get(metaPrefix+chunksMetaKey) != "" {
+		return true, nil
+	}
+	if size, _ := strconv.ParseInt(resp.Header.Get("X-Object-Size"), 10, 64); size == 0 {
+		return false, nil
+	}
+	n := len(manifestMagic)
+	if len(envelopeMagic) > n {
+		n = len(envelopeMagic)
+	}
+	header := http.Header{}
+	header.Set("Range", fmt.Sprintf("bytes=0-%d", n-1))
+	b, _, err := c.getRaw(scope, objID, header)
+	if err != nil {
+		return false, err
+	}
+	return isManifest(b) || isEnvelope(b), nil
+}
+
+// copyThrough copies an object by reading and writing it, keeping its content type and metadata
+func (c *client) copyThrough(srcScope Scope, srcID string, dstScope Scope, dstID string) error {
+	b, info, err := c.GetWithInfo(srcScope, srcID)
+	if err != nil {
+		return err
+	}
+	return c.PutWithOptions(dstScope, dstID, b, &PutOptions{ContentType: info.ContentType, Metadata: info.Metadata})
+}
+
+// Copy copies an object to another key, in the same or another scope, without downloading it.
+// The copy keeps the object's content type and metadata.
+func (c *client) Copy(srcScope Scope, srcID string, dstScope Scope, dstID string) error {
+	if srcScope == dstScope && srcID == dstID {
+		return ErrSameObject
+	}
+	server, err := c.serverCopy(srcScope, srcID)
+	if err != nil {
+		return err
+	}
+	if !server {
+		return c.copyThrough(srcScope, srcID, dstScope, dstID)
+	}
+	return c.copyRaw("copy", srcScope, srcID, dstScope, dstID)
+}
+
+// Move copies an object to another key and deletes the original.  Writing to a temporary key and then moving it
+// into place means readers of the destination never see a partial write.
+func (c *client) Move(srcScope Scope, srcID string, dstScope Scope, dstID string) error {
+	if srcScope == dstScope && srcID == dstID {
+		return ErrSameObject
+	}
+	server, err := c.serverCopy(srcScope, srcID)
+	if err != nil {
+		return err
+	}
+	if !server {
+		if err := c.copyThrough(srcScope, srcID, dstScope, dstID); err != nil {
+			return err
+		}
+		// removes the source's parts too, whether or not this client chunks
+		return c.delChunked(srcScope, srcID)
+	}
+	return c.copyRaw("move", srcScope, srcID, dstScope, dstID)
+}
@@ -0,0 +1,72 @@
+package client
+
+import (
+	"bytes"
+	"errors"
+	"math/rand"
+	"strings"
+	"testing"
+)
+
+func TestCopyChunkedFromPlainClient(t *testing.T) {
+	writer, s := newTestClient(t, WithChunking(1024))
+	payload := make([]byte, 3000)
+	rand.New(rand.NewSource(1)).Read(payload)
+	if err := writer.Put(ScopeBot, "big.bin", payload); err != nil {
+		t.Fatal(err)
+	}
+
+	// a client without chunking copies the payload rather than the manifest alone
+	plain := &client{apiHost: writer.apiHost, jwtProviderUrl: writer.jwtProviderUrl, codec: CodecNone}
+	if err := plain.Copy(ScopeBot, "big.bin", ScopeBot, "copy.bin"); err != nil {
+		t.Fatal(err)
+	}
+	if got, err := plain.Get(ScopeBot, "copy.bin"); err != nil || !bytes.Equal(got, payload) {
+		t.Fatal("the copy does not read back", err)
+	}
+
+	// a move leaves none of the source's parts behind
+	if err := plain.Move(ScopeBot, "big.bin", ScopeBot, "moved.bin"); err != nil {
+		t.Fatal(err)
+	}
+	if got, err := plain.Get(ScopeBot, "moved.bin"); err != nil || !bytes.Equal(got, payload) {
+		t.Fatal("the moved object does not read back", err)
+	}
+	for key := range s.objects {
+		if strings.HasPrefix(key, "bot/big.bin") {
+			t.Fatalf("%s was left behind", key)
+		}
+	}
+}
+
+func TestCopyEncryptedFromPlainClient(t *testing.T) {
+	writer, _ := newTestClient(t, WithEncryption(StaticKeyProvider("k1", testKey(1))))
+	if err := writer.Put(ScopeBot, "secret.json", []byte(`{"a":1}`)); err != nil {
+		t.Fatal(err)
+	}
+
+	// a byte for byte copy would not decrypt at its new key, and a client without the key cannot copy it through
+	plain := &client{apiHost: writer.apiHost, jwtProviderUrl: writer.jwtProviderUrl, codec: CodecNone}
+	if err := plain.Copy(ScopeBot, "secret.json", ScopeBot, "copy.json"); err == nil {
+		t.Fatal("expected the copy to fail without the key")
+	}
+	if _, err := writer.Get(ScopeBot, "copy.json"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("got %v, want ErrNotFound", err)
+	}
+}
+
+func TestCopyPlainObjectOnServer(t *testing.T) {
+	c, s := newTestClient(t)
+	if err := c.Put(ScopeBot, "a.json", []byte(`{"a":1}`)); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Copy(ScopeBot, "a.json", ScopeOwner, "a.json"); err != nil {
+		t.Fatal(err)
+	}
+	if s.count("POST") != 1 || s.count("PUT") != 1 {
+		t.Fatal("a plain object should be copied on the server")
+	}
+	if got, err := c.Get(ScopeOwner, "a.json"); err != nil || string(got) != `{"a":1}` {
+		t.Fatal("the copy does not read back", err)
+	}
+}
@@ -0,0 +1,192 @@
+package client
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+
+	"golang.org/x/crypto/hkdf"
+)
+
+// envelopeMagic prefixes every encrypted object
+var envelopeMagic = []byte("FBDBENC1")
+
+const dataKeySize = 32
+
+// ErrNotEncrypted is returned when encryption is enabled and a stored object is plaintext
+var ErrNotEncrypted = errors.New("object is not encrypted")
+
+// ErrEncrypted is returned when an encrypted object is read by a client without a KeyProvider
+var ErrEncrypted = errors.New("object is encrypted")
+
+// KeyProvider supplies the master keys used for client-side encryption
+type KeyProvider interface {
+	// CurrentKey returns the id and material of the key used to encrypt new objects
+	CurrentKey() (string, []byte, error)
+	// Key returns the material of the key with the given id, as recorded on a stored object
+	Key(id string) ([]byte, error)
+}
+
+type staticKeyProvider struct {
+	id  string
+	key []byte
+}
+
+func (p *staticKeyProvider) CurrentKey() (string, []byte, error) {
+	return p.id, p.key, nil
+}
+
+func (p *staticKeyProvider) Key(id string) ([]byte, error) {
+	if id != p.id {
+		return nil, fmt.Errorf("unknown key id %q", id)
+	}
+	return p.key, nil
+}
+
+// StaticKeyProvider returns a KeyProvider with a single master key
+func StaticKeyProvider(id string, key []byte) KeyProvider {
+	return &staticKeyProvider{id: id, key: key}
+}
+
+// WithEncryption encrypts payloads before they leave the client, so the server only stores ciphertext.
+// Each object gets a random data key, which is wrapped under a key derived from the master key, scope and key name.
+func WithEncryption(kp KeyProvider) Option {
+	return func(c *client) {
+		c.keys = kp
+	}
+}
+
+type envelopeHeader struct {
+	KeyID      string `json:"kid"`
+	Encoding   string `json:"enc"`
+	WrappedKey []byte `json:"wk"`
+}
+
+// wrappingKey derives the key that wraps data keys for one object
+func wrappingKey(master []byte, scope Scope, objID string) ([]byte, error) {
+	info := fmt.Sprintf("forta-bot-db|%s|%s", scope, objID)
+	k := make([]byte, dataKeySize)
+	if _, err := io.ReadFull(hkdf.New(sha256.New, master, nil, []byte(info)), k); err != nil {
+		return nil, err
+	}
+	return k, nil
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// seal encrypts b, prefixing the output with a random nonce
+func seal(key, b, aad []byte) ([]byte, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return gcm.Seal(nonce, nonce, b, aad), nil
+}
+
+func open(key, b, aad []byte) ([]byte, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(b) < gcm.NonceSize() {
+		return nil, errors.New("ciphertext too short")
+	}
+	return gcm.Open(nil, b[:gcm.NonceSize()], b[gcm.NonceSize():], aad)
+}
+
+// envelopeAAD binds the ciphertext to its location and encoding, so it cannot be swapped with another object
+func envelopeAAD(scope Scope, objID, encoding string) []byte {
+	return []byte(fmt.Sprintf("%s|%s|%s", scope, objID, encoding))
+}
+
+func isEnvelope(b []byte) bool {
+	return bytes.HasPrefix(b, envelopeMagic)
+}
+
+// encrypt wraps an encoded payload as: magic | header length (uint32) | header json | ciphertext
+func encrypt(kp KeyProvider, scope Scope, objID string, encoding string, b []byte) ([]byte, error) {
+	keyID, master, err := kp.CurrentKey()
+	if err != nil {
+		return nil, err
+	}
+	wk, err := wrappingKey(master, scope, objID)
+	if err != nil {
+		return nil, err
+	}
+	dataKey := make([]byte, dataKeySize)
+	if _, err := rand.Read(dataKey); err != nil {
+		return nil, err
+	}
+	wrapped, err := seal(wk, dataKey, []byte(keyID))
+	if err != nil {
+		return nil, err
+	}
+	ct, err := seal(dataKey, b, envelopeAAD(scope, objID, encoding))
+	if err != nil {
+		return nil, err
+	}
+	header, err := json.Marshal(&envelopeHeader{KeyID: keyID, Encoding: encoding, WrappedKey: wrapped})
+	if err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	buf.Write(envelopeMagic)
+	if err := binary.Write(&buf, binary.BigEndian, uint32(len(header))); err != nil {
+		return nil, err
+	}
+	buf.Write(header)
+	buf.Write(ct)
+	return buf.Bytes(), nil
+}
+
+// decrypt opens an envelope, returning the encoded payload and its encoding
+func decrypt(kp KeyProvider, scope Scope, objID string, b []byte) ([]byte, string, error) {
+	b = b[len(envelopeMagic):]
+	if len(b) < 4 {
+		return nil, "", errors.New("truncated envelope")
+	}
+	n := binary.BigEndian.Uint32(b)
+	b = b[4:]
+	if uint32(len(b)) < n {
+		return nil, "", errors.New("truncated envelope")
+	}
+	var header envelopeHeader
+	if err := json.Unmarshal(b[:n], &header); err != nil {
+		return nil, "", err
+	}
+	master, err := kp.Key(header.KeyID)
+	if err != nil {
+		return nil, "", err
+	}
+	wk, err := wrappingKey(master, scope, objID)
+	if err != nil {
+		return nil, "", err
+	}
+	dataKey, err := open(wk, header.WrappedKey, []byte(header.KeyID))
+	if err != nil {
+		return nil, "", err
+	}
+	pt, err := open(dataKey, b[n:], envelopeAAD(scope, objID, header.Encoding))
+	if err != nil {
+		return nil, "", err
+	}
+	return pt, header.Encoding, nil
+}
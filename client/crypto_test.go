@@ -0,0 +1,133 @@
+package client
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"testing"
+)
+
+// rotatingKeyProvider encrypts with its current key and can still open objects written under older ones
+type rotatingKeyProvider struct {
+	current string
+	keys    map[string][]byte
+}
+
+func (p *rotatingKeyProvider) CurrentKey() (string, []byte, error) {
+	return p.current, p.keys[p.current], nil
+}
+
+func (p *rotatingKeyProvider) Key(id string) ([]byte, error) {
+	k, ok := p.keys[id]
+	if !ok {
+		return nil, fmt.Errorf("unknown key id %q", id)
+	}
+	return k, nil
+}
+
+func testKey(b byte) []byte {
+	return bytes.Repeat([]byte{b}, 32)
+}
+
+func TestEncryptRoundTrip(t *testing.T) {
+	kp := StaticKeyProvider("k1", testKey(1))
+	payload := []byte("secret payload")
+	b, err := encrypt(kp, ScopeBot, "obj", "gzip", payload)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !isEnvelope(b) || bytes.Contains(b, payload) {
+		t.Fatal("payload is not sealed")
+	}
+	pt, enc, err := decrypt(kp, ScopeBot, "obj", b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(pt, payload) || enc != "gzip" {
+		t.Fatalf("got %q/%q", pt, enc)
+	}
+
+	// a fresh data key and nonce per write
+	b2, err := encrypt(kp, ScopeBot, "obj", "gzip", payload)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Equal(b, b2) {
+		t.Fatal("two encryptions of the same payload are identical")
+	}
+}
+
+func TestDecryptRejects(t *testing.T) {
+	kp := StaticKeyProvider("k1", testKey(1))
+	b, err := encrypt(kp, ScopeBot, "obj", "", []byte("secret payload"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tampered := append([]byte(nil), b...)
+	tampered[len(tampered)-1] ^= 0xff
+	if _, _, err := decrypt(kp, ScopeBot, "obj", tampered); err == nil {
+		t.Error("tampered ciphertext was opened")
+	}
+	if _, _, err := decrypt(StaticKeyProvider("k1", testKey(2)), ScopeBot, "obj", b); err == nil {
+		t.Error("opened with the wrong key")
+	}
+	if _, _, err := decrypt(StaticKeyProvider("k2", testKey(1)), ScopeBot, "obj", b); err == nil {
+		t.Error("opened with an unknown key id")
+	}
+	if _, _, err := decrypt(kp, ScopeScanner, "obj", b); err == nil {
+		t.Error("opened under another scope")
+	}
+	if _, _, err := decrypt(kp, ScopeBot, "other", b); err == nil {
+		t.Error("opened under another object id")
+	}
+	if _, _, err := decrypt(kp, ScopeBot, "obj", b[:len(envelopeMagic)+2]); err == nil {
+		t.Error("opened a truncated envelope")
+	}
+}
+
+func TestEncryptedClient(t *testing.T) {
+	kp := &rotatingKeyProvider{current: "k1", keys: map[string][]byte{"k1": testKey(1)}}
+	c, s := newTestClient(t, WithEncryption(kp))
+	if err := c.Put(ScopeBot, "a", []byte("written under k1")); err != nil {
+		t.Fatal(err)
+	}
+	for _, obj := range s.objects {
+		if !isEnvelope(obj.body) {
+			t.Fatal("server stored plaintext")
+		}
+	}
+
+	// after rotation, new writes use k2 and old objects still open with k1
+	kp.keys["k2"] = testKey(2)
+	kp.current = "k2"
+	if err := c.Put(ScopeBot, "b", []byte("written under k2")); err != nil {
+		t.Fatal(err)
+	}
+	for key, want := range map[string]string{"a": "written under k1", "b": "written under k2"} {
+		got, err := c.Get(ScopeBot, key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(got) != want {
+			t.Fatalf("%s: got %q, want %q", key, got, want)
+		}
+	}
+
+	// once k1 is retired, its objects can no longer be read
+	delete(kp.keys, "k1")
+	if _, err := c.Get(ScopeBot, "a"); err == nil {
+		t.Fatal("read an object whose key was retired")
+	}
+
+	plain := &client{apiHost: c.apiHost, jwtProviderUrl: c.jwtProviderUrl, codec: c.codec}
+	if _, err := plain.Get(ScopeBot, "b"); !errors.Is(err, ErrEncrypted) {
+		t.Fatalf("client without keys: got %v, want ErrEncrypted", err)
+	}
+	if err := plain.Put(ScopeBot, "c", []byte("plaintext")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.Get(ScopeBot, "c"); !errors.Is(err, ErrNotEncrypted) {
+		t.Fatalf("plaintext object: got %v, want ErrNotEncrypted", err)
+	}
+}
@@ -0,0 +1,140 @@
+package client
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// ElectorOptions configures an Elector
+type ElectorOptions struct {
+	// TTL is how long leadership outlives a leader that stops renewing (default 30s, at least MinLeaseTTL)
+	TTL time.Duration
+	// RetryInterval is how often followers try to take over (default TTL/3)
+	RetryInterval time.Duration
+	// OnElected is called when this instance becomes leader, with the fencing token of its term
+	OnElected func(token int64)
+	// OnDefeated is called when this instance stops being leader, including on Close
+	OnDefeated func()
+	// OnError is called when an election attempt fails for another reason than an existing leader
+	OnError func(error)
+}
+
+// Elector elects one leader among the scanners running a bot, using a ScopeBot lease named after the election.
+// The server records the leader as the scanner of the JWT that acquired the lease, so scanners cannot claim to be another.
+// Callbacks run on the elector's goroutine.
+type Elector struct {
+	c    LockClient
+	name string
+	opts ElectorOptions
+
+	mu    sync.Mutex
+	lease *Lease
+
+	stop      chan struct{}
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+// NewElector joins the election and keeps campaigning until Close
+func NewElector(c LockClient, name string, opts ElectorOptions) (*Elector, error) {
+	if opts.TTL == 0 {
+		opts.TTL = 30 * time.Second
+	}
+	if opts.TTL < MinLeaseTTL {
+		return nil, fmt.Errorf("election ttl must be at least %v", MinLeaseTTL)
+	}
+	if opts.RetryInterval == 0 {
+		opts.RetryInterval = opts.TTL / 3
+	}
+	e := &Elector{
+		c:    c,
+		name: name,
+		opts: opts,
+		stop: make(chan struct{}),
+		done: make(chan struct{}),
+	}
+	go e.run()
+	return e, nil
+}
+
+// IsLeader reports whether this instance currently leads
+func (e *Elector) IsLeader() bool {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.lease != nil
+}
+
+// Leader returns the scanner address of the current leader, or "" if there is none
+func (e *Elector) Leader() (string, error) {
+	grant, err := e.c.Locks().Holder(ScopeBot, e.name)
+	if errors.Is(err, ErrNotFound) {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	return grant.Holder, nil
+}
+
+// Close leaves the election, handing leadership over right away if this instance leads
+func (e *Elector) Close() error {
+	e.closeOnce.Do(func() {
+		close(e.stop)
+	})
+	<-e.done
+
+	e.mu.Lock()
+	lease := e.lease
+	e.lease = nil
+	e.mu.Unlock()
+	if lease == nil {
+		return nil
+	}
+	err := lease.Release()
+	if e.opts.OnDefeated != nil {
+		e.opts.OnDefeated()
+	}
+	return err
+}
+
+func (e *Elector) run() {
+	defer close(e.done)
+
+	for {
+		lease, err := AcquireLease(e.c, ScopeBot, e.name, e.opts.TTL)
+		if err == nil {
+			e.mu.Lock()
+			e.lease = lease
+			e.mu.Unlock()
+			if e.opts.OnElected != nil {
+				e.opts.OnElected(lease.Token())
+			}
+
+			select {
+			case <-e.stop:
+				// Close releases the lease
+				return
+			case <-lease.Lost():
+			}
+			e.mu.Lock()
+			e.lease = nil
+			e.mu.Unlock()
+			if e.opts.OnDefeated != nil {
+				e.opts.OnDefeated()
+			}
+			if e.opts.OnError != nil {
+				e.opts.OnError(lease.Err())
+			}
+		} else if !errors.Is(err, ErrLockHeld) && e.opts.OnError != nil {
+			e.opts.OnError(err)
+		}
+
+		select {
+		case <-e.stop:
+			return
+		case <-time.After(e.opts.RetryInterval):
+		}
+	}
+}
@@ -0,0 +1,109 @@
+package client
+
+import (
+	"testing"
+	"time"
+)
+
+func TestElectorMinTTL(t *testing.T) {
+	if _, err := NewElector(&testLocks{s: &lockState{}, id: "a"}, "publisher", ElectorOptions{TTL: time.Millisecond}); err == nil {
+		t.Fatal("expected a ttl under MinLeaseTTL to be rejected")
+	}
+}
+
+func TestElectorHandover(t *testing.T) {
+	s := &lockState{}
+	type event struct {
+		id      string
+		elected bool
+		token   int64
+	}
+	events := make(chan event, 10)
+	elector := func(id string) *Elector {
+		e, err := NewElector(&testLocks{s: s, id: id}, "publisher", ElectorOptions{
+			TTL:           MinLeaseTTL,
+			RetryInterval: 10 * time.Millisecond,
+			OnElected:     func(token int64) { events <- event{id: id, elected: true, token: token} },
+			OnDefeated:    func() { events <- event{id: id} },
+			OnError:       func(err error) { t.Errorf("%s: %v", id, err) },
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return e
+	}
+	next := func() event {
+		select {
+		case ev := <-events:
+			return ev
+		case <-time.After(MinLeaseTTL):
+			t.Fatal("no election event")
+			return event{}
+		}
+	}
+
+	a := elector("a")
+	first := next()
+	if first != (event{id: "a", elected: true, token: 1}) {
+		t.Fatalf("got %+v, want a elected", first)
+	}
+	b := elector("b")
+	defer b.Close()
+	time.Sleep(50 * time.Millisecond)
+	if !a.IsLeader() || b.IsLeader() {
+		t.Fatal("b took over a held lease")
+	}
+	if leader, err := b.Leader(); err != nil || leader != "a" {
+		t.Fatalf("leader %q, %v", leader, err)
+	}
+
+	// closing the leader hands over without waiting for the lease to expire
+	start := time.Now()
+	if err := a.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if ev := next(); ev != (event{id: "a"}) {
+		t.Fatalf("got %+v, want a defeated", ev)
+	}
+	if ev := next(); ev.id != "b" || !ev.elected || ev.token <= first.token {
+		t.Fatalf("got %+v, want b elected with a higher token", ev)
+	}
+	if time.Since(start) > MinLeaseTTL/2 {
+		t.Fatal("the handover waited for the lease to expire")
+	}
+	if a.IsLeader() || !b.IsLeader() {
+		t.Fatal("leadership did not move to b")
+	}
+}
+
+func TestElectorLosesLease(t *testing.T) {
+	s := &lockState{}
+	defeated := make(chan struct{}, 1)
+	errs := make(chan error, 1)
+	e, err := NewElector(&testLocks{s: s, id: "a"}, "publisher", ElectorOptions{
+		TTL:           MinLeaseTTL,
+		RetryInterval: time.Hour,
+		OnDefeated:    func() { defeated <- struct{}{} },
+		OnError:       func(err error) { errs <- err },
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer e.Close()
+	for !e.IsLeader() {
+		time.Sleep(time.Millisecond)
+	}
+
+	s.set(func(s *lockState) { s.holder, s.token = "b", s.token+1 })
+	select {
+	case <-defeated:
+	case <-time.After(MinLeaseTTL):
+		t.Fatal("the elector did not notice the lost lease")
+	}
+	if err := <-errs; err != ErrLeaseLost {
+		t.Fatalf("got %v, want ErrLeaseLost", err)
+	}
+	if e.IsLeader() {
+		t.Fatal("still leading after losing the lease")
+	}
+}
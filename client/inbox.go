@@ -0,0 +1,117 @@
+package client
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// ErrInboxFull is returned by Send when the receiving bot has been sent too many messages; try again later
+var ErrInboxFull = errors.New("inbox is receiving too many messages")
+
+// Message is a message sent to the bot's inbox; From and Scanner are verified by the server
+type Message struct {
+	ID      string
+	From    string
+	Scanner string
+	Body    []byte
+	SentAt  time.Time
+}
+
+// Inbox passes small messages between bots, e.g. to hand candidates to a downstream bot without raising alerts.
+// Messages are limited to MaxKVValueSize and kept for 7 days unless acknowledged.
+type Inbox interface {
+	// Send delivers body to the inbox of botID and returns the message id.
+	// It returns ErrInboxFull when the bot is sent more messages than the server accepts.
+	Send(botID string, body []byte) (string, error)
+	// List returns the bot's messages, oldest first, after the message id after ("" for the start).
+	// The returned id is where to continue listing, or "" once there are no more messages.
+	List(after string, limit int) ([]Message, string, error)
+	// Ack removes a message from the inbox
+	Ack(id string) error
+}
+
+// InboxClient is implemented by clients that can send and receive messages
+type InboxClient interface {
+	Inbox() Inbox
+}
+
+var _ InboxClient = (*client)(nil)
+
+type inboxClient struct {
+	c *client
+}
+
+// Inbox returns the messaging API; the inbox listed is the one of the client's bot
+func (c *client) Inbox() Inbox {
+	return &inboxClient{c: c}
+}
+
+func (i *inboxClient) Send(botID string, body []byte) (string, error) {
+	if len(body) > MaxKVValueSize {
+		return "", ErrValueTooLarge
+	}
+	resp, err := i.c.do("POST", fmt.Sprintf("%s/inbox/%s", i.c.apiHost, botID), body, nil)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusTooManyRequests {
+		return "", ErrInboxFull
+	}
+	if err := checkStatus(resp); err != nil {
+		return "", err
+	}
+	var res struct {
+		ID string `json:"id"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&res); err != nil {
+		return "", err
+	}
+	return res.ID, nil
+}
+
+func (i *inboxClient) List(after string, limit int) ([]Message, string, error) {
+	u := fmt.Sprintf("%s/inbox?after=%s", i.c.apiHost, url.QueryEscape(after))
+	if limit > 0 {
+		u = fmt.Sprintf("%s&limit=%d", u, limit)
+	}
+	resp, err := i.c.do("GET", u, nil, nil)
+	if err != nil {
+		return nil, "", err
+	}
+	defer resp.Body.Close()
+	if err := checkStatus(resp); err != nil {
+		return nil, "", err
+	}
+	var res struct {
+		Messages []struct {
+			ID      string `json:"id"`
+			From    string `json:"from"`
+			Scanner string `json:"scanner"`
+			Body    []byte `json:"body"`
+			SentAt  int64  `json:"sentAt"`
+		} `json:"messages"`
+		Next string `json:"next"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&res); err != nil {
+		return nil, "", err
+	}
+	msgs := make([]Message, 0, len(res.Messages))
+	for _, m := range res.Messages {
+		msgs = append(msgs, Message{ID: m.ID, From: m.From, Scanner: m.Scanner, Body: m.Body, SentAt: time.UnixMilli(m.SentAt)})
+	}
+	return msgs, res.Next, nil
+}
+
+func (i *inboxClient) Ack(id string) error {
+	resp, err := i.c.do("DELETE", fmt.Sprintf("%s/inbox/%s", i.c.apiHost, url.PathEscape(id)), nil, nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	return checkStatus(resp)
+}
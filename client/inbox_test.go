@@ -0,0 +1,64 @@
+package client
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestInboxRequests(t *testing.T) {
+	c, s := newTestClient(t)
+	var sent []string
+	s.handle("/inbox/0xdownstream", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "POST" {
+			t.Errorf("unexpected method %s", r.Method)
+		}
+		if len(sent) == 1 {
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		b, _ := io.ReadAll(r.Body)
+		sent = append(sent, string(b))
+		w.Write([]byte(`{"id":"01H/1"}`))
+	})
+	s.handle("/inbox", func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		if r.Method != "GET" || q.Get("after") != "01G/9" || q.Get("limit") != "10" {
+			t.Errorf("unexpected request %s %s", r.Method, r.URL)
+		}
+		w.Write([]byte(`{"messages":[{"id":"01H/1","from":"0xupstream","scanner":"0xscanner","body":"eyJhIjoxfQ==","sentAt":1700000000000}],"next":"01H/1"}`))
+	})
+	acked := ""
+	s.handle("/inbox/01H/1", func(w http.ResponseWriter, r *http.Request) {
+		// ids contain slashes, so they are escaped into one path segment
+		acked = r.Method + " " + r.URL.EscapedPath()
+	})
+
+	inbox := c.Inbox()
+	id, err := inbox.Send("0xdownstream", []byte(`{"a":1}`))
+	if err != nil || id != "01H/1" {
+		t.Fatalf("got %q, %v", id, err)
+	}
+	if _, err := inbox.Send("0xdownstream", []byte(`{"a":2}`)); !errors.Is(err, ErrInboxFull) {
+		t.Fatalf("got %v, want ErrInboxFull", err)
+	}
+	msgs, next, err := inbox.List("01G/9", 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := Message{ID: "01H/1", From: "0xupstream", Scanner: "0xscanner", Body: []byte(`{"a":1}`), SentAt: time.UnixMilli(1_700_000_000_000)}
+	if len(msgs) != 1 || msgs[0].ID != want.ID || msgs[0].From != want.From || msgs[0].Scanner != want.Scanner ||
+		string(msgs[0].Body) != string(want.Body) || !msgs[0].SentAt.Equal(want.SentAt) || next != "01H/1" {
+		t.Fatalf("got %+v, %q", msgs, next)
+	}
+	if err := inbox.Ack(id); err != nil {
+		t.Fatal(err)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(sent) != 1 || sent[0] != `{"a":1}` || acked != "DELETE /inbox/01H%2F1" {
+		t.Fatalf("sent %q, acked with %q", sent, acked)
+	}
+}
@@ -0,0 +1,102 @@
+package client
+
+import (
+	"encoding/json"
+	"errors"
+)
+
+// maxUpdateAttempts bounds the read-modify-write loop in Update
+const maxUpdateAttempts = 10
+
+// ErrVersionMismatch is returned by conditional writes when the stored object changed
+var ErrVersionMismatch = errors.New("version mismatch")
+
+// VersionedClient is implemented by clients whose backend supports conditional writes.
+// Versions are opaque strings (e.g. an ETag); an empty version means the object must not exist.
+type VersionedClient interface {
+	GetVersioned(scope Scope, objID string) ([]byte, string, error)
+	PutIfVersion(scope Scope, objID string, payload []byte, version string) error
+}
+
+// GetJSON fetches an object and unmarshals it into a T
+func GetJSON[T any](c Client, scope Scope, objID string) (T, error) {
+	var v T
+	b, err := c.Get(scope, objID)
+	if err != nil {
+		return v, err
+	}
+	if err := json.Unmarshal(b, &v); err != nil {
+		return v, err
+	}
+	return v, nil
+}
+
+// GetJSONOrDefault is like GetJSON, but returns def if the object does not exist
+func GetJSONOrDefault[T any](c Client, scope Scope, objID string, def T) (T, error) {
+	v, err := GetJSON[T](c, scope, objID)
+	if errors.Is(err, ErrNotFound) {
+		return def, nil
+	}
+	return v, err
+}
+
+// PutJSON marshals v and stores it
+func PutJSON[T any](c Client, scope Scope, objID string, v T) error {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	return c.Put(scope, objID, b)
+}
+
+// Update reads the object (or the zero T if it does not exist), applies fn and writes the result back.
+// If c implements VersionedClient, as the clients of this package and clienttest do, the write is conditional and
+// the whole cycle is retried when another writer got in first.  Otherwise Update is not atomic and the last writer wins.
+// If fn returns an error, nothing is written and that error is returned.
+func Update[T any](c Client, scope Scope, objID string, fn func(*T) error) error {
+	vc, ok := c.(VersionedClient)
+	if !ok {
+		return updateUnversioned(c, scope, objID, fn)
+	}
+
+	for i := 0; i < maxUpdateAttempts; i++ {
+		var v T
+		b, version, err := vc.GetVersioned(scope, objID)
+		if errors.Is(err, ErrNotSupported) {
+			// a wrapper around a client without conditional writes
+			return updateUnversioned(c, scope, objID, fn)
+		}
+		if err != nil && !errors.Is(err, ErrNotFound) {
+			return err
+		}
+		if err == nil {
+			if err := json.Unmarshal(b, &v); err != nil {
+				return err
+			}
+		}
+		if err := fn(&v); err != nil {
+			return err
+		}
+		updated, err := json.Marshal(v)
+		if err != nil {
+			return err
+		}
+		err = vc.PutIfVersion(scope, objID, updated, version)
+		if errors.Is(err, ErrVersionMismatch) {
+			continue
+		}
+		return err
+	}
+	return ErrVersionMismatch
+}
+
+func updateUnversioned[T any](c Client, scope Scope, objID string, fn func(*T) error) error {
+	v, err := GetJSONOrDefault[T](c, scope, objID, *new(T))
+	if err != nil {
+		return err
+	}
+	if err := fn(&v); err != nil {
+		return err
+	}
+	return PutJSON(c, scope, objID, v)
+}
@@ -0,0 +1,66 @@
+package client
+
+import (
+	"errors"
+	"sync"
+	"testing"
+)
+
+func TestUpdateConcurrent(t *testing.T) {
+	c, _ := newTestClient(t)
+	type counter struct{ Count int }
+
+	const writers = 8
+	var wg sync.WaitGroup
+	errs := make(chan error, writers)
+	for i := 0; i < writers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs <- Update(c, ScopeBot, "counter.json", func(v *counter) error {
+				v.Count++
+				return nil
+			})
+		}()
+	}
+	wg.Wait()
+	close(errs)
+
+	// a writer may run out of attempts, but then it must say so rather than lose its update
+	written := 0
+	for err := range errs {
+		switch {
+		case err == nil:
+			written++
+		case !errors.Is(err, ErrVersionMismatch):
+			t.Fatal(err)
+		}
+	}
+	v, err := GetJSON[counter](c, ScopeBot, "counter.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v.Count != written {
+		t.Fatalf("count is %d after %d successful updates", v.Count, written)
+	}
+}
+
+func TestPutIfVersion(t *testing.T) {
+	c, _ := newTestClient(t)
+	if err := c.PutIfVersion(ScopeBot, "a.json", []byte("1"), ""); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.PutIfVersion(ScopeBot, "a.json", []byte("2"), ""); !errors.Is(err, ErrVersionMismatch) {
+		t.Fatalf("create over an existing object: got %v, want ErrVersionMismatch", err)
+	}
+	_, version, err := c.GetVersioned(ScopeBot, "a.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := c.PutIfVersion(ScopeBot, "a.json", []byte("3"), version); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.PutIfVersion(ScopeBot, "a.json", []byte("4"), version); !errors.Is(err, ErrVersionMismatch) {
+		t.Fatalf("stale version: got %v, want ErrVersionMismatch", err)
+	}
+}
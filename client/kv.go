@@ -0,0 +1,138 @@
+package client
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+)
+
+// MaxKVValueSize is the largest value the key-value API stores
+const MaxKVValueSize = 64 * 1024
+
+// ErrValueTooLarge is returned when a key-value write exceeds MaxKVValueSize
+var ErrValueTooLarge = errors.New("value too large")
+
+// ErrNotCounter is returned by Incr when the key holds a value written by Put or CompareAndSwap
+var ErrNotCounter = errors.New("not a counter")
+
+// KV stores small values as items rather than objects, for low-latency state that changes often (e.g. per-block checkpoints).
+// Values are stored as given: they are not compressed, encrypted or chunked.
+// Counters read back through Get as their decimal value.
+type KV interface {
+	Get(scope Scope, key string) ([]byte, error)
+	Put(scope Scope, key string, value []byte) error
+	Del(scope Scope, key string) error
+	// Incr atomically adds by (which may be negative) to a counter, starting from 0, and returns the new count
+	Incr(scope Scope, key string, by int64) (int64, error)
+	// CompareAndSwap writes new only if the key still holds old, reporting whether it did.
+	// A nil old only succeeds if the key does not exist.
+	CompareAndSwap(scope Scope, key string, old, new []byte) (bool, error)
+}
+
+// KVClient is implemented by clients that can use the key-value API
+type KVClient interface {
+	KV() KV
+}
+
+var _ KVClient = (*client)(nil)
+
+type kvClient struct {
+	c *client
+}
+
+// KV returns the key-value API, using the same authentication as the client
+func (c *client) KV() KV {
+	return &kvClient{c: c}
+}
+
+func (k *kvClient) url(scope Scope, key string) string {
+	return fmt.Sprintf("%s/kv/%s/%s", k.c.apiHost, scope, key)
+}
+
+func (k *kvClient) Get(scope Scope, key string) ([]byte, error) {
+	resp, err := k.c.do("GET", k.url(scope, key), nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if err := checkStatus(resp); err != nil {
+		return nil, err
+	}
+	return io.ReadAll(resp.Body)
+}
+
+func (k *kvClient) Put(scope Scope, key string, value []byte) error {
+	if len(value) > MaxKVValueSize {
+		return ErrValueTooLarge
+	}
+	resp, err := k.c.do("PUT", k.url(scope, key), value, nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	return checkStatus(resp)
+}
+
+func (k *kvClient) Del(scope Scope, key string) error {
+	resp, err := k.c.do("DELETE", k.url(scope, key), nil, nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	return checkStatus(resp)
+}
+
+func (k *kvClient) Incr(scope Scope, key string, by int64) (int64, error) {
+	u := fmt.Sprintf("%s/incr?by=%d", k.url(scope, key), by)
+	resp, err := k.c.do("POST", u, nil, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusConflict {
+		return 0, ErrNotCounter
+	}
+	if err := checkStatus(resp); err != nil {
+		return 0, err
+	}
+	var res struct {
+		Value int64 `json:"value"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&res); err != nil {
+		return 0, err
+	}
+	return res.Value, nil
+}
+
+func (k *kvClient) CompareAndSwap(scope Scope, key string, old, new []byte) (bool, error) {
+	if len(new) > MaxKVValueSize {
+		return false, ErrValueTooLarge
+	}
+	req := struct {
+		Old *[]byte `json:"old,omitempty"`
+		New []byte  `json:"new"`
+	}{New: new}
+	if old != nil {
+		req.Old = &old
+	}
+	body, err := json.Marshal(&req)
+	if err != nil {
+		return false, err
+	}
+	header := http.Header{}
+	header.Set("Content-Type", "application/json")
+	resp, err := k.c.do("POST", k.url(scope, key)+"/cas", body, header)
+	if err != nil {
+		return false, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusPreconditionFailed {
+		return false, nil
+	}
+	if err := checkStatus(resp); err != nil {
+		return false, err
+	}
+	return true, nil
+}
@@ -0,0 +1,101 @@
+package client
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"testing"
+)
+
+func TestKVRequests(t *testing.T) {
+	c, s := newTestClient(t)
+	var stored []byte
+	s.handle("/kv/bot/checkpoint", func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case "PUT":
+			stored, _ = io.ReadAll(r.Body)
+		case "GET":
+			if stored == nil {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			w.Write(stored)
+		case "DELETE":
+			stored = nil
+		default:
+			t.Errorf("unexpected method %s", r.Method)
+		}
+	})
+	s.handle("/kv/bot/checkpoint/incr", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "POST" || r.URL.Query().Get("by") != "-2" {
+			t.Errorf("unexpected request %s %s", r.Method, r.URL)
+		}
+		w.Write([]byte(`{"value":40}`))
+	})
+	s.handle("/kv/bot/label/incr", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusConflict)
+	})
+
+	kv := c.KV()
+	if err := kv.Put(ScopeBot, "checkpoint", []byte("12345")); err != nil {
+		t.Fatal(err)
+	}
+	if b, err := kv.Get(ScopeBot, "checkpoint"); err != nil || string(b) != "12345" {
+		t.Fatalf("got %q, %v", b, err)
+	}
+	if err := kv.Del(ScopeBot, "checkpoint"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := kv.Get(ScopeBot, "checkpoint"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("got %v, want ErrNotFound", err)
+	}
+	if n, err := kv.Incr(ScopeBot, "checkpoint", -2); err != nil || n != 40 {
+		t.Fatalf("got %d, %v", n, err)
+	}
+	if _, err := kv.Incr(ScopeBot, "label", 1); !errors.Is(err, ErrNotCounter) {
+		t.Fatalf("got %v, want ErrNotCounter", err)
+	}
+	if err := kv.Put(ScopeBot, "checkpoint", make([]byte, MaxKVValueSize+1)); !errors.Is(err, ErrValueTooLarge) {
+		t.Fatalf("got %v, want ErrValueTooLarge", err)
+	}
+}
+
+func TestKVCompareAndSwap(t *testing.T) {
+	c, s := newTestClient(t)
+	current := []byte("a")
+	s.handle("/kv/owner/leader/cas", func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Old *[]byte `json:"old"`
+			New []byte  `json:"new"`
+		}
+		if r.Method != "POST" || r.Header.Get("Content-Type") != "application/json" {
+			t.Errorf("unexpected request %s %s", r.Method, r.URL)
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Error(err)
+		}
+		// a nil old is sent as a missing field, and only swaps a missing key
+		if (req.Old == nil) != (current == nil) || (req.Old != nil && string(*req.Old) != string(current)) {
+			w.WriteHeader(http.StatusPreconditionFailed)
+			return
+		}
+		current = req.New
+	})
+
+	kv := c.KV()
+	if ok, err := kv.CompareAndSwap(ScopeOwner, "leader", []byte("a"), []byte("b")); err != nil || !ok {
+		t.Fatalf("got %v, %v", ok, err)
+	}
+	if ok, err := kv.CompareAndSwap(ScopeOwner, "leader", []byte("a"), []byte("c")); err != nil || ok {
+		t.Fatalf("swapped a stale value: %v, %v", ok, err)
+	}
+	if ok, err := kv.CompareAndSwap(ScopeOwner, "leader", nil, []byte("c")); err != nil || ok {
+		t.Fatalf("swapped an existing key as missing: %v, %v", ok, err)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if string(current) != "b" {
+		t.Fatalf("stored %q", current)
+	}
+}
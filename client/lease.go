@@ -0,0 +1,114 @@
+package client
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// MinLeaseTTL is the shortest lease, as the server counts whole seconds
+const MinLeaseTTL = time.Second
+
+// Lease holds a lock and renews it in the background until it is released or lost
+type Lease struct {
+	locks Locks
+	scope Scope
+	name  string
+	ttl   time.Duration
+
+	mu    sync.Mutex
+	grant *LockGrant
+	err   error
+
+	lost    chan struct{}
+	stop    chan struct{}
+	done    chan struct{}
+	endOnce sync.Once
+}
+
+// AcquireLease takes the lock, returning ErrLockHeld if another scanner holds it.
+// The lease is renewed every ttl/3 (ttl is at least MinLeaseTTL); renewal errors are retried until the lease expires.
+func AcquireLease(c LockClient, scope Scope, name string, ttl time.Duration) (*Lease, error) {
+	if ttl < MinLeaseTTL {
+		return nil, fmt.Errorf("lease ttl must be at least %v", MinLeaseTTL)
+	}
+	grant, err := c.Locks().Acquire(scope, name, ttl)
+	if err != nil {
+		return nil, err
+	}
+	l := &Lease{
+		locks: c.Locks(),
+		scope: scope,
+		name:  name,
+		ttl:   ttl,
+		grant: grant,
+		lost:  make(chan struct{}),
+		stop:  make(chan struct{}),
+		done:  make(chan struct{}),
+	}
+	go l.run()
+	return l, nil
+}
+
+// Token is the fencing token of the lease
+func (l *Lease) Token() int64 {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.grant.Token
+}
+
+// Lost is closed when the lease could not be renewed; work guarded by it should stop
+func (l *Lease) Lost() <-chan struct{} {
+	return l.lost
+}
+
+// Err returns why the lease was lost, or nil while it is held
+func (l *Lease) Err() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.err
+}
+
+// Release stops renewing and frees the lock for other scanners.  Releasing a lost lease does nothing.
+func (l *Lease) Release() error {
+	l.endOnce.Do(func() {
+		close(l.stop)
+	})
+	<-l.done
+	if l.Err() != nil {
+		return nil
+	}
+	err := l.locks.Release(l.scope, l.name, l.Token())
+	if errors.Is(err, ErrLeaseLost) {
+		return nil
+	}
+	return err
+}
+
+func (l *Lease) run() {
+	defer close(l.done)
+
+	t := time.NewTicker(l.ttl / 3)
+	defer t.Stop()
+	for {
+		select {
+		case <-l.stop:
+			return
+		case <-t.C:
+		}
+		grant, err := l.locks.Renew(l.scope, l.name, l.Token(), l.ttl)
+		l.mu.Lock()
+		if err == nil {
+			l.grant = grant
+		} else if errors.Is(err, ErrLeaseLost) || time.Now().After(l.grant.ExpiresAt) {
+			l.err = err
+		}
+		failed := l.err != nil
+		l.mu.Unlock()
+		if failed {
+			close(l.lost)
+			return
+		}
+	}
+}
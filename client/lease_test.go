@@ -0,0 +1,184 @@
+package client
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+// lockState is one in-memory lock shared by testLocks of different holders
+type lockState struct {
+	mu       sync.Mutex
+	holder   string
+	token    int64
+	expires  time.Time
+	renewErr error
+	renews   int
+	releases int
+}
+
+// testLocks is a Locks acting as holder id
+type testLocks struct {
+	s  *lockState
+	id string
+}
+
+func (l *testLocks) Locks() Locks {
+	return l
+}
+
+func (l *testLocks) grant() *LockGrant {
+	return &LockGrant{Holder: l.s.holder, Token: l.s.token, ExpiresAt: l.s.expires}
+}
+
+func (l *testLocks) Acquire(scope Scope, name string, ttl time.Duration) (*LockGrant, error) {
+	l.s.mu.Lock()
+	defer l.s.mu.Unlock()
+	if l.s.holder != "" && l.s.holder != l.id && time.Now().Before(l.s.expires) {
+		return nil, ErrLockHeld
+	}
+	l.s.holder, l.s.expires = l.id, time.Now().Add(ttl)
+	l.s.token++
+	return l.grant(), nil
+}
+
+func (l *testLocks) Renew(scope Scope, name string, token int64, ttl time.Duration) (*LockGrant, error) {
+	l.s.mu.Lock()
+	defer l.s.mu.Unlock()
+	l.s.renews++
+	if l.s.renewErr != nil {
+		return nil, l.s.renewErr
+	}
+	if l.s.holder != l.id || l.s.token != token {
+		return nil, ErrLeaseLost
+	}
+	l.s.expires = time.Now().Add(ttl)
+	return l.grant(), nil
+}
+
+func (l *testLocks) Release(scope Scope, name string, token int64) error {
+	l.s.mu.Lock()
+	defer l.s.mu.Unlock()
+	l.s.releases++
+	if l.s.holder != l.id || l.s.token != token {
+		return ErrLeaseLost
+	}
+	l.s.holder = ""
+	return nil
+}
+
+func (l *testLocks) Holder(scope Scope, name string) (*LockGrant, error) {
+	l.s.mu.Lock()
+	defer l.s.mu.Unlock()
+	if l.s.holder == "" || time.Now().After(l.s.expires) {
+		return nil, ErrNotFound
+	}
+	return l.grant(), nil
+}
+
+func (s *lockState) set(f func(s *lockState)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	f(s)
+}
+
+func (s *lockState) get(f func(s *lockState)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	f(s)
+}
+
+func TestAcquireLeaseMinTTL(t *testing.T) {
+	s := &lockState{}
+	if _, err := AcquireLease(&testLocks{s: s, id: "a"}, ScopeBot, "job", time.Nanosecond); err == nil {
+		t.Fatal("expected a ttl under MinLeaseTTL to be rejected")
+	}
+	if s.token != 0 {
+		t.Fatal("the lock was acquired")
+	}
+}
+
+func TestLeaseRenewsAndReleases(t *testing.T) {
+	s := &lockState{}
+	lease, err := AcquireLease(&testLocks{s: s, id: "a"}, ScopeBot, "job", MinLeaseTTL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := AcquireLease(&testLocks{s: s, id: "b"}, ScopeBot, "job", MinLeaseTTL); !errors.Is(err, ErrLockHeld) {
+		t.Fatalf("got %v, want ErrLockHeld", err)
+	}
+
+	time.Sleep(MinLeaseTTL / 2)
+	var renews int
+	s.get(func(s *lockState) { renews = s.renews })
+	if renews == 0 {
+		t.Fatal("the lease was not renewed")
+	}
+	if lease.Err() != nil {
+		t.Fatal(lease.Err())
+	}
+	if err := lease.Release(); err != nil {
+		t.Fatal(err)
+	}
+	s.get(func(s *lockState) {
+		if s.holder != "" || s.releases != 1 {
+			t.Fatalf("the lock is still held by %q after %d releases", s.holder, s.releases)
+		}
+	})
+}
+
+func TestLeaseLost(t *testing.T) {
+	s := &lockState{}
+	lease, err := AcquireLease(&testLocks{s: s, id: "a"}, ScopeBot, "job", MinLeaseTTL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// another scanner took over
+	s.set(func(s *lockState) { s.holder, s.token = "b", s.token+1 })
+
+	select {
+	case <-lease.Lost():
+	case <-time.After(MinLeaseTTL):
+		t.Fatal("the lease was not lost")
+	}
+	if !errors.Is(lease.Err(), ErrLeaseLost) {
+		t.Fatalf("got %v, want ErrLeaseLost", lease.Err())
+	}
+	// releasing a lost lease leaves the new holder alone
+	if err := lease.Release(); err != nil {
+		t.Fatal(err)
+	}
+	s.get(func(s *lockState) {
+		if s.holder != "b" || s.releases != 0 {
+			t.Fatalf("holder %q after %d releases", s.holder, s.releases)
+		}
+	})
+}
+
+func TestLeaseRenewFailure(t *testing.T) {
+	s := &lockState{}
+	lease, err := AcquireLease(&testLocks{s: s, id: "a"}, ScopeBot, "job", MinLeaseTTL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	unavailable := &StatusError{StatusCode: 503}
+	s.set(func(s *lockState) { s.renewErr = unavailable })
+
+	// failed renewals are retried while the lease lasts
+	time.Sleep(MinLeaseTTL / 2)
+	select {
+	case <-lease.Lost():
+		t.Fatal("the lease was lost before it expired")
+	default:
+	}
+
+	select {
+	case <-lease.Lost():
+	case <-time.After(MinLeaseTTL):
+		t.Fatal("the lease outlived its expiry")
+	}
+	if !errors.Is(lease.Err(), unavailable) {
+		t.Fatalf("got %v, want the renewal error", lease.Err())
+	}
+}
@@ -0,0 +1,84 @@
+package client
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestLockRequests(t *testing.T) {
+	c, s := newTestClient(t)
+	expiresAt := time.UnixMilli(1_700_000_060_000)
+	held := false
+	s.handle("/locks/bot/leader", func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		switch r.Method {
+		case "POST":
+			// the ttl is rounded up to whole seconds
+			if q.Get("ttl") != "2" {
+				t.Errorf("unexpected ttl %q", q.Get("ttl"))
+			}
+			if held {
+				w.WriteHeader(http.StatusConflict)
+				return
+			}
+			held = true
+			w.Write([]byte(`{"holder":"0xscanner","token":7,"expiresAt":1700000060000}`))
+		case "GET":
+			if !held {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			w.Write([]byte(`{"holder":"0xscanner","token":7,"expiresAt":1700000060000}`))
+		case "DELETE":
+			if q.Get("token") != "7" || !held {
+				w.WriteHeader(http.StatusConflict)
+				return
+			}
+			held = false
+			w.Write([]byte(`{"holder":"0xscanner","token":7,"expiresAt":1700000060000}`))
+		}
+	})
+	s.handle("/locks/bot/leader/renew", func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		if r.Method != "POST" || q.Get("ttl") != "60" {
+			t.Errorf("unexpected request %s %s", r.Method, r.URL)
+		}
+		if q.Get("token") != "7" {
+			w.WriteHeader(http.StatusConflict)
+			return
+		}
+		w.Write([]byte(`{"holder":"0xscanner","token":7,"expiresAt":1700000120000}`))
+	})
+
+	locks := c.Locks()
+	g, err := locks.Acquire(ScopeBot, "leader", 1500*time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if g.Holder != "0xscanner" || g.Token != 7 || !g.ExpiresAt.Equal(expiresAt) {
+		t.Fatalf("got %+v", g)
+	}
+	if _, err := locks.Acquire(ScopeBot, "leader", 2*time.Second); !errors.Is(err, ErrLockHeld) {
+		t.Fatalf("got %v, want ErrLockHeld", err)
+	}
+	if h, err := locks.Holder(ScopeBot, "leader"); err != nil || h.Token != 7 {
+		t.Fatalf("got %+v, %v", h, err)
+	}
+	if g, err := locks.Renew(ScopeBot, "leader", 7, time.Minute); err != nil || !g.ExpiresAt.Equal(expiresAt.Add(time.Minute)) {
+		t.Fatalf("got %+v, %v", g, err)
+	}
+	if _, err := locks.Renew(ScopeBot, "leader", 6, time.Minute); !errors.Is(err, ErrLeaseLost) {
+		t.Fatalf("got %v, want ErrLeaseLost", err)
+	}
+	if err := locks.Release(ScopeBot, "leader", 7); err != nil {
+		t.Fatal(err)
+	}
+	if err := locks.Release(ScopeBot, "leader", 7); !errors.Is(err, ErrLeaseLost) {
+		t.Fatalf("got %v, want ErrLeaseLost", err)
+	}
+	if _, err := locks.Holder(ScopeBot, "leader"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("got %v, want ErrNotFound", err)
+	}
+}
@@ -0,0 +1,63 @@
+package client
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/url"
+)
+
+// PrefixClient deletes objects by key prefix
+type PrefixClient interface {
+	DelPrefix(scope Scope, prefix string) (int, error)
+}
+
+var _ PrefixClient = (*client)(nil)
+
+type prefixDeleteResponse struct {
+	Deleted   int    `json:"deleted"`
+	Truncated bool   `json:"truncated"`
+	Next      string `json:"next"`
+}
+
+// DelPrefix deletes every object in the scope whose key starts with prefix, returning how many were removed.
+// An empty prefix wipes the whole scope.  Chunked objects are removed along with their parts.
+// ScopeBot deletes never remove ScopeScanner objects, although those are stored under the bot's prefix.
+func (c *client) DelPrefix(scope Scope, prefix string) (int, error) {
+	u := fmt.Sprintf("%s/database/%s?prefix=%s&confirm=true", c.apiHost, scope, url.QueryEscape(prefix))
+	deleted := 0
+	after := ""
+	for {
+		reqURL := u
+		if after != "" {
+			reqURL += "&after=" + url.QueryEscape(after)
+		}
+		resp, err := c.do("DELETE", reqURL, nil, nil)
+		if err != nil {
+			return deleted, err
+		}
+		var res prefixDeleteResponse
+		err = checkStatus(resp)
+		if err == nil {
+			var b []byte
+			b, err = io.ReadAll(resp.Body)
+			if err == nil {
+				err = json.Unmarshal(b, &res)
+			}
+		}
+		resp.Body.Close()
+		if err != nil {
+			return deleted, err
+		}
+		deleted += res.Deleted
+		// the server removes a bounded number of objects per request, and the next one continues after the last key
+		// it examined, so objects it skips are not listed again
+		if !res.Truncated {
+			return deleted, nil
+		}
+		if res.Next == "" || res.Next == after {
+			return deleted, fmt.Errorf("prefix delete made no progress after %q", after)
+		}
+		after = res.Next
+	}
+}
@@ -0,0 +1,48 @@
+package client
+
+import (
+	"net/http"
+	"reflect"
+	"testing"
+)
+
+func TestDelPrefixContinuesAfterNext(t *testing.T) {
+	c, s := newTestClient(t)
+	var afters []string
+	s.handle("/database/bot", func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		if r.Method != "DELETE" || q.Get("prefix") != "labels/" || q.Get("confirm") != "true" {
+			t.Errorf("unexpected request %s %s", r.Method, r.URL)
+		}
+		afters = append(afters, q.Get("after"))
+		switch q.Get("after") {
+		case "":
+			w.Write([]byte(`{"deleted":0,"truncated":true,"next":"labels/m"}`))
+		case "labels/m":
+			w.Write([]byte(`{"deleted":3,"truncated":true,"next":"labels/t"}`))
+		default:
+			w.Write([]byte(`{"deleted":2,"truncated":false}`))
+		}
+	})
+
+	deleted, err := c.DelPrefix(ScopeBot, "labels/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if deleted != 5 {
+		t.Fatalf("deleted %d objects, want 5", deleted)
+	}
+	if want := []string{"", "labels/m", "labels/t"}; !reflect.DeepEqual(afters, want) {
+		t.Fatalf("continued after %q, want %q", afters, want)
+	}
+}
+
+func TestDelPrefixStopsWithoutProgress(t *testing.T) {
+	c, s := newTestClient(t)
+	s.handle("/database/bot", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"deleted":0,"truncated":true,"next":"a"}`))
+	})
+	if _, err := c.DelPrefix(ScopeBot, ""); err == nil {
+		t.Fatal("expected an error when the server does not move forward")
+	}
+}
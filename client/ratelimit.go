@@ -0,0 +1,123 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// contentionRetry is how long to back off when the server gave up on a bucket other takes kept updating
+const contentionRetry = 50 * time.Millisecond
+
+// MinRate is the slowest refill the server accepts, in tokens per second
+const MinRate = 0.001
+
+// ErrLimitMismatch is returned by Take when the bucket was created with another limit; every caller of a bucket
+// must use the same one until the bucket is full again and expires
+var ErrLimitMismatch = errors.New("the rate limit bucket has a different limit")
+
+// RateLimit is a token bucket refilling at Rate tokens per second (at least MinRate) up to Burst tokens
+// (0 means Rate, rounded up).  Every caller of a bucket must use the same limit.
+type RateLimit struct {
+	Rate  float64
+	Burst int
+}
+
+// RateLimits are token buckets shared by every instance that can see the scope, e.g. a ScopeOwner bucket
+// holds one quota for all the owner's bots on all scanners
+type RateLimits interface {
+	// Take removes n tokens from the bucket.  It returns 0 if they were taken, otherwise how long to wait before trying again.
+	Take(scope Scope, name string, n int, limit RateLimit) (time.Duration, error)
+}
+
+// RateLimitClient is implemented by clients that can take from shared rate limits
+type RateLimitClient interface {
+	RateLimits() RateLimits
+}
+
+var _ RateLimitClient = (*client)(nil)
+
+type rateLimitClient struct {
+	c *client
+}
+
+// RateLimits returns the rate limit API, using the same authentication as the client
+func (c *client) RateLimits() RateLimits {
+	return &rateLimitClient{c: c}
+}
+
+func (r *rateLimitClient) Take(scope Scope, name string, n int, limit RateLimit) (time.Duration, error) {
+	u := fmt.Sprintf("%s/ratelimit/%s/%s/take?n=%d&rate=%s", r.c.apiHost, scope, name, n, strconv.FormatFloat(limit.Rate, 'f', -1, 64))
+	if limit.Burst > 0 {
+		u = fmt.Sprintf("%s&burst=%d", u, limit.Burst)
+	}
+	resp, err := r.c.do("POST", u, nil, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	switch resp.StatusCode {
+	case http.StatusTooManyRequests:
+		var res struct {
+			RetryAfter int64 `json:"retryAfter"`
+		}
+		if err := json.NewDecoder(resp.Body).Decode(&res); err != nil {
+			return 0, err
+		}
+		return time.Duration(res.RetryAfter) * time.Millisecond, nil
+	case http.StatusConflict:
+		return contentionRetry, nil
+	case http.StatusPreconditionFailed:
+		return 0, ErrLimitMismatch
+	}
+	return 0, checkStatus(resp)
+}
+
+// RateLimiter keeps every instance sharing a bucket under one limit, e.g. the quota of an external API key
+type RateLimiter struct {
+	limits RateLimits
+	scope  Scope
+	name   string
+	limit  RateLimit
+}
+
+// NewRateLimiter returns a limiter on the bucket called name; each call takes tokens from the server
+func NewRateLimiter(c RateLimitClient, scope Scope, name string, limit RateLimit) *RateLimiter {
+	return &RateLimiter{limits: c.RateLimits(), scope: scope, name: name, limit: limit}
+}
+
+// Allow takes a token if one is available, without waiting
+func (l *RateLimiter) Allow() (bool, error) {
+	wait, err := l.limits.Take(l.scope, l.name, 1, l.limit)
+	return err == nil && wait == 0, err
+}
+
+// Wait blocks until a token is taken or ctx is done
+func (l *RateLimiter) Wait(ctx context.Context) error {
+	return l.WaitN(ctx, 1)
+}
+
+// WaitN blocks until n tokens are taken or ctx is done.  Instances woken at the same time compete for the
+// refilled tokens, so waits are spread out a little and the losers wait again.
+func (l *RateLimiter) WaitN(ctx context.Context, n int) error {
+	for {
+		wait, err := l.limits.Take(l.scope, l.name, n, l.limit)
+		if err != nil {
+			return err
+		}
+		if wait == 0 {
+			return nil
+		}
+		wait += time.Duration(rand.Int63n(int64(wait)/10 + 1))
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(wait):
+		}
+	}
+}
@@ -0,0 +1,107 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+)
+
+// scriptedLimits returns the waits in order, then 0
+type scriptedLimits struct {
+	mu    sync.Mutex
+	waits []time.Duration
+	err   error
+	takes int
+}
+
+func (s *scriptedLimits) Take(scope Scope, name string, n int, limit RateLimit) (time.Duration, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.takes++
+	if s.err != nil {
+		return 0, s.err
+	}
+	if len(s.waits) == 0 {
+		return 0, nil
+	}
+	wait := s.waits[0]
+	s.waits = s.waits[1:]
+	return wait, nil
+}
+
+func TestRateLimiterWaitN(t *testing.T) {
+	limits := &scriptedLimits{waits: []time.Duration{10 * time.Millisecond, 20 * time.Millisecond}}
+	l := &RateLimiter{limits: limits, scope: ScopeOwner, name: "api", limit: RateLimit{Rate: 1}}
+
+	start := time.Now()
+	if err := l.WaitN(context.Background(), 1); err != nil {
+		t.Fatal(err)
+	}
+	if limits.takes != 3 {
+		t.Fatalf("took %d times, want 3", limits.takes)
+	}
+	// each wait is spread by up to a tenth
+	if elapsed := time.Since(start); elapsed < 30*time.Millisecond {
+		t.Fatalf("returned after %v, before the waits", elapsed)
+	}
+}
+
+func TestRateLimiterWaitNCancelled(t *testing.T) {
+	limits := &scriptedLimits{waits: []time.Duration{time.Hour}}
+	l := &RateLimiter{limits: limits, scope: ScopeOwner, name: "api", limit: RateLimit{Rate: 1}}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := l.WaitN(ctx, 1); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("got %v, want context.DeadlineExceeded", err)
+	}
+
+	limits.err = ErrLimitMismatch
+	if err := l.Wait(context.Background()); !errors.Is(err, ErrLimitMismatch) {
+		t.Fatalf("got %v, want ErrLimitMismatch", err)
+	}
+	if ok, err := l.Allow(); ok || !errors.Is(err, ErrLimitMismatch) {
+		t.Fatalf("Allow: got %v, %v", ok, err)
+	}
+}
+
+func TestTakeRequest(t *testing.T) {
+	c, s := newTestClient(t)
+	status := http.StatusOK
+	s.handle("/ratelimit/owner/api/take", func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		if r.Method != "POST" || q.Get("n") != "2" || q.Get("rate") != "0.5" || q.Get("burst") != "4" {
+			t.Errorf("unexpected request %s %s", r.Method, r.URL)
+		}
+		w.WriteHeader(status)
+		switch status {
+		case http.StatusOK:
+			w.Write([]byte(`{"remaining":2}`))
+		case http.StatusTooManyRequests:
+			w.Write([]byte(`{"message":"rate limited","retryAfter":1500}`))
+		}
+	})
+	limit := RateLimit{Rate: 0.5, Burst: 4}
+
+	for _, tc := range []struct {
+		status int
+		wait   time.Duration
+		err    error
+	}{
+		{http.StatusOK, 0, nil},
+		{http.StatusTooManyRequests, 1500 * time.Millisecond, nil},
+		{http.StatusConflict, contentionRetry, nil},
+		{http.StatusPreconditionFailed, 0, ErrLimitMismatch},
+	} {
+		s.mu.Lock()
+		status = tc.status
+		s.mu.Unlock()
+		wait, err := c.RateLimits().Take(ScopeOwner, "api", 2, limit)
+		if wait != tc.wait || !errors.Is(err, tc.err) {
+			t.Fatalf("status %d: got %v, %v, want %v, %v", tc.status, wait, err, tc.wait, tc.err)
+		}
+	}
+}
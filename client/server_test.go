@@ -0,0 +1,180 @@
+package client
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+)
+
+type testObject struct {
+	body   []byte
+	etag   string
+	header http.Header
+}
+
+// testServer is an in-memory stand-in for the object API: GET, HEAD, PUT and DELETE on /database/{scope}/{key},
+// with ETags, If-None-Match, If-Match, Range, Content-Type, X-Object-Encoding and X-Meta-* headers, and POST
+// /database/{scope}/{key}/copy and /move.
+// Watches (?watch=true&since=) answer right away instead of waiting for a change.
+// It also serves the JWT provider's /create, and handlers registered for other endpoints.
+type testServer struct {
+	*httptest.Server
+	mu       sync.Mutex
+	objects  map[string]*testObject
+	requests []string
+	// status, when set and non-zero for a request, is returned instead of handling it
+	status func(r *http.Request) int
+	// handlers serve the endpoints beyond single objects, by path
+	handlers map[string]http.HandlerFunc
+}
+
+func newTestServer(t *testing.T) *testServer {
+	s := &testServer{objects: make(map[string]*testObject), handlers: make(map[string]http.HandlerFunc)}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.serve))
+	t.Cleanup(s.Close)
+	return s
+}
+
+// newTestClient returns a client of the test server
+func newTestClient(t *testing.T, opts ...Option) (*client, *testServer) {
+	s := newTestServer(t)
+	u, err := url.Parse(s.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, err := NewClient(s.URL, u.Hostname(), u.Port(), opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c.(*client), s
+}
+
+// count returns how many requests were made with the method
+func (s *testServer) count(method string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	n := 0
+	for _, r := range s.requests {
+		if strings.HasPrefix(r, method+" ") {
+			n++
+		}
+	}
+	return n
+}
+
+// handle serves path with h instead of the object store; h runs with the server locked
+func (s *testServer) handle(path string, h http.HandlerFunc) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.handlers[path] = h
+}
+
+func (s *testServer) object(key string) *testObject {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.objects[key]
+}
+
+func (s *testServer) serve(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path == "/create" {
+		w.Write([]byte(`{"token":"test"}`))
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.requests = append(s.requests, r.Method+" "+r.URL.Path)
+	if s.status != nil {
+		if code := s.status(r); code != 0 {
+			w.WriteHeader(code)
+			return
+		}
+	}
+	if h := s.handlers[r.URL.Path]; h != nil {
+		h(w, r)
+		return
+	}
+	key := strings.TrimPrefix(r.URL.Path, "/database/")
+	obj := s.objects[key]
+
+	switch r.Method {
+	case "GET", "HEAD":
+		if q := r.URL.Query(); q.Get("watch") == "true" {
+			since := q.Get("since")
+			if (obj == nil && since == "") || (obj != nil && obj.etag == since) {
+				w.WriteHeader(http.StatusNotModified)
+				return
+			}
+		}
+		if obj == nil {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		if inm := r.Header.Get("If-None-Match"); inm != "" && inm == obj.etag {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		for k, v := range obj.header {
+			w.Header()[k] = v
+		}
+		w.Header().Set("ETag", obj.etag)
+		w.Header().Set("X-Object-Size", strconv.Itoa(len(obj.body)))
+		var first, last int
+		if n, _ := fmt.Sscanf(r.Header.Get("Range"), "bytes=%d-%d", &first, &last); n == 2 && r.Method == "GET" {
+			if last >= len(obj.body) {
+				last = len(obj.body) - 1
+			}
+			w.Header().Set("Content-Range", fmt.Sprintf("bytes %d-%d/%d", first, last, len(obj.body)))
+			w.WriteHeader(http.StatusPartialContent)
+			w.Write(obj.body[first : last+1])
+			return
+		}
+		if r.Method == "GET" {
+			w.Write(obj.body)
+		}
+	case "PUT":
+		if im := r.Header.Get("If-Match"); im != "" && (obj == nil || (im != "*" && im != obj.etag)) {
+			w.WriteHeader(http.StatusPreconditionFailed)
+			return
+		}
+		if r.Header.Get("If-None-Match") == "*" && obj != nil {
+			w.WriteHeader(http.StatusPreconditionFailed)
+			return
+		}
+		b, _ := io.ReadAll(r.Body)
+		sum := sha256.Sum256(b)
+		h := http.Header{}
+		for k, v := range r.Header {
+			if k == "Content-Type" || k == "X-Object-Encoding" || strings.HasPrefix(k, "X-Meta-") {
+				h[k] = v
+			}
+		}
+		s.objects[key] = &testObject{body: b, etag: `"` + hex.EncodeToString(sum[:8]) + `"`, header: h}
+		w.Write([]byte(`{"message":"OK"}`))
+	case "POST":
+		i := strings.LastIndex(key, "/")
+		src, action := key[:i], key[i+1:]
+		if s.objects[src] == nil {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		copied := *s.objects[src]
+		s.objects[r.URL.Query().Get("to")] = &copied
+		if action == "move" {
+			delete(s.objects, src)
+		}
+		w.Write([]byte(`{"message":"OK"}`))
+	case "DELETE":
+		delete(s.objects, key)
+		w.Write([]byte(`{"message":"OK"}`))
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
@@ -0,0 +1,94 @@
+package client
+
+import (
+	"context"
+	"errors"
+)
+
+// ErrScopeNotShared is returned by Shared clients for ScopeScanner, which is private to the scanner
+var ErrScopeNotShared = errors.New("only bot, owner and public scopes can be shared")
+
+// Shared reads and writes the objects another bot or owner granted access to through their ACL,
+// or reads the public scope of another bot.
+// Objects are decoded like the client's own, so objects the grantor encrypted need their keys.
+type Shared interface {
+	Get(objID string) ([]byte, error)
+	GetWithInfo(objID string) ([]byte, *ObjectInfo, error)
+	Put(objID string, payload []byte) error
+	Del(objID string) error
+	Stat(objID string) (*ObjectInfo, error)
+	Watch(ctx context.Context, objID string) <-chan *ObjectUpdate
+}
+
+// SharingClient reaches the objects other bots and owners share
+type SharingClient interface {
+	Shared(scope Scope, id string) Shared
+}
+
+var _ SharingClient = (*client)(nil)
+
+type sharedClient struct {
+	c     *client
+	scope Scope
+}
+
+// Shared addresses the bot scope of the bot id (scope ScopeBot), the owner scope of the owner id (scope ScopeOwner),
+// or the public scope of the bot id (scope ScopePublic).  Calls fail with ErrForbidden unless the bot or owner granted
+// access to the object; public objects can be read by any bot, but only written by their own.
+func (c *client) Shared(scope Scope, id string) Shared {
+	cp := *c
+	cp.namespace = id
+	return &sharedClient{c: &cp, scope: scope}
+}
+
+func (s *sharedClient) check() error {
+	if s.scope == ScopeScanner {
+		return ErrScopeNotShared
+	}
+	return nil
+}
+
+func (s *sharedClient) Get(objID string) ([]byte, error) {
+	if err := s.check(); err != nil {
+		return nil, err
+	}
+	return s.c.Get(s.scope, objID)
+}
+
+func (s *sharedClient) GetWithInfo(objID string) ([]byte, *ObjectInfo, error) {
+	if err := s.check(); err != nil {
+		return nil, nil, err
+	}
+	return s.c.GetWithInfo(s.scope, objID)
+}
+
+func (s *sharedClient) Put(objID string, payload []byte) error {
+	if err := s.check(); err != nil {
+		return err
+	}
+	return s.c.Put(s.scope, objID, payload)
+}
+
+func (s *sharedClient) Del(objID string) error {
+	if err := s.check(); err != nil {
+		return err
+	}
+	return s.c.Del(s.scope, objID)
+}
+
+func (s *sharedClient) Stat(objID string) (*ObjectInfo, error) {
+	if err := s.check(); err != nil {
+		return nil, err
+	}
+	return s.c.Stat(s.scope, objID)
+}
+
+func (s *sharedClient) Watch(ctx context.Context, objID string) <-chan *ObjectUpdate {
+	if err := s.check(); err != nil {
+		ch := make(chan *ObjectUpdate, 1)
+		ch <- &ObjectUpdate{Err: err}
+		close(ch)
+		return ch
+	}
+	return s.c.Watch(ctx, s.scope, objID)
+}
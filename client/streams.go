@@ -0,0 +1,127 @@
+package client
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// StreamRecord is one entry of an append-only stream
+type StreamRecord struct {
+	Seq   int64
+	Value []byte
+	Time  time.Time
+}
+
+// Streams are append-only logs that several writers (e.g. the same bot on different scanners) add to
+// without overwriting each other.  Records are numbered from 1 without gaps, in the order they were appended.
+// Like KV values, records are limited to MaxKVValueSize and stored as given.
+type Streams interface {
+	// Append adds a record to the end of the stream and returns its sequence number
+	Append(scope Scope, name string, value []byte) (int64, error)
+	// Read returns up to limit records starting at sequence number from, and the sequence number to read next.
+	// A limit of 0 uses the server default; fewer records than limit does not mean the end of the stream was reached.
+	Read(scope Scope, name string, from int64, limit int) ([]StreamRecord, int64, error)
+}
+
+// StreamClient is implemented by clients that can append to and read streams
+type StreamClient interface {
+	Streams() Streams
+}
+
+var _ StreamClient = (*client)(nil)
+
+type streamsClient struct {
+	c *client
+}
+
+// Streams returns the stream API, using the same authentication as the client
+func (c *client) Streams() Streams {
+	return &streamsClient{c: c}
+}
+
+func (s *streamsClient) url(scope Scope, name string) string {
+	return fmt.Sprintf("%s/streams/%s/%s", s.c.apiHost, scope, name)
+}
+
+func (s *streamsClient) Append(scope Scope, name string, value []byte) (int64, error) {
+	if len(value) > MaxKVValueSize {
+		return 0, ErrValueTooLarge
+	}
+	resp, err := s.c.do("POST", s.url(scope, name), value, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	if err := checkStatus(resp); err != nil {
+		return 0, err
+	}
+	var res struct {
+		Seq int64 `json:"seq"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&res); err != nil {
+		return 0, err
+	}
+	return res.Seq, nil
+}
+
+func (s *streamsClient) Read(scope Scope, name string, from int64, limit int) ([]StreamRecord, int64, error) {
+	u := fmt.Sprintf("%s?from=%d", s.url(scope, name), from)
+	if limit > 0 {
+		u = fmt.Sprintf("%s&limit=%d", u, limit)
+	}
+	resp, err := s.c.do("GET", u, nil, nil)
+	if err != nil {
+		return nil, from, err
+	}
+	defer resp.Body.Close()
+	if err := checkStatus(resp); err != nil {
+		return nil, from, err
+	}
+	var res struct {
+		Records []struct {
+			Seq   int64  `json:"seq"`
+			Value []byte `json:"value"`
+			Time  int64  `json:"time"`
+		} `json:"records"`
+		Next int64 `json:"next"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&res); err != nil {
+		return nil, from, err
+	}
+	recs := make([]StreamRecord, 0, len(res.Records))
+	for _, r := range res.Records {
+		recs = append(recs, StreamRecord{Seq: r.Seq, Value: r.Value, Time: time.UnixMilli(r.Time)})
+	}
+	return recs, res.Next, nil
+}
+
+// Cursor tails a stream, remembering where the last read stopped.
+// Persist Position (e.g. in KV) to resume after a restart without reprocessing records.
+type Cursor struct {
+	streams Streams
+	scope   Scope
+	name    string
+	next    int64
+}
+
+// NewCursor returns a cursor that reads the stream from sequence number from; use 1 for the beginning
+func NewCursor(streams Streams, scope Scope, name string, from int64) *Cursor {
+	return &Cursor{streams: streams, scope: scope, name: name, next: from}
+}
+
+// Next returns the records appended since the previous call, up to limit, and advances the cursor.
+// It returns no records once the cursor has caught up with the stream.
+func (cu *Cursor) Next(limit int) ([]StreamRecord, error) {
+	recs, next, err := cu.streams.Read(cu.scope, cu.name, cu.next, limit)
+	if err != nil {
+		return nil, err
+	}
+	cu.next = next
+	return recs, nil
+}
+
+// Position is the sequence number of the next record the cursor will return
+func (cu *Cursor) Position() int64 {
+	return cu.next
+}
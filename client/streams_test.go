@@ -0,0 +1,50 @@
+package client
+
+import (
+	"io"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestStreamRequests(t *testing.T) {
+	c, s := newTestClient(t)
+	var appended []string
+	s.handle("/streams/owner/alerts", func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		switch r.Method {
+		case "POST":
+			b, _ := io.ReadAll(r.Body)
+			appended = append(appended, string(b))
+			w.Write([]byte(`{"seq":12}`))
+		case "GET":
+			if q.Get("from") != "11" || q.Get("limit") != "2" {
+				t.Errorf("unexpected query %s", r.URL.RawQuery)
+			}
+			// values are base64, times are millis
+			w.Write([]byte(`{"records":[{"seq":11,"value":"YQ==","time":1700000000000},{"seq":12,"value":"Yg==","time":1700000001000}],"next":13}`))
+		}
+	})
+
+	streams := c.Streams()
+	if seq, err := streams.Append(ScopeOwner, "alerts", []byte("b")); err != nil || seq != 12 {
+		t.Fatalf("got %d, %v", seq, err)
+	}
+	cursor := NewCursor(streams, ScopeOwner, "alerts", 11)
+	recs, err := cursor.Next(2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(recs) != 2 || recs[0].Seq != 11 || string(recs[0].Value) != "a" || string(recs[1].Value) != "b" ||
+		!recs[1].Time.Equal(time.UnixMilli(1_700_000_001_000)) {
+		t.Fatalf("got %+v", recs)
+	}
+	if cursor.Position() != 13 {
+		t.Fatalf("cursor at %d, want 13", cursor.Position())
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(appended) != 1 || appended[0] != "b" {
+		t.Fatalf("appended %q", appended)
+	}
+}
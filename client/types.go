@@ -1,5 +1,25 @@
 package client
 
+import "time"
+
 type CreateJWTResponse struct {
 	Token string `json:"token"`
 }
+
+// ObjectInfo describes a stored object
+type ObjectInfo struct {
+	Size            int64
+	ETag            string
+	LastModified    time.Time
+	ContentType     string
+	ContentEncoding string
+	ChecksumSHA256  string
+	Metadata        map[string]string
+}
+
+// PutOptions sets an object's content type and custom metadata.
+// Metadata keys are case-insensitive and returned lowercased; the server bounds their number and size.
+type PutOptions struct {
+	ContentType string
+	Metadata    map[string]string
+}
@@ -0,0 +1,146 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"time"
+)
+
+// WatchClient follows objects as they change
+type WatchClient interface {
+	Watch(ctx context.Context, scope Scope, objID string) <-chan *ObjectUpdate
+	Changes(scope Scope, prefix string, from int64, limit int) ([]Change, int64, error)
+}
+
+var _ WatchClient = (*client)(nil)
+
+// watchRetryDelay is how long Watch waits after a failed request before trying again
+var watchRetryDelay = time.Second
+
+// ObjectUpdate is delivered by Watch when an object is written or deleted, or when watching it failed
+type ObjectUpdate struct {
+	Payload []byte
+	Info    *ObjectInfo
+	Deleted bool
+	Err     error
+}
+
+// Watch delivers the object as soon as it is written, starting with its current value if it exists,
+// and an update with Deleted set when it is removed.  Errors are delivered too and watching carries on.
+// The channel is closed once ctx is done and the request in flight (at most a few seconds) returns.
+func (c *client) Watch(ctx context.Context, scope Scope, objID string) <-chan *ObjectUpdate {
+	ch := make(chan *ObjectUpdate)
+	go func() {
+		defer close(ch)
+		var etag string
+		for ctx.Err() == nil {
+			u := addQuery(c.objURL(scope, objID), "watch=true&since="+url.QueryEscape(etag))
+			update, next := c.watchOnce(scope, objID, u, etag)
+			if update == nil {
+				// the server gave up waiting, nothing changed
+				continue
+			}
+			select {
+			case ch <- update:
+			case <-ctx.Done():
+				return
+			}
+			if update.Err != nil {
+				select {
+				case <-time.After(watchRetryDelay):
+				case <-ctx.Done():
+				}
+			}
+			etag = next
+		}
+	}()
+	return ch
+}
+
+// watchOnce waits for one change, returning nil if there was none, and the etag to watch from next
+func (c *client) watchOnce(scope Scope, objID, u, etag string) (*ObjectUpdate, string) {
+	b, h, err := c.getURL(u, http.Header{})
+	var se *StatusError
+	switch {
+	case errors.As(err, &se) && se.StatusCode >= 500:
+		// keep watching from the same etag; a failing server says nothing about the object
+		return &ObjectUpdate{Err: err}, etag
+	case errors.Is(err, ErrNotModified):
+		return nil, etag
+	case errors.Is(err, ErrNotFound):
+		if etag == "" {
+			// not created yet
+			return nil, etag
+		}
+		return &ObjectUpdate{Deleted: true}, ""
+	case err != nil:
+		return &ObjectUpdate{Err: err}, etag
+	}
+	b, err = c.decodeObject(scope, objID, b, h)
+	if err != nil {
+		return &ObjectUpdate{Err: err}, h.Get("ETag")
+	}
+	return &ObjectUpdate{Payload: b, Info: objectInfo(h)}, h.Get("ETag")
+}
+
+const (
+	ChangePut       = "put"
+	ChangeDelete    = "delete"
+	ChangeDelPrefix = "delPrefix"
+)
+
+// Change is a write recorded in a scope's change feed; for ChangeDelPrefix, ObjID is the deleted prefix
+type Change struct {
+	Seq   int64
+	Op    string
+	ObjID string
+	ETag  string
+	Time  time.Time
+}
+
+// partPattern matches the part objects of chunked objects; only the write of the object itself is a change
+var partPattern = regexp.MustCompile(`\.part-[^/]+-\d{5}$`)
+
+// Changes lists writes to objects under prefix in a scope, starting at sequence number from (1 is the beginning),
+// and returns the sequence number to continue from.  Fewer changes than limit does not mean the end was reached;
+// the feed is caught up when the returned sequence number equals from.  A limit of 0 uses the server default.
+func (c *client) Changes(scope Scope, prefix string, from int64, limit int) ([]Change, int64, error) {
+	u := fmt.Sprintf("%s/changes/%s?from=%d&prefix=%s", c.apiHost, scope, from, url.QueryEscape(prefix))
+	if limit > 0 {
+		u = fmt.Sprintf("%s&limit=%d", u, limit)
+	}
+	resp, err := c.do("GET", u, nil, nil)
+	if err != nil {
+		return nil, from, err
+	}
+	defer resp.Body.Close()
+	if err := checkStatus(resp); err != nil {
+		return nil, from, err
+	}
+	var res struct {
+		Changes []struct {
+			Seq  int64  `json:"seq"`
+			Op   string `json:"op"`
+			Key  string `json:"key"`
+			ETag string `json:"etag"`
+			Time int64  `json:"time"`
+		} `json:"changes"`
+		Next int64 `json:"next"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&res); err != nil {
+		return nil, from, err
+	}
+	changes := make([]Change, 0, len(res.Changes))
+	for _, ch := range res.Changes {
+		if partPattern.MatchString(ch.Key) {
+			continue
+		}
+		changes = append(changes, Change{Seq: ch.Seq, Op: ch.Op, ObjID: ch.Key, ETag: ch.ETag, Time: time.UnixMilli(ch.Time)})
+	}
+	return changes, res.Next, nil
+}
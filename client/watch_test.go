@@ -0,0 +1,74 @@
+package client
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWatchServerErrors(t *testing.T) {
+	watchRetryDelay = time.Millisecond
+	defer func() { watchRetryDelay = time.Second }()
+	c, s := newTestClient(t)
+	if err := c.Put(ScopeBot, "config.json", []byte("v1")); err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	updates := c.Watch(ctx, ScopeBot, "config.json")
+
+	next := func() *ObjectUpdate {
+		select {
+		case u := <-updates:
+			return u
+		case <-ctx.Done():
+			t.Fatal("no update")
+			return nil
+		}
+	}
+	if u := next(); string(u.Payload) != "v1" {
+		t.Fatalf("got %+v, want v1", u)
+	}
+
+	// a failing server is reported, but is not mistaken for a deletion
+	failures := 2
+	s.mu.Lock()
+	s.status = func(r *http.Request) int {
+		if strings.HasPrefix(r.URL.Path, "/database/") && failures > 0 {
+			failures--
+			return http.StatusInternalServerError
+		}
+		return 0
+	}
+	s.mu.Unlock()
+	for i := 0; i < 2; i++ {
+		u := next()
+		if u.Err == nil || u.Deleted {
+			t.Fatalf("got %+v, want an error", u)
+		}
+	}
+	// the watch carries on from v1, so it neither sees v1 again nor misses v2
+	if err := c.Put(ScopeBot, "config.json", []byte("v2")); err != nil {
+		t.Fatal(err)
+	}
+	if u := next(); string(u.Payload) != "v2" {
+		t.Fatalf("got %+v, want v2", u)
+	}
+
+	if err := c.Del(ScopeBot, "config.json"); err != nil {
+		t.Fatal(err)
+	}
+	if u := next(); !u.Deleted {
+		t.Fatalf("got %+v, want a deletion", u)
+	}
+}
+
+func TestGetCoercesServerErrors(t *testing.T) {
+	c, s := newTestClient(t)
+	s.status = func(r *http.Request) int { return http.StatusInternalServerError }
+	if _, err := c.Get(ScopeBot, "config.json"); err != ErrNotFound {
+		t.Fatalf("got %v, want ErrNotFound", err)
+	}
+}
@@ -0,0 +1,137 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+
+	"forta-bot-db/api"
+	"forta-bot-db/auth"
+)
+
+const aclPath = "/acl/{scope}"
+
+type grantsResponse struct {
+	Grants []auth.Grant `json:"grants"`
+}
+
+// grantPartition returns the partition of the grants on the caller's scope; scanner scopes cannot be shared
+func grantPartition(hc *auth.HandlerCtx) (string, error) {
+	if hc.Scope != auth.ScopeBot && hc.Scope != auth.ScopeOwner {
+		return "", fmt.Errorf("grants can only be made on the bot and owner scopes")
+	}
+	prefix, err := hc.GetScopePrefix()
+	if err != nil {
+		return "", err
+	}
+	return auth.GrantPartition(prefix), nil
+}
+
+func listGrants(hc *auth.HandlerCtx, pk string) (events.APIGatewayV2HTTPResponse, error) {
+	res := &grantsResponse{Grants: []auth.Grant{}}
+	var start map[string]types.AttributeValue
+	for {
+		out, err := hc.DB.Query(hc.Ctx, &dynamodb.QueryInput{
+			TableName:              &kvTable,
+			KeyConditionExpression: aws.String("pk = :pk"),
+			ExpressionAttributeValues: map[string]types.AttributeValue{
+				":pk": &types.AttributeValueMemberS{Value: pk},
+			},
+			ExclusiveStartKey: start,
+			ConsistentRead:    aws.Bool(true),
+		})
+		if err != nil {
+			hc.Logger.WithError(err).Error("could not list grants")
+			return api.InternalError(), nil
+		}
+		var grants []auth.Grant
+		if err := attributevalue.UnmarshalListOfMaps(out.Items, &grants); err != nil {
+			hc.Logger.WithError(err).Error("could not unmarshal grants")
+			return api.InternalError(), nil
+		}
+		res.Grants = append(res.Grants, grants...)
+		if len(out.LastEvaluatedKey) == 0 {
+			return api.OKJSON(res), nil
+		}
+		start = out.LastEvaluatedKey
+	}
+}
+
+// putGrant creates or replaces the grant of a grantee on a prefix
+func putGrant(hc *auth.HandlerCtx, r events.APIGatewayV2HTTPRequest, pk string) (events.APIGatewayV2HTTPResponse, error) {
+	b, err := requestBody(r)
+	if err != nil {
+		hc.Logger.WithError(err).Error("could not decode body")
+		return api.InternalError(), nil
+	}
+	var g auth.Grant
+	if err := json.Unmarshal(b, &g); err != nil {
+		return api.BadRequest("body must be a grant"), nil
+	}
+	if g.Grantee, err = auth.ParseGrantee(g.Grantee); err != nil {
+		return api.BadRequest(err.Error()), nil
+	}
+	if g.Access != auth.AccessRead && g.Access != auth.AccessReadWrite {
+		return api.BadRequest(fmt.Sprintf("access must be %s or %s", auth.AccessRead, auth.AccessReadWrite)), nil
+	}
+	if len(g.Prefix) > maxKVKeySize {
+		return api.BadRequest(fmt.Sprintf("prefix must not exceed %d bytes", maxKVKeySize)), nil
+	}
+	g.PK = pk
+	g.SK = auth.GrantSortKey(g.Grantee, g.Prefix)
+	item, err := attributevalue.MarshalMap(&g)
+	if err != nil {
+		hc.Logger.WithError(err).Error("could not marshal grant")
+		return api.InternalError(), nil
+	}
+	if _, err := hc.DB.PutItem(hc.Ctx, &dynamodb.PutItemInput{TableName: &kvTable, Item: item}); err != nil {
+		hc.Logger.WithError(err).Error("could not write grant")
+		return api.InternalError(), nil
+	}
+	return api.OKJSON(&g), nil
+}
+
+// deleteGrant revokes the grant of ?grantee= on ?prefix=
+func deleteGrant(hc *auth.HandlerCtx, r events.APIGatewayV2HTTPRequest, pk string) (events.APIGatewayV2HTTPResponse, error) {
+	grantee, err := auth.ParseGrantee(r.QueryStringParameters["grantee"])
+	if err != nil {
+		return api.BadRequest(err.Error()), nil
+	}
+	_, err = hc.DB.DeleteItem(hc.Ctx, &dynamodb.DeleteItemInput{
+		TableName: &kvTable,
+		Key: map[string]types.AttributeValue{
+			"pk": &types.AttributeValueMemberS{Value: pk},
+			"sk": &types.AttributeValueMemberS{Value: auth.GrantSortKey(grantee, r.QueryStringParameters["prefix"])},
+		},
+	})
+	if err != nil {
+		hc.Logger.WithError(err).Error("could not delete grant")
+		return api.InternalError(), nil
+	}
+	return api.OK(), nil
+}
+
+// aclRoute manages the grants other bots and owners have on the caller's bot or owner scope
+func aclRoute(hc *auth.HandlerCtx, r events.APIGatewayV2HTTPRequest) (events.APIGatewayV2HTTPResponse, error) {
+	pk, err := grantPartition(hc)
+	if err != nil {
+		return api.BadRequest(err.Error()), nil
+	}
+	switch strings.ToLower(r.RequestContext.HTTP.Method) {
+	case "get":
+		return listGrants(hc, pk)
+	case "put":
+		return putGrant(hc, r, pk)
+	case "delete":
+		return deleteGrant(hc, r, pk)
+	default:
+		hc.Logger.Warn("method not allowed")
+		return api.MethodNotAllowed(), nil
+	}
+}
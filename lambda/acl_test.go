@@ -0,0 +1,73 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/golang/mock/gomock"
+	log "github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+
+	"forta-bot-db/auth"
+	m "forta-bot-db/store/mocks"
+)
+
+func TestACL(t *testing.T) {
+	kvTable = "test-kv"
+	ctrl := gomock.NewController(t)
+	d := m.NewMockDynamoDB(ctrl)
+
+	hc := &auth.HandlerCtx{
+		Ctx:     context.Background(),
+		BotID:   "0xbotId",
+		Scanner: "0xscanner",
+		Scope:   auth.ScopeBot,
+		Logger:  log.WithField("test", true),
+		DB:      d,
+	}
+
+	d.EXPECT().PutItem(hc.Ctx, gomock.Any()).DoAndReturn(func(ctx context.Context, input *dynamodb.PutItemInput, _ ...func(*dynamodb.Options)) (*dynamodb.PutItemOutput, error) {
+		assert.Equal(t, "acl#0xbotId/", input.Item["pk"].(*types.AttributeValueMemberS).Value)
+		assert.Equal(t, "owner:0xpartner|labels/", input.Item["sk"].(*types.AttributeValueMemberS).Value)
+		assert.Equal(t, auth.AccessRead, input.Item["access"].(*types.AttributeValueMemberS).Value)
+		return &dynamodb.PutItemOutput{}, nil
+	})
+	resp, err := route(hc, apiReq("PUT", aclPath, `{"grantee":"owner:0xPartner","prefix":"labels/","access":"read"}`, nil))
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.JSONEq(t, `{"grantee":"owner:0xpartner","prefix":"labels/","access":"read"}`, resp.Body)
+
+	resp, err = route(hc, apiReq("PUT", aclPath, `{"grantee":"scanner:0xpartner","access":"read"}`, nil))
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+
+	resp, err = route(hc, apiReq("PUT", aclPath, `{"grantee":"bot:0xpartner","access":"admin"}`, nil))
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+
+	d.EXPECT().Query(hc.Ctx, gomock.Any()).Return(&dynamodb.QueryOutput{Items: []map[string]types.AttributeValue{{
+		"grantee": &types.AttributeValueMemberS{Value: "owner:0xpartner"},
+		"prefix":  &types.AttributeValueMemberS{Value: "labels/"},
+		"access":  &types.AttributeValueMemberS{Value: "read"},
+	}}}, nil)
+	resp, err = route(hc, apiReq("GET", aclPath, "", nil))
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"grants":[{"grantee":"owner:0xpartner","prefix":"labels/","access":"read"}]}`, resp.Body)
+
+	d.EXPECT().DeleteItem(hc.Ctx, gomock.Any()).DoAndReturn(func(ctx context.Context, input *dynamodb.DeleteItemInput, _ ...func(*dynamodb.Options)) (*dynamodb.DeleteItemOutput, error) {
+		assert.Equal(t, "owner:0xpartner|labels/", input.Key["sk"].(*types.AttributeValueMemberS).Value)
+		return &dynamodb.DeleteItemOutput{}, nil
+	})
+	resp, err = route(hc, apiReq("DELETE", aclPath, "", map[string]string{"grantee": "owner:0xpartner", "prefix": "labels/"}))
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+	// scanner scopes are private to the scanner
+	hc.Scope = auth.ScopeScanner
+	resp, err = route(hc, apiReq("GET", aclPath, "", nil))
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+}
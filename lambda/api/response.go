@@ -4,13 +4,21 @@ import (
 	"encoding/base64"
 	"encoding/json"
 	"github.com/aws/aws-lambda-go/events"
+	"math"
 	"net/http"
+	"strconv"
+	"time"
 )
 
 type Response struct {
 	Message string `json:"message"`
 }
 
+type RetryResponse struct {
+	Message    string `json:"message"`
+	RetryAfter int64  `json:"retryAfter"`
+}
+
 func response(obj interface{}, status int) events.APIGatewayV2HTTPResponse {
 	b, _ := json.Marshal(obj)
 	return events.APIGatewayV2HTTPResponse{StatusCode: status, Body: string(b)}
@@ -20,6 +28,10 @@ func OK() events.APIGatewayV2HTTPResponse {
 	return response(&Response{Message: "OK"}, 200)
 }
 
+func OKJSON(obj interface{}) events.APIGatewayV2HTTPResponse {
+	return response(obj, http.StatusOK)
+}
+
 func OKBytes(b []byte) events.APIGatewayV2HTTPResponse {
 	msg := base64.StdEncoding.EncodeToString(b)
 	return events.APIGatewayV2HTTPResponse{
@@ -29,6 +41,18 @@ func OKBytes(b []byte) events.APIGatewayV2HTTPResponse {
 	}
 }
 
+func OKHead(headers map[string]string) events.APIGatewayV2HTTPResponse {
+	return events.APIGatewayV2HTTPResponse{StatusCode: http.StatusOK, Headers: headers}
+}
+
+func NotModified() events.APIGatewayV2HTTPResponse {
+	return events.APIGatewayV2HTTPResponse{StatusCode: http.StatusNotModified}
+}
+
+func RangeNotSatisfiable() events.APIGatewayV2HTTPResponse {
+	return response(&Response{Message: "range not satisfiable"}, http.StatusRequestedRangeNotSatisfiable)
+}
+
 func InternalError() events.APIGatewayV2HTTPResponse {
 	return response(&Response{Message: "internal error"}, http.StatusInternalServerError)
 }
@@ -41,6 +65,10 @@ func Unauthorized() events.APIGatewayV2HTTPResponse {
 	return response(&Response{Message: "unauthorized"}, http.StatusUnauthorized)
 }
 
+func Forbidden() events.APIGatewayV2HTTPResponse {
+	return response(&Response{Message: "forbidden"}, http.StatusForbidden)
+}
+
 func MethodNotAllowed() events.APIGatewayV2HTTPResponse {
 	return response(&Response{Message: "method not allowed"}, http.StatusMethodNotAllowed)
 }
@@ -48,3 +76,20 @@ func MethodNotAllowed() events.APIGatewayV2HTTPResponse {
 func BadRequest(msg string) events.APIGatewayV2HTTPResponse {
 	return response(&Response{Message: msg}, http.StatusBadRequest)
 }
+
+func Conflict(msg string) events.APIGatewayV2HTTPResponse {
+	return response(&Response{Message: msg}, http.StatusConflict)
+}
+
+func PreconditionFailed() events.APIGatewayV2HTTPResponse {
+	return response(&Response{Message: "precondition failed"}, http.StatusPreconditionFailed)
+}
+
+// TooManyRequests carries the wait in milliseconds in the body, as Retry-After only counts whole seconds
+func TooManyRequests(retryAfter time.Duration) events.APIGatewayV2HTTPResponse {
+	resp := response(&RetryResponse{Message: "rate limited", RetryAfter: retryAfter.Milliseconds()}, http.StatusTooManyRequests)
+	resp.Headers = map[string]string{
+		"Retry-After": strconv.Itoa(int(math.Ceil(retryAfter.Seconds()))),
+	}
+	return resp
+}
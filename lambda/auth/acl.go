@@ -0,0 +1,149 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// access levels a grant gives on the objects of another bot or owner
+const (
+	AccessRead      = "read"
+	AccessReadWrite = "read-write"
+)
+
+var ErrNoGrant = errors.New("no grant for the requested objects")
+
+// aclPartitionPrefix keeps grants apart from other items; the grants on a scope share a partition
+const aclPartitionPrefix = "acl#"
+
+// Grant gives a bot or the bots of an owner access to the objects under Prefix of a bot or owner scope.
+// Grantee is "bot:{botId}" or "owner:{owner}".
+type Grant struct {
+	PK      string `dynamodbav:"pk" json:"-"`
+	SK      string `dynamodbav:"sk" json:"-"`
+	Grantee string `dynamodbav:"grantee" json:"grantee"`
+	Prefix  string `dynamodbav:"prefix" json:"prefix"`
+	Access  string `dynamodbav:"access" json:"access"`
+}
+
+// ParseGrantee validates and lowercases a grantee
+func ParseGrantee(grantee string) (string, error) {
+	kind, id, ok := strings.Cut(strings.ToLower(grantee), ":")
+	if !ok || (kind != "bot" && kind != "owner") || !strings.HasPrefix(id, "0x") {
+		return "", errors.New("grantee must be bot:{botId} or owner:{owner}")
+	}
+	return kind + ":" + id, nil
+}
+
+// GrantPartition returns the partition holding the grants on the objects under scopePrefix
+func GrantPartition(scopePrefix string) string {
+	return aclPartitionPrefix + scopePrefix
+}
+
+// GrantSortKey orders grants by grantee, so the grants of one grantee are read with a single query
+func GrantSortKey(grantee, prefix string) string {
+	return grantee + "|" + prefix
+}
+
+// crossNamespace reports whether the request addresses another bot's or owner's objects
+func (hc *HandlerCtx) crossNamespace() bool {
+	return hc.ForBot != "" || hc.ForOwner != ""
+}
+
+// grantees returns who the caller can be granted access as
+func (hc *HandlerCtx) grantees() []string {
+	grantees := []string{"bot:" + strings.ToLower(hc.BotID)}
+	if hc.Owner != "" {
+		grantees = append(grantees, "owner:"+strings.ToLower(hc.Owner))
+	}
+	return grantees
+}
+
+// authorizeGrant checks that a grant covers a request for another bot's or owner's objects.
+// Only object reads and writes can cross namespaces; everything else stays within the caller's own.
+func (a *Authorizer) authorizeGrant(ctx context.Context, hc *HandlerCtx, request events.APIGatewayV2HTTPRequest) error {
+	if (hc.ForBot == "" || strings.EqualFold(hc.ForBot, hc.BotID)) && (hc.ForOwner == "" || strings.EqualFold(hc.ForOwner, hc.Owner)) {
+		// the caller's own namespace needs no grant
+		hc.ForBot, hc.ForOwner = "", ""
+		return nil
+	}
+	if !strings.Contains(request.RouteKey, " /database/") || hc.DestKey != "" {
+		return ErrNoGrant
+	}
+	grants, err := a.grants(ctx, hc)
+	if err != nil {
+		return err
+	}
+	if hc.PathKey == BatchKey && strings.EqualFold(request.RequestContext.HTTP.Method, "POST") {
+		// a batch names its keys in the body, so each operation is checked on its own
+		hc.Grants = grants
+		return nil
+	}
+
+	access := AccessReadWrite
+	switch strings.ToUpper(request.RequestContext.HTTP.Method) {
+	case "GET", "HEAD":
+		access = AccessRead
+	}
+	key := hc.PathKey
+	if key == "" {
+		key = hc.Prefix
+	}
+	if !allows(grants, key, access) {
+		return ErrNoGrant
+	}
+	return nil
+}
+
+// Allows reports whether the caller may access key with the given access (AccessRead or AccessReadWrite).
+// Requests within the caller's own namespace are always allowed; others need one of the caller's grants.
+func (hc *HandlerCtx) Allows(key, access string) bool {
+	if !hc.crossNamespace() {
+		return true
+	}
+	return allows(hc.Grants, key, access)
+}
+
+func allows(grants []Grant, key, access string) bool {
+	for _, g := range grants {
+		if strings.HasPrefix(key, g.Prefix) && (g.Access == AccessReadWrite || g.Access == access) {
+			return true
+		}
+	}
+	return false
+}
+
+// grants returns every grant the caller holds on the requested scope
+func (a *Authorizer) grants(ctx context.Context, hc *HandlerCtx) ([]Grant, error) {
+	scopePrefix, err := hc.GetScopePrefix()
+	if err != nil {
+		return nil, err
+	}
+	var all []Grant
+	for _, grantee := range hc.grantees() {
+		out, err := a.d.Query(ctx, &dynamodb.QueryInput{
+			TableName:              &a.kvTable,
+			KeyConditionExpression: aws.String("pk = :pk AND begins_with(sk, :grantee)"),
+			ExpressionAttributeValues: map[string]types.AttributeValue{
+				":pk":      &types.AttributeValueMemberS{Value: GrantPartition(scopePrefix)},
+				":grantee": &types.AttributeValueMemberS{Value: GrantSortKey(grantee, "")},
+			},
+		})
+		if err != nil {
+			return nil, err
+		}
+		var grants []Grant
+		if err := attributevalue.UnmarshalListOfMaps(out.Items, &grants); err != nil {
+			return nil, err
+		}
+		all = append(all, grants...)
+	}
+	return all, nil
+}
@@ -0,0 +1,111 @@
+package auth
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/forta-network/forta-core-go/security"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+
+	mock_store "forta-bot-db/store/mocks"
+)
+
+const partnerBotID = "0xbeefbeefbeefbeefbeefbeefbeefbeefbeefbeefbeefbeefbeefbeefbeefbeef"
+
+func TestAuthorizeGrant(t *testing.T) {
+	jwtVerifier = func(tokenString string) (*security.ScannerToken, error) {
+		return testToken(testBotID, testScanner), nil
+	}
+	ctrl := gomock.NewController(t)
+	d := mock_store.NewMockDynamoDB(ctrl)
+	a := &Authorizer{d: d, table: "table", kvTable: "kv"}
+
+	// the owner is cached, so no registry lookups are needed
+	cached, err := attributevalue.MarshalMap(&CtxState{
+		AuthID: calculateAuthID(testBotID, testScanner), BotID: testBotID, Scanner: testScanner, Owner: strings.ToLower(testOwner),
+	})
+	assert.NoError(t, err)
+	d.EXPECT().GetItem(gomock.Any(), gomock.Any()).Return(&dynamodb.GetItemOutput{Item: cached}, nil).AnyTimes()
+
+	grants := func(grantee string, gs ...Grant) {
+		d.EXPECT().Query(gomock.Any(), gomock.Any()).DoAndReturn(func(ctx context.Context, input *dynamodb.QueryInput, _ ...func(*dynamodb.Options)) (*dynamodb.QueryOutput, error) {
+			assert.Equal(t, "kv", *input.TableName)
+			assert.Equal(t, GrantPartition(partnerBotID+"/"), input.ExpressionAttributeValues[":pk"].(*types.AttributeValueMemberS).Value)
+			assert.Equal(t, GrantSortKey(grantee, ""), input.ExpressionAttributeValues[":grantee"].(*types.AttributeValueMemberS).Value)
+			out := &dynamodb.QueryOutput{}
+			for _, g := range gs {
+				item, err := attributevalue.MarshalMap(&g)
+				assert.NoError(t, err)
+				out.Items = append(out.Items, item)
+			}
+			return out, nil
+		})
+	}
+	req := func(method, key string) (*HandlerCtx, error) {
+		r := testReq(method, testParams("bot", key), authHeader)
+		r.RouteKey = method + " /database/{scope}/{key}"
+		r.QueryStringParameters = map[string]string{"bot": partnerBotID}
+		return a.Authorize(context.Background(), r)
+	}
+	botGrantee := "bot:" + strings.ToLower(testBotID)
+	ownerGrantee := "owner:" + strings.ToLower(testOwner)
+
+	// granted to the caller's owner
+	grants(botGrantee)
+	grants(ownerGrantee, Grant{Grantee: ownerGrantee, Prefix: "labels/", Access: AccessRead})
+	hc, err := req("GET", "labels/latest.json")
+	assert.NoError(t, err)
+	key, err := hc.GetObjectKey()
+	assert.NoError(t, err)
+	assert.Equal(t, partnerBotID+"/labels/latest.json", key)
+
+	// reading is not writing
+	grants(botGrantee, Grant{Grantee: botGrantee, Prefix: "labels/", Access: AccessRead})
+	grants(ownerGrantee)
+	_, err = req("PUT", "labels/latest.json")
+	assert.ErrorIs(t, err, ErrNoGrant)
+
+	// outside the granted prefix
+	grants(botGrantee, Grant{Grantee: botGrantee, Prefix: "labels/", Access: AccessReadWrite})
+	grants(ownerGrantee)
+	_, err = req("GET", "secrets.json")
+	assert.ErrorIs(t, err, ErrNoGrant)
+
+	// a batch is let through with the grants, to authorize each of its operations
+	grants(botGrantee, Grant{Grantee: botGrantee, Prefix: "labels/", Access: AccessRead})
+	grants(ownerGrantee)
+	hc, err = req("POST", BatchKey)
+	assert.NoError(t, err)
+	assert.True(t, hc.Allows("labels/latest.json", AccessRead))
+	assert.False(t, hc.Allows("labels/latest.json", AccessReadWrite))
+	assert.False(t, hc.Allows("secrets.json", AccessRead))
+
+	// bot ids are not case sensitive
+	grants(botGrantee, Grant{Grantee: botGrantee, Prefix: "labels/", Access: AccessRead})
+	grants(ownerGrantee)
+	r := testReq("GET", testParams("bot", "labels/latest.json"), authHeader)
+	r.RouteKey = "GET /database/{scope}/{key}"
+	r.QueryStringParameters = map[string]string{"bot": "0x" + strings.ToUpper(partnerBotID[2:])}
+	hc, err = a.Authorize(context.Background(), r)
+	assert.NoError(t, err)
+	key, err = hc.GetObjectKey()
+	assert.NoError(t, err)
+	assert.Equal(t, partnerBotID+"/labels/latest.json", key)
+
+	// only object routes cross namespaces
+	r = testReq("GET", testParams("bot", "checkpoint"), authHeader)
+	r.RouteKey = "GET /kv/{scope}/{key}"
+	r.QueryStringParameters = map[string]string{"bot": partnerBotID}
+	_, err = a.Authorize(context.Background(), r)
+	assert.ErrorIs(t, err, ErrNoGrant)
+
+	r = testReq("GET", testParams("scanner", testKey), authHeader)
+	r.QueryStringParameters = map[string]string{"bot": partnerBotID}
+	_, err = a.Authorize(context.Background(), r)
+	assert.Error(t, err)
+}
@@ -26,6 +26,9 @@ type Scope string
 const ScopeScanner Scope = "scanner"
 const ScopeBot Scope = "bot"
 const ScopeOwner Scope = "owner"
+
+// ScopePublic is written by its bot and readable by every authenticated bot
+const ScopePublic Scope = "public"
 const DefaultScope = ScopeScanner
 
 var ErrNotAssigned = errors.New("botId is not assigned to scanner")
@@ -49,10 +52,35 @@ type HandlerCtx struct {
 	ExpiresAt int64
 	PathKey   string
 	Scope     Scope
+	DestKey   string
+	DestScope Scope
+	Prefix    string
+	// ForBot and ForOwner address another bot's bot scope or another owner's owner scope (?bot= and ?owner=),
+	// which needs a grant from them
+	ForBot   string
+	ForOwner string
+	// Grants are the caller's grants on the other bot's or owner's scope, set for batches, whose operations are
+	// authorized one by one with Allows
+	Grants []Grant
+	// Publisher is the bot whose public scope a read addresses, from GET /database/public/{publisher}/{key}
+	Publisher string
 	Logger    *log.Entry
 	Store     store.S3
+	DB        store.DynamoDB
+}
+
+// keylessRoutes have no {key} segment; every other route, including all of /database and /kv, must name a key
+var keylessRoutes = map[string]bool{
+	"GET /inbox":           true,
+	"GET /changes/{scope}": true,
+	"GET /acl/{scope}":     true,
+	"PUT /acl/{scope}":     true,
+	"DELETE /acl/{scope}":  true,
 }
 
+// BatchKey is the reserved key that addresses the batch endpoint: POST /database/{scope}/_batch
+const BatchKey = "_batch"
+
 type JwtVerifier func(tokenString string) (*security.ScannerToken, error)
 
 var jwtVerifier JwtVerifier = security.VerifyScannerJWT
@@ -61,17 +89,82 @@ func calculateAuthID(botID, scanner string) string {
 	return strings.ToLower(fmt.Sprintf("%s|%s", botID, scanner))
 }
 
-func (hc *HandlerCtx) GetObjectKey() (string, error) {
-	switch hc.Scope {
+func (hc *HandlerCtx) scopePrefix(scope Scope) (string, error) {
+	switch scope {
 	case ScopeScanner:
-		return fmt.Sprintf("%s/%s/%s", hc.BotID, hc.Scanner, hc.PathKey), nil
+		return fmt.Sprintf("%s/%s/", hc.BotID, hc.Scanner), nil
 	case ScopeBot:
-		return fmt.Sprintf("%s/%s", hc.BotID, hc.PathKey), nil
+		if hc.ForBot != "" {
+			return fmt.Sprintf("%s/", hc.ForBot), nil
+		}
+		return fmt.Sprintf("%s/", hc.BotID), nil
 	case ScopeOwner:
-		return fmt.Sprintf("owner/%s/%s", hc.Owner, hc.PathKey), nil
+		if hc.ForOwner != "" {
+			return fmt.Sprintf("owner/%s/", hc.ForOwner), nil
+		}
+		return fmt.Sprintf("owner/%s/", hc.Owner), nil
+	case ScopePublic:
+		// bot ids are compared without case, so the same publisher always maps to the same prefix
+		if hc.Publisher != "" {
+			return fmt.Sprintf("public/%s/", strings.ToLower(hc.Publisher)), nil
+		}
+		return fmt.Sprintf("public/%s/", strings.ToLower(hc.BotID)), nil
 	default:
-		return "", errors.New("scope must be scanner, owner, or bot")
+		return "", errors.New("scope must be scanner, owner, bot, or public")
+	}
+}
+
+func (hc *HandlerCtx) objectKey(scope Scope, pathKey string) (string, error) {
+	prefix, err := hc.scopePrefix(scope)
+	if err != nil {
+		return "", err
 	}
+	return prefix + pathKey, nil
+}
+
+// GetScopePrefix returns the key prefix every object of the scope is stored under
+func (hc *HandlerCtx) GetScopePrefix() (string, error) {
+	return hc.scopePrefix(hc.Scope)
+}
+
+func (hc *HandlerCtx) GetObjectKey() (string, error) {
+	return hc.objectKey(hc.Scope, hc.PathKey)
+}
+
+// GetDestObjectKey returns the object key of the copy/move destination
+func (hc *HandlerCtx) GetDestObjectKey() (string, error) {
+	if hc.DestKey == "" {
+		return "", errors.New("no destination defined")
+	}
+	return hc.objectKey(hc.DestScope, hc.DestKey)
+}
+
+func (hc *HandlerCtx) needsOwner() bool {
+	// grants can be made to the caller's owner
+	return hc.Scope == ScopeOwner || hc.DestScope == ScopeOwner || hc.crossNamespace()
+}
+
+// parseNamespace reads ?bot= for the bot scope and ?owner= for the owner scope of another bot or owner.
+// Both are lowercased, as grants and object prefixes are kept under lowercased ids.
+func parseNamespace(scope Scope, query map[string]string) (string, string, error) {
+	forBot := strings.ToLower(query["bot"])
+	forOwner := strings.ToLower(query["owner"])
+	if forBot != "" && scope != ScopeBot {
+		return "", "", errors.New("bot can only be set for the bot scope")
+	}
+	if forOwner != "" && scope != ScopeOwner {
+		return "", "", errors.New("owner can only be set for the owner scope")
+	}
+	return forBot, forOwner, nil
+}
+
+// parseDest splits a copy/move destination of the form {scope}/{key}
+func parseDest(to string) (Scope, string, error) {
+	parts := strings.SplitN(to, "/", 2)
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return "", "", errors.New("destination must be {scope}/{key}")
+	}
+	return Scope(parts[0]), parts[1], nil
 }
 
 func extractContext(ctx context.Context, request events.APIGatewayV2HTTPRequest) (*HandlerCtx, error) {
@@ -88,11 +181,42 @@ func extractContext(ctx context.Context, request events.APIGatewayV2HTTPRequest)
 		scope = Scope(scopeStr)
 	}
 
+	// the public route names the scope in its path
+	publisher := request.PathParameters["publisher"]
+	if publisher != "" {
+		scope = ScopePublic
+		scopeStr = string(ScopePublic)
+	}
+
 	pathKey, ok := request.PathParameters["key"]
-	if !ok {
+	if !ok && !keylessRoutes[request.RouteKey] {
 		return nil, errors.New("no key defined")
 	}
 
+	// DELETE /database/{scope}?prefix= shares its route with DELETE /database/{key}, so the segment is the scope
+	var prefix string
+	if p, isPrefix := request.QueryStringParameters["prefix"]; isPrefix && scopeStr == "" &&
+		strings.EqualFold(request.RequestContext.HTTP.Method, "DELETE") {
+		scope = Scope(pathKey)
+		pathKey = ""
+		prefix = p
+	}
+
+	var destScope Scope
+	var destKey string
+	if to, ok := request.QueryStringParameters["to"]; ok {
+		var err error
+		destScope, destKey, err = parseDest(to)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	forBot, forOwner, err := parseNamespace(scope, request.QueryStringParameters)
+	if err != nil {
+		return nil, err
+	}
+
 	parts := strings.Split(h, " ")
 	if len(parts) != 2 {
 		return nil, errors.New("invalid Authorization header")
@@ -109,13 +233,19 @@ func extractContext(ctx context.Context, request events.APIGatewayV2HTTPRequest)
 				return nil, err
 			}
 			return &HandlerCtx{
-				AuthID:  calculateAuthID(botId.(string), st.Scanner),
-				Ctx:     ctx,
-				BotID:   botId.(string),
-				Scanner: st.Scanner,
-				Store:   s,
-				PathKey: pathKey,
-				Scope:   scope,
+				AuthID:    calculateAuthID(botId.(string), st.Scanner),
+				Ctx:       ctx,
+				BotID:     botId.(string),
+				Scanner:   st.Scanner,
+				Store:     s,
+				PathKey:   pathKey,
+				Scope:     scope,
+				Prefix:    prefix,
+				DestKey:   destKey,
+				DestScope: destScope,
+				ForBot:    forBot,
+				ForOwner:  forOwner,
+				Publisher: publisher,
 				Logger: log.WithFields(log.Fields{
 					"botId":   botId,
 					"scanner": st.Scanner,
@@ -129,9 +259,10 @@ func extractContext(ctx context.Context, request events.APIGatewayV2HTTPRequest)
 }
 
 type Authorizer struct {
-	r     registry.Client
-	d     store.DynamoDB
-	table string
+	r       registry.Client
+	d       store.DynamoDB
+	table   string
+	kvTable string
 }
 
 type ensStore struct{}
@@ -179,7 +310,7 @@ func NewAuthorizer(ctx context.Context) (*Authorizer, error) {
 	if err != nil {
 		return nil, err
 	}
-	return &Authorizer{r: r, d: d, table: table}, nil
+	return &Authorizer{r: r, d: d, table: table, kvTable: os.Getenv("kvTable")}, nil
 }
 
 func (a *Authorizer) authorizeCtx(ctx context.Context, hc *HandlerCtx) error {
@@ -199,8 +330,11 @@ func (a *Authorizer) authorizeCtx(ctx context.Context, hc *HandlerCtx) error {
 			return err
 		}
 		// copy over owner from one from jwt
-		hc.Owner = saved.Owner
-		return nil
+		// a state saved by a request that did not need the owner has none, so resolve it below
+		if saved.Owner != "" || !hc.needsOwner() {
+			hc.Owner = saved.Owner
+			return nil
+		}
 	}
 	enabled, err := a.r.IsEnabledScanner(hc.Scanner)
 	if err != nil {
@@ -217,7 +351,7 @@ func (a *Authorizer) authorizeCtx(ctx context.Context, hc *HandlerCtx) error {
 	if !assigned {
 		return ErrNotAssigned
 	}
-	if hc.Scope == ScopeOwner {
+	if hc.needsOwner() {
 		agt, err := a.r.GetAgent(hc.BotID)
 		if err != nil {
 			return err
@@ -252,6 +386,12 @@ func (a *Authorizer) Authorize(ctx context.Context, request events.APIGatewayV2H
 	if err := a.authorizeCtx(ctx, botCtx); err != nil {
 		return nil, err
 	}
+	if botCtx.crossNamespace() {
+		if err := a.authorizeGrant(ctx, botCtx, request); err != nil {
+			return nil, err
+		}
+	}
+	botCtx.DB = a.d
 
 	return botCtx, nil
 }
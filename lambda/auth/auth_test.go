@@ -8,6 +8,8 @@ import (
 	"testing"
 
 	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
 	"github.com/forta-network/forta-core-go/registry"
 	mock_registry "github.com/forta-network/forta-core-go/registry/mocks"
 	"github.com/forta-network/forta-core-go/security"
@@ -237,3 +239,101 @@ func TestAuthorize(t *testing.T) {
 		}
 	}
 }
+
+func TestAuthorizeDest(t *testing.T) {
+	jwtVerifier = func(tokenString string) (*security.ScannerToken, error) {
+		return testToken(testBotID, testScanner), nil
+	}
+	ctrl := gomock.NewController(t)
+	r := mock_registry.NewMockClient(ctrl)
+	d := mock_store.NewMockDynamoDB(ctrl)
+	a := &Authorizer{r: r, d: d, table: "table"}
+
+	req := testReq("POST", testParams("scanner", testKey), authHeader)
+	req.QueryStringParameters = map[string]string{"to": "owner/shared.json"}
+
+	// the cached state was saved by a request that did not need the owner, so it is resolved for the destination
+	cached, err := attributevalue.MarshalMap(&CtxState{AuthID: calculateAuthID(testBotID, testScanner), BotID: testBotID, Scanner: testScanner})
+	assert.NoError(t, err)
+	d.EXPECT().GetItem(gomock.Any(), gomock.Any()).Return(&dynamodb.GetItemOutput{Item: cached}, nil).Times(1)
+	r.EXPECT().IsEnabledScanner(gomock.Any()).Return(true, nil).Times(1)
+	r.EXPECT().IsAssigned(gomock.Any(), gomock.Any()).Return(true, nil).Times(1)
+	r.EXPECT().GetAgent(gomock.Any()).Return(&registry.Agent{Owner: testOwner}, nil).Times(1)
+	d.EXPECT().PutItem(gomock.Any(), gomock.Any()).Return(nil, nil).Times(1)
+
+	hc, err := a.Authorize(context.Background(), req)
+	assert.NoError(t, err)
+	assert.Equal(t, ScopeOwner, hc.DestScope)
+	assert.Equal(t, "shared.json", hc.DestKey)
+	key, err := hc.GetDestObjectKey()
+	assert.NoError(t, err)
+	assert.Equal(t, fmt.Sprintf("owner/%s/shared.json", strings.ToLower(testOwner)), key)
+
+	req.QueryStringParameters = map[string]string{"to": "shared.json"}
+	_, err = a.Authorize(context.Background(), req)
+	assert.Error(t, err)
+}
+
+func TestExtractContextPrefix(t *testing.T) {
+	jwtVerifier = func(tokenString string) (*security.ScannerToken, error) {
+		return testToken(testBotID, testScanner), nil
+	}
+
+	// DELETE /database/{scope}?prefix= arrives on the DELETE /database/{key} route
+	req := testReq("DELETE", map[string]string{"key": "bot"}, authHeader)
+	req.QueryStringParameters = map[string]string{"prefix": "state/"}
+	hc, err := extractContext(context.Background(), req)
+	assert.NoError(t, err)
+	assert.Equal(t, ScopeBot, hc.Scope)
+	assert.Equal(t, "", hc.PathKey)
+	assert.Equal(t, "state/", hc.Prefix)
+	prefix, err := hc.GetScopePrefix()
+	assert.NoError(t, err)
+	assert.Equal(t, testBotID+"/", prefix)
+
+	// other methods keep the key
+	req = testReq("GET", map[string]string{"key": "bot"}, authHeader)
+	req.QueryStringParameters = map[string]string{"prefix": "state/"}
+	hc, err = extractContext(context.Background(), req)
+	assert.NoError(t, err)
+	assert.Equal(t, DefaultScope, hc.Scope)
+	assert.Equal(t, "bot", hc.PathKey)
+}
+
+func TestExtractContextPublic(t *testing.T) {
+	jwtVerifier = func(tokenString string) (*security.ScannerToken, error) {
+		return testToken(testBotID, testScanner), nil
+	}
+
+	req := testReq("GET", map[string]string{"publisher": "0xpublisher", "key": "tokens.json"}, authHeader)
+	hc, err := extractContext(context.Background(), req)
+	assert.NoError(t, err)
+	assert.Equal(t, ScopePublic, hc.Scope)
+	key, err := hc.GetObjectKey()
+	assert.NoError(t, err)
+	assert.Equal(t, "public/0xpublisher/tokens.json", key)
+
+	// a bot writes its own public scope
+	hc, err = extractContext(context.Background(), testReq("PUT", testParams("public", "tokens.json"), authHeader))
+	assert.NoError(t, err)
+	key, err = hc.GetObjectKey()
+	assert.NoError(t, err)
+	assert.Equal(t, "public/"+testBotID+"/tokens.json", key)
+}
+
+func TestExtractContextKey(t *testing.T) {
+	jwtVerifier = func(tokenString string) (*security.ScannerToken, error) {
+		return testToken(testBotID, testScanner), nil
+	}
+
+	req := testReq("GET", map[string]string{"scope": "bot"}, authHeader)
+	req.RouteKey = "GET /database/{scope}/{key}"
+	_, err := extractContext(context.Background(), req)
+	assert.EqualError(t, err, "no key defined")
+
+	req = testReq("GET", nil, authHeader)
+	req.RouteKey = "GET /inbox"
+	hc, err := extractContext(context.Background(), req)
+	assert.NoError(t, err)
+	assert.Equal(t, "", hc.PathKey)
+}
@@ -0,0 +1,149 @@
+package main
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/aws/aws-lambda-go/events"
+
+	"forta-bot-db/api"
+	"forta-bot-db/auth"
+)
+
+const batchKey = auth.BatchKey
+
+const maxBatchOperations = 100
+
+// maxBatchConcurrency bounds concurrent store calls per batch
+const maxBatchConcurrency = 8
+
+// maxBatchResponseSize keeps the combined results under the lambda payload limit.  Values that do not fit are left
+// out and their results marked 413, so the caller reads those objects on their own.
+const maxBatchResponseSize = 5 * 1024 * 1024
+
+type batchOperation struct {
+	Op      string            `json:"op"`
+	Key     string            `json:"key"`
+	Value   string            `json:"value,omitempty"`
+	Headers map[string]string `json:"headers,omitempty"`
+}
+
+type batchRequest struct {
+	Operations []batchOperation `json:"operations"`
+}
+
+type batchResult struct {
+	Key     string            `json:"key"`
+	Status  int               `json:"status"`
+	Value   string            `json:"value,omitempty"`
+	Headers map[string]string `json:"headers,omitempty"`
+	Error   string            `json:"error,omitempty"`
+}
+
+type batchResponse struct {
+	Results []*batchResult `json:"results"`
+}
+
+// runOperation applies one operation through the regular object handlers, so it behaves exactly like a single request
+func runOperation(hc *auth.HandlerCtx, op batchOperation) *batchResult {
+	opCtx := *hc
+	opCtx.PathKey = op.Key
+	opCtx.Logger = hc.Logger.WithField("key", op.Key)
+
+	headers := map[string]string{}
+	for k, v := range op.Headers {
+		headers[strings.ToLower(k)] = v
+	}
+	req := events.APIGatewayV2HTTPRequest{Headers: headers}
+
+	access := auth.AccessReadWrite
+	if strings.EqualFold(op.Op, "get") {
+		access = auth.AccessRead
+	}
+	if !hc.Allows(op.Key, access) {
+		return &batchResult{Key: op.Key, Status: http.StatusForbidden, Error: "forbidden"}
+	}
+
+	var resp events.APIGatewayV2HTTPResponse
+	switch strings.ToLower(op.Op) {
+	case "get":
+		resp, _ = getObj(&opCtx, req)
+	case "put":
+		req.Body = op.Value
+		req.IsBase64Encoded = true
+		resp, _ = putObj(&opCtx, req)
+	case "delete":
+		resp, _ = delObj(&opCtx)
+	default:
+		return &batchResult{Key: op.Key, Status: http.StatusBadRequest, Error: fmt.Sprintf("unknown op %q", op.Op)}
+	}
+
+	res := &batchResult{Key: op.Key, Status: resp.StatusCode, Headers: resp.Headers}
+	switch {
+	case resp.StatusCode >= 400:
+		var msg api.Response
+		_ = json.Unmarshal([]byte(resp.Body), &msg)
+		res.Error = msg.Message
+	case resp.IsBase64Encoded:
+		res.Value = resp.Body
+	}
+	return res
+}
+
+// batchObj runs a list of get/put/delete operations concurrently.  Within the caller's own namespace they share the
+// request's authorization; on another bot's or owner's scope each needs a grant for its key.
+func batchObj(hc *auth.HandlerCtx, r events.APIGatewayV2HTTPRequest) (events.APIGatewayV2HTTPResponse, error) {
+	body := []byte(r.Body)
+	if r.IsBase64Encoded {
+		b, err := base64.StdEncoding.DecodeString(r.Body)
+		if err != nil {
+			return api.BadRequest("could not decode body"), nil
+		}
+		body = b
+	}
+	var req batchRequest
+	if err := json.Unmarshal(body, &req); err != nil {
+		return api.BadRequest("invalid batch request"), nil
+	}
+	if len(req.Operations) == 0 || len(req.Operations) > maxBatchOperations {
+		return api.BadRequest(fmt.Sprintf("a batch must have between 1 and %d operations", maxBatchOperations)), nil
+	}
+
+	results := make([]*batchResult, len(req.Operations))
+	sem := make(chan struct{}, maxBatchConcurrency)
+	var wg sync.WaitGroup
+	for i, op := range req.Operations {
+		if op.Key == "" || op.Key == batchKey || scannerKey(hc.Scope, op.Key) {
+			results[i] = &batchResult{Key: op.Key, Status: http.StatusBadRequest, Error: "invalid key"}
+			continue
+		}
+		wg.Add(1)
+		go func(i int, op batchOperation) {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+			results[i] = runOperation(hc, op)
+		}(i, op)
+	}
+	wg.Wait()
+	limitResponseSize(results)
+
+	return api.OKJSON(&batchResponse{Results: results}), nil
+}
+
+// limitResponseSize replaces the results that would take the response over maxBatchResponseSize, in order
+func limitResponseSize(results []*batchResult) {
+	size := 0
+	for i, res := range results {
+		b, _ := json.Marshal(res)
+		if size+len(b) > maxBatchResponseSize {
+			results[i] = &batchResult{Key: res.Key, Status: http.StatusRequestEntityTooLarge, Error: "the result does not fit in the batch response"}
+			b, _ = json.Marshal(results[i])
+		}
+		size += len(b)
+	}
+}
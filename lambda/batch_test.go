@@ -0,0 +1,198 @@
+package main
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/golang/mock/gomock"
+	log "github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+
+	"forta-bot-db/auth"
+	m "forta-bot-db/store/mocks"
+)
+
+func TestBatchObj(t *testing.T) {
+	bucket = "test-bucket"
+	ctrl := gomock.NewController(t)
+	s := m.NewMockS3(ctrl)
+
+	hc := &auth.HandlerCtx{
+		Ctx:     context.Background(),
+		BotID:   "0xbotId",
+		Scanner: "0xscanner",
+		PathKey: batchKey,
+		Scope:   auth.ScopeScanner,
+		Logger:  log.WithField("test", true),
+		Store:   s,
+	}
+
+	s.EXPECT().GetObject(hc.Ctx, gomock.Any()).DoAndReturn(func(ctx context.Context, input *s3.GetObjectInput, _ ...func(*s3.Options)) (*s3.GetObjectOutput, error) {
+		assert.Equal(t, "0xbotId/0xscanner/a", *input.Key)
+		return &s3.GetObjectOutput{Body: io.NopCloser(strings.NewReader("value-a"))}, nil
+	})
+	s.EXPECT().PutObject(hc.Ctx, gomock.Any()).DoAndReturn(func(ctx context.Context, input *s3.PutObjectInput, _ ...func(*s3.Options)) (*s3.PutObjectOutput, error) {
+		assert.Equal(t, "0xbotId/0xscanner/b", *input.Key)
+		b, _ := io.ReadAll(input.Body)
+		assert.Equal(t, "value-b", string(b))
+		return &s3.PutObjectOutput{}, nil
+	})
+	s.EXPECT().DeleteObject(hc.Ctx, gomock.Any()).DoAndReturn(func(ctx context.Context, input *s3.DeleteObjectInput, _ ...func(*s3.Options)) (*s3.DeleteObjectOutput, error) {
+		assert.Equal(t, "0xbotId/0xscanner/c", *input.Key)
+		return &s3.DeleteObjectOutput{}, nil
+	})
+
+	req, _ := json.Marshal(&batchRequest{Operations: []batchOperation{
+		{Op: "get", Key: "a"},
+		{Op: "put", Key: "b", Value: base64.StdEncoding.EncodeToString([]byte("value-b"))},
+		{Op: "delete", Key: "c"},
+		{Op: "rename", Key: "d"},
+	}})
+	resp, err := batchObj(hc, events.APIGatewayV2HTTPRequest{Body: string(req)})
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+	var res batchResponse
+	assert.NoError(t, json.Unmarshal([]byte(resp.Body), &res))
+	assert.Len(t, res.Results, 4)
+	assert.Equal(t, http.StatusOK, res.Results[0].Status)
+	assert.Equal(t, base64.StdEncoding.EncodeToString([]byte("value-a")), res.Results[0].Value)
+	assert.Equal(t, http.StatusOK, res.Results[1].Status)
+	assert.Equal(t, http.StatusOK, res.Results[2].Status)
+	assert.Equal(t, http.StatusBadRequest, res.Results[3].Status)
+
+	resp, err = batchObj(hc, events.APIGatewayV2HTTPRequest{Body: `{"operations":[]}`})
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+}
+
+func TestBatchObjGrants(t *testing.T) {
+	bucket = "test-bucket"
+	ctrl := gomock.NewController(t)
+	s := m.NewMockS3(ctrl)
+
+	// a read-only grantee of another bot's labels
+	hc := &auth.HandlerCtx{
+		Ctx:     context.Background(),
+		BotID:   "0xbotId",
+		Scanner: "0xscanner",
+		PathKey: batchKey,
+		Scope:   auth.ScopeBot,
+		ForBot:  "0xpartner",
+		Grants:  []auth.Grant{{Grantee: "bot:0xbotid", Prefix: "labels/", Access: auth.AccessRead}},
+		Logger:  log.WithField("test", true),
+		Store:   s,
+	}
+
+	s.EXPECT().GetObject(hc.Ctx, gomock.Any()).DoAndReturn(func(ctx context.Context, input *s3.GetObjectInput, _ ...func(*s3.Options)) (*s3.GetObjectOutput, error) {
+		assert.Equal(t, "0xpartner/labels/a", *input.Key)
+		return &s3.GetObjectOutput{Body: io.NopCloser(strings.NewReader("value-a"))}, nil
+	})
+
+	req, _ := json.Marshal(&batchRequest{Operations: []batchOperation{
+		{Op: "get", Key: "labels/a"},
+		{Op: "put", Key: "labels/b", Value: base64.StdEncoding.EncodeToString([]byte("value-b"))},
+		{Op: "delete", Key: "labels/c"},
+		{Op: "get", Key: "secrets.json"},
+	}})
+	resp, err := batchObj(hc, events.APIGatewayV2HTTPRequest{Body: string(req)})
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+	var res batchResponse
+	assert.NoError(t, json.Unmarshal([]byte(resp.Body), &res))
+	assert.Len(t, res.Results, 4)
+	assert.Equal(t, http.StatusOK, res.Results[0].Status)
+	assert.Equal(t, http.StatusForbidden, res.Results[1].Status)
+	assert.Equal(t, http.StatusForbidden, res.Results[2].Status)
+	assert.Equal(t, http.StatusForbidden, res.Results[3].Status)
+}
+
+func TestBatchObjScannerKeys(t *testing.T) {
+	// a grant on the whole of another bot's scope does not reach its scanners' objects
+	hc := &auth.HandlerCtx{
+		Ctx:     context.Background(),
+		BotID:   "0xbotId",
+		Scanner: "0xscanner",
+		PathKey: batchKey,
+		Scope:   auth.ScopeBot,
+		ForBot:  "0xpartner",
+		Grants:  []auth.Grant{{Grantee: "bot:0xbotid", Prefix: "", Access: auth.AccessReadWrite}},
+		Logger:  log.WithField("test", true),
+	}
+
+	req, _ := json.Marshal(&batchRequest{Operations: []batchOperation{
+		{Op: "get", Key: "0x1111111111111111111111111111111111111111/state.json"},
+		{Op: "put", Key: "0xABCDEFABCDEFABCDEFABCDEFABCDEFABCDEFABCD/state.json", Value: "eA=="},
+	}})
+	resp, err := batchObj(hc, events.APIGatewayV2HTTPRequest{Body: string(req)})
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+	var res batchResponse
+	assert.NoError(t, json.Unmarshal([]byte(resp.Body), &res))
+	assert.Len(t, res.Results, 2)
+	assert.Equal(t, http.StatusBadRequest, res.Results[0].Status)
+	assert.Equal(t, http.StatusBadRequest, res.Results[1].Status)
+}
+
+func TestBatchObjResponseSize(t *testing.T) {
+	bucket = "test-bucket"
+	ctrl := gomock.NewController(t)
+	s := m.NewMockS3(ctrl)
+
+	hc := &auth.HandlerCtx{
+		Ctx:     context.Background(),
+		BotID:   "0xbotId",
+		Scanner: "0xscanner",
+		PathKey: batchKey,
+		Scope:   auth.ScopeScanner,
+		Logger:  log.WithField("test", true),
+		Store:   s,
+	}
+
+	// each value takes about 40% of the response once base64 encoded
+	value := strings.Repeat("x", maxBatchResponseSize*3/10)
+	s.EXPECT().GetObject(hc.Ctx, gomock.Any()).DoAndReturn(func(ctx context.Context, input *s3.GetObjectInput, _ ...func(*s3.Options)) (*s3.GetObjectOutput, error) {
+		return &s3.GetObjectOutput{Body: io.NopCloser(strings.NewReader(value))}, nil
+	}).Times(3)
+
+	req, _ := json.Marshal(&batchRequest{Operations: []batchOperation{
+		{Op: "get", Key: "a"},
+		{Op: "get", Key: "b"},
+		{Op: "get", Key: "c"},
+	}})
+	resp, err := batchObj(hc, events.APIGatewayV2HTTPRequest{Body: string(req)})
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.LessOrEqual(t, len(resp.Body), maxBatchResponseSize)
+
+	var res batchResponse
+	assert.NoError(t, json.Unmarshal([]byte(resp.Body), &res))
+	assert.Len(t, res.Results, 3)
+	assert.Equal(t, http.StatusOK, res.Results[0].Status)
+	assert.Equal(t, http.StatusOK, res.Results[1].Status)
+	assert.Equal(t, http.StatusRequestEntityTooLarge, res.Results[2].Status)
+	assert.Empty(t, res.Results[2].Value)
+}
+
+func TestPutObjReservedKey(t *testing.T) {
+	hc := &auth.HandlerCtx{
+		Ctx:     context.Background(),
+		BotID:   "0xbotId",
+		Scanner: "0xscanner",
+		PathKey: batchKey,
+		Scope:   auth.ScopeBot,
+		Logger:  log.WithField("test", true),
+	}
+	resp, err := putObj(hc, events.APIGatewayV2HTTPRequest{Body: "x"})
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+}
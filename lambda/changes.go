@@ -0,0 +1,129 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+
+	"forta-bot-db/api"
+	"forta-bot-db/auth"
+)
+
+const changesPath = "/changes/{scope}"
+
+// changeFeed turns on recording object writes; each write then also appends to the scope's change stream.
+// It is off unless configured, as every object write then costs a query and a write on the scope's partition.
+var changeFeed = os.Getenv("changeFeed") == "true"
+
+// changeRetention is how long changes are kept before DynamoDB expires them; readers further behind miss changes
+const changeRetention = 7 * 24 * time.Hour
+
+// maxRecordAttempts bounds how often a change is retried when concurrent writes keep taking its sequence number
+const maxRecordAttempts = 3
+
+// recordRetryDelay spaces out retries of a contended change, giving the other writers time to finish
+var recordRetryDelay = 20 * time.Millisecond
+
+// changePartitionPrefix keeps a scope's change stream apart from streams written through the stream API
+const changePartitionPrefix = "changes#"
+
+const (
+	changePut       = "put"
+	changeDelete    = "delete"
+	changeDelPrefix = "delPrefix"
+)
+
+// change is the stream record of one object write; for delPrefix, Key is the deleted prefix
+type change struct {
+	Op   string `json:"op"`
+	Key  string `json:"key"`
+	ETag string `json:"etag,omitempty"`
+}
+
+type changeResponse struct {
+	Seq  int64  `json:"seq"`
+	Op   string `json:"op"`
+	Key  string `json:"key"`
+	ETag string `json:"etag,omitempty"`
+	Time int64  `json:"time"`
+}
+
+type changesResponse struct {
+	Changes []changeResponse `json:"changes"`
+	Next    int64            `json:"next"`
+}
+
+func changePartition(hc *auth.HandlerCtx) (string, error) {
+	prefix, err := hc.GetScopePrefix()
+	if err != nil {
+		return "", err
+	}
+	return changePartitionPrefix + prefix, nil
+}
+
+// recordChange appends a write to the change feed of hc's scope.  The write has already happened, so a failure
+// is logged rather than returned: the feed may miss it, but the caller's request succeeded.
+func recordChange(hc *auth.HandlerCtx, op, key, etag string) {
+	if !changeFeed {
+		return
+	}
+	pk, err := changePartition(hc)
+	if err != nil {
+		return
+	}
+	logger := hc.Logger.WithField("op", op).WithField("key", key)
+	b, err := json.Marshal(&change{Op: op, Key: key, ETag: etag})
+	if err != nil {
+		logger.WithError(err).Error("could not record change")
+		return
+	}
+	for attempt := 1; ; attempt++ {
+		_, err = appendToStream(hc, pk, b, changeRetention)
+		if !errors.Is(err, errStreamContention) || attempt == maxRecordAttempts {
+			break
+		}
+		time.Sleep(time.Duration(attempt) * recordRetryDelay)
+	}
+	if err != nil {
+		logger.WithError(err).Error("dropped change")
+	}
+}
+
+// getChanges lists object writes in a scope from ?from= onwards, optionally only those under ?prefix=.
+// Next is past every change read, including ones the prefix filtered out, so a caught up reader sees next == from.
+func getChanges(hc *auth.HandlerCtx, r events.APIGatewayV2HTTPRequest) (events.APIGatewayV2HTTPResponse, error) {
+	from, limit, err := readRange(r)
+	if err != nil {
+		return api.BadRequest(err.Error()), nil
+	}
+	pk, err := changePartition(hc)
+	if err != nil {
+		return api.NotFound(), nil
+	}
+	recs, err := queryStream(hc, pk, from, limit)
+	if err != nil {
+		hc.Logger.WithError(err).Error("could not read changes")
+		return api.InternalError(), nil
+	}
+
+	prefix := r.QueryStringParameters["prefix"]
+	res := &changesResponse{Changes: []changeResponse{}, Next: from}
+	for _, rec := range recs {
+		res.Next = rec.Seq + 1
+		var c change
+		if err := json.Unmarshal(rec.Value, &c); err != nil {
+			hc.Logger.WithError(err).WithField("seq", rec.Seq).Error("could not unmarshal change")
+			continue
+		}
+		// a prefix deletion matches if it overlaps the requested prefix
+		if !strings.HasPrefix(c.Key, prefix) && !(c.Op == changeDelPrefix && strings.HasPrefix(prefix, c.Key)) {
+			continue
+		}
+		res.Changes = append(res.Changes, changeResponse{Seq: rec.Seq, Op: c.Op, Key: c.Key, ETag: c.ETag, Time: rec.Time})
+	}
+	return api.OKJSON(res), nil
+}
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/golang/mock/gomock"
+	log "github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+
+	"forta-bot-db/auth"
+	m "forta-bot-db/store/mocks"
+)
+
+func TestRecordChange(t *testing.T) {
+	bucket = "test-bucket"
+	kvTable = "test-kv"
+	changeFeed = true
+	recordRetryDelay = 0
+	t0 := time.UnixMilli(1_700_000_000_000)
+	now = func() time.Time { return t0 }
+	defer func() { changeFeed = false; now = time.Now }()
+	ctrl := gomock.NewController(t)
+	s := m.NewMockS3(ctrl)
+	d := m.NewMockDynamoDB(ctrl)
+
+	hc := &auth.HandlerCtx{
+		Ctx:     context.Background(),
+		BotID:   "0xbotId",
+		Scanner: "0xscanner",
+		PathKey: "config.json",
+		Scope:   auth.ScopeBot,
+		Logger:  log.WithField("test", true),
+		Store:   s,
+		DB:      d,
+	}
+
+	etag := `"abc"`
+	s.EXPECT().PutObject(hc.Ctx, gomock.Any()).Return(&s3.PutObjectOutput{ETag: &etag}, nil)
+	d.EXPECT().Query(hc.Ctx, gomock.Any()).DoAndReturn(func(ctx context.Context, input *dynamodb.QueryInput, _ ...func(*dynamodb.Options)) (*dynamodb.QueryOutput, error) {
+		assert.Equal(t, "changes#0xbotId/", input.ExpressionAttributeValues[":pk"].(*types.AttributeValueMemberS).Value)
+		return &dynamodb.QueryOutput{}, nil
+	})
+	d.EXPECT().PutItem(hc.Ctx, gomock.Any()).DoAndReturn(func(ctx context.Context, input *dynamodb.PutItemInput, _ ...func(*dynamodb.Options)) (*dynamodb.PutItemOutput, error) {
+		assert.Equal(t, seqKey(1), input.Item["sk"].(*types.AttributeValueMemberS).Value)
+		assert.JSONEq(t, `{"op":"put","key":"config.json","etag":"\"abc\""}`, string(input.Item["value"].(*types.AttributeValueMemberB).Value))
+		// changes expire
+		assert.Equal(t, strconv.FormatInt(t0.Add(changeRetention).Unix(), 10), input.Item["ttl"].(*types.AttributeValueMemberN).Value)
+		return &dynamodb.PutItemOutput{}, nil
+	})
+	resp, err := putObj(hc, events.APIGatewayV2HTTPRequest{Body: "{}"})
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+	// a change that keeps losing its sequence number to concurrent writes is appended again
+	s.EXPECT().PutObject(hc.Ctx, gomock.Any()).Return(&s3.PutObjectOutput{ETag: &etag}, nil)
+	gomock.InOrder(
+		d.EXPECT().Query(hc.Ctx, gomock.Any()).Return(&dynamodb.QueryOutput{}, nil),
+		d.EXPECT().PutItem(hc.Ctx, gomock.Any()).Return(nil, &types.ConditionalCheckFailedException{}).Times(maxAppendAttempts),
+		d.EXPECT().Query(hc.Ctx, gomock.Any()).Return(&dynamodb.QueryOutput{}, nil),
+		d.EXPECT().PutItem(hc.Ctx, gomock.Any()).Return(&dynamodb.PutItemOutput{}, nil),
+	)
+	resp, err = putObj(hc, events.APIGatewayV2HTTPRequest{Body: "{}"})
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+	// the object is written, so a failure to record the change does not fail the request
+	s.EXPECT().DeleteObject(hc.Ctx, gomock.Any()).Return(&s3.DeleteObjectOutput{}, nil)
+	d.EXPECT().Query(hc.Ctx, gomock.Any()).Return(nil, assert.AnError)
+	resp, err = delObj(hc)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+}
+
+func TestGetChanges(t *testing.T) {
+	kvTable = "test-kv"
+	ctrl := gomock.NewController(t)
+	d := m.NewMockDynamoDB(ctrl)
+
+	hc := &auth.HandlerCtx{
+		Ctx:     context.Background(),
+		BotID:   "0xbotId",
+		Scanner: "0xscanner",
+		Scope:   auth.ScopeBot,
+		Logger:  log.WithField("test", true),
+		DB:      d,
+	}
+
+	record := func(seq, value string) map[string]types.AttributeValue {
+		return map[string]types.AttributeValue{
+			"seq":   &types.AttributeValueMemberN{Value: seq},
+			"value": &types.AttributeValueMemberB{Value: []byte(value)},
+		}
+	}
+	d.EXPECT().Query(hc.Ctx, gomock.Any()).DoAndReturn(func(ctx context.Context, input *dynamodb.QueryInput, _ ...func(*dynamodb.Options)) (*dynamodb.QueryOutput, error) {
+		assert.Equal(t, seqKey(5), input.ExpressionAttributeValues[":from"].(*types.AttributeValueMemberS).Value)
+		return &dynamodb.QueryOutput{Items: []map[string]types.AttributeValue{
+			record("5", `{"op":"put","key":"config/a.json","etag":"\"1\""}`),
+			record("6", `{"op":"put","key":"state.json"}`),
+			record("7", `{"op":"delPrefix","key":"con"}`),
+		}}, nil
+	})
+	resp, err := route(hc, events.APIGatewayV2HTTPRequest{
+		RouteKey:              "GET " + changesPath,
+		QueryStringParameters: map[string]string{"from": "5", "prefix": "config/"},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	var res changesResponse
+	assert.NoError(t, json.Unmarshal([]byte(resp.Body), &res))
+	assert.Equal(t, int64(8), res.Next)
+	assert.Len(t, res.Changes, 2)
+	assert.Equal(t, "config/a.json", res.Changes[0].Key)
+	assert.Equal(t, changeDelPrefix, res.Changes[1].Op)
+	assert.False(t, strings.Contains(resp.Body, "state.json"))
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+
+	"github.com/aws/smithy-go"
+)
+
+var errChecksumMismatch = errors.New("checksum mismatch")
+
+// payloadChecksums verifies the optional Content-MD5 and X-Checksum-SHA256 headers against the body.
+// It returns the values to pass on to S3, which verifies them again on write; the SHA-256 is always
+// computed so it is stored with the object and can be returned on reads.
+func payloadChecksums(headers map[string]string, b []byte) (*string, *string, error) {
+	var contentMD5 *string
+	if v, ok := headers["content-md5"]; ok && v != "" {
+		sum := md5.Sum(b)
+		if v != base64.StdEncoding.EncodeToString(sum[:]) {
+			return nil, nil, errChecksumMismatch
+		}
+		contentMD5 = &v
+	}
+
+	sum := sha256.Sum256(b)
+	sha := base64.StdEncoding.EncodeToString(sum[:])
+	if v, ok := headers["x-checksum-sha256"]; ok && v != "" && v != sha {
+		return nil, nil, errChecksumMismatch
+	}
+	return contentMD5, &sha, nil
+}
+
+// isChecksumError reports whether s3 rejected a write because of its checksum
+func isChecksumError(err error) bool {
+	var ae smithy.APIError
+	if !errors.As(err, &ae) {
+		return false
+	}
+	switch ae.ErrorCode() {
+	case "BadDigest", "InvalidDigest", "XAmzContentChecksumMismatch":
+		return true
+	}
+	return false
+}
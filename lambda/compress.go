@@ -0,0 +1,180 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"strconv"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+// internalMetaPrefix marks metadata the server keeps for itself; it is neither accepted from nor returned to clients
+const internalMetaPrefix = "fbdb-"
+
+// objects compressed at rest record the encoding and the size and checksum of the uncompressed payload
+const metaEncoding = internalMetaPrefix + "encoding"
+const metaSize = internalMetaPrefix + "size"
+const metaSHA256 = internalMetaPrefix + "sha256"
+
+// maxInternalMetaSize is kept free of custom metadata in S3's 2 KB limit for the entries above
+const maxInternalMetaSize = 128
+
+// objectEncodingHeader carries the codec a writer encoded the payload with.  It is not Content-Encoding, as HTTP
+// clients would undo that on their own, and readers that decode the payload themselves would then decode it twice.
+const objectEncodingHeader = "X-Object-Encoding"
+
+// serverEncodingHeader tells clients that the Content-Encoding was applied by the server rather than by the writer
+const serverEncodingHeader = "X-Server-Encoding"
+
+// compressThreshold skips payloads too small to gain from compression
+const compressThreshold = 1024
+
+const encodingGzip = "gzip"
+
+// maxRangeSize keeps ranged reads of compressed objects under the lambda payload limit once base64 encoded
+const maxRangeSize = 4 * 1024 * 1024
+
+// compressible reports whether a payload may be compressed at rest.  Binary payloads are left alone, as clients
+// read them by range, and so are payloads written with Cache-Control: no-transform.
+func compressible(headers map[string]string) bool {
+	if strings.Contains(strings.ToLower(headers["cache-control"]), "no-transform") {
+		return false
+	}
+	return !strings.HasPrefix(strings.ToLower(headers["content-type"]), "application/octet-stream")
+}
+
+// compressPayload gzips b, reporting false if that does not make it smaller
+func compressPayload(b []byte) ([]byte, bool) {
+	var buf bytes.Buffer
+	w := gzip.NewWriter(&buf)
+	if _, err := w.Write(b); err != nil {
+		return nil, false
+	}
+	if err := w.Close(); err != nil {
+		return nil, false
+	}
+	if buf.Len() >= len(b) {
+		return nil, false
+	}
+	return buf.Bytes(), true
+}
+
+func decompressPayload(encoding string, b []byte) ([]byte, error) {
+	if encoding != encodingGzip {
+		return nil, fmt.Errorf("unsupported stored encoding %q", encoding)
+	}
+	r, err := gzip.NewReader(bytes.NewReader(b))
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	return ioutil.ReadAll(r)
+}
+
+var errRangeNotSatisfiable = errors.New("range not satisfiable")
+
+// parseRange reads a single range (bytes=a-b, bytes=a- or bytes=-n) of an object of the given size,
+// returning its first and last byte
+func parseRange(header string, size int64) (int64, int64, error) {
+	spec := strings.TrimPrefix(header, "bytes=")
+	dash := strings.Index(spec, "-")
+	if spec == header || dash < 0 || strings.Contains(spec, ",") {
+		return 0, 0, errors.New("only a single byte range is supported")
+	}
+	from, to := spec[:dash], spec[dash+1:]
+	if from == "" {
+		n, err := strconv.ParseInt(to, 10, 64)
+		if err != nil || n < 0 {
+			return 0, 0, errors.New("invalid range")
+		}
+		if n == 0 || size == 0 {
+			return 0, 0, errRangeNotSatisfiable
+		}
+		if n > size {
+			n = size
+		}
+		return size - n, size - 1, nil
+	}
+	first, err := strconv.ParseInt(from, 10, 64)
+	if err != nil || first < 0 {
+		return 0, 0, errors.New("invalid range")
+	}
+	last := size - 1
+	if to != "" {
+		if last, err = strconv.ParseInt(to, 10, 64); err != nil || last < first {
+			return 0, 0, errors.New("invalid range")
+		}
+		if last >= size {
+			last = size - 1
+		}
+	}
+	if first >= size {
+		return 0, 0, errRangeNotSatisfiable
+	}
+	return first, last, nil
+}
+
+// decompressRange decompresses b only as far as the range reaches
+func decompressRange(encoding string, b []byte, first, last int64) ([]byte, error) {
+	if encoding != encodingGzip {
+		return nil, fmt.Errorf("unsupported stored encoding %q", encoding)
+	}
+	r, err := gzip.NewReader(bytes.NewReader(b))
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	if _, err := io.CopyN(io.Discard, r, first); err != nil {
+		return nil, err
+	}
+	out := make([]byte, last-first+1)
+	if _, err := io.ReadFull(r, out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// compressAtRest replaces the body of input with its compressed form when that saves space.
+// The payload's checksums were verified before, so the ones sent to S3 are replaced with the checksum of the stored bytes.
+func compressAtRest(input *s3.PutObjectInput, b []byte, sha string) {
+	gz, ok := compressPayload(b)
+	if !ok {
+		return
+	}
+	input.Metadata[metaEncoding] = encodingGzip
+	input.Metadata[metaSize] = strconv.Itoa(len(b))
+	input.Metadata[metaSHA256] = sha
+	sum := sha256.Sum256(gz)
+	stored := base64.StdEncoding.EncodeToString(sum[:])
+	input.Body = bytes.NewReader(gz)
+	input.ContentMD5 = nil
+	input.ChecksumSHA256 = &stored
+}
+
+// acceptsEncoding reports whether an Accept-Encoding header allows the encoding
+func acceptsEncoding(header, encoding string) bool {
+	for _, part := range strings.Split(header, ",") {
+		fields := strings.Split(part, ";")
+		name := strings.ToLower(strings.TrimSpace(fields[0]))
+		if name != encoding && name != "*" {
+			continue
+		}
+		for _, param := range fields[1:] {
+			param = strings.TrimSpace(param)
+			if strings.HasPrefix(param, "q=") {
+				if q, err := strconv.ParseFloat(param[2:], 64); err == nil && q == 0 {
+					return false
+				}
+			}
+		}
+		return true
+	}
+	return false
+}
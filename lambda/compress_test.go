@@ -0,0 +1,186 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/golang/mock/gomock"
+	log "github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+
+	"forta-bot-db/auth"
+	m "forta-bot-db/store/mocks"
+)
+
+func sha256Base64(b []byte) string {
+	sum := sha256.Sum256(b)
+	return base64.StdEncoding.EncodeToString(sum[:])
+}
+
+func TestCompressAtRest(t *testing.T) {
+	bucket = "test-bucket"
+	ctrl := gomock.NewController(t)
+	s := m.NewMockS3(ctrl)
+
+	hc := &auth.HandlerCtx{
+		Ctx:     context.Background(),
+		BotID:   "0xbotId",
+		Scanner: "0xscanner",
+		PathKey: "state.json",
+		Scope:   auth.ScopeBot,
+		Logger:  log.WithField("test", true),
+		Store:   s,
+	}
+	payload := []byte(strings.Repeat(`{"address":"0xdeadbeef","count":1}`, 100))
+
+	var stored []byte
+	var meta map[string]string
+	var storedSum string
+	s.EXPECT().PutObject(hc.Ctx, gomock.Any()).DoAndReturn(func(ctx context.Context, input *s3.PutObjectInput, _ ...func(*s3.Options)) (*s3.PutObjectOutput, error) {
+		stored, _ = io.ReadAll(input.Body)
+		meta = input.Metadata
+		storedSum = *input.ChecksumSHA256
+		return &s3.PutObjectOutput{}, nil
+	})
+	resp, err := putObj(hc, events.APIGatewayV2HTTPRequest{
+		Body:    string(payload),
+		Headers: map[string]string{"x-checksum-sha256": sha256Base64(payload)},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Less(t, len(stored), len(payload))
+	assert.Equal(t, encodingGzip, meta[metaEncoding])
+	assert.Equal(t, sha256Base64(stored), storedSum)
+
+	getStored := func() (*s3.GetObjectOutput, error) {
+		return &s3.GetObjectOutput{
+			Body:           io.NopCloser(bytes.NewReader(stored)),
+			ContentLength:  int64(len(stored)),
+			ChecksumSHA256: &storedSum,
+			Metadata:       meta,
+		}, nil
+	}
+
+	// clients that accept gzip get the stored bytes
+	s.EXPECT().GetObject(hc.Ctx, gomock.Any()).DoAndReturn(func(ctx context.Context, input *s3.GetObjectInput, _ ...func(*s3.Options)) (*s3.GetObjectOutput, error) {
+		return getStored()
+	})
+	resp, err = getObj(hc, events.APIGatewayV2HTTPRequest{Headers: map[string]string{"accept-encoding": "gzip, deflate"}})
+	assert.NoError(t, err)
+	body, _ := base64.StdEncoding.DecodeString(resp.Body)
+	assert.Equal(t, stored, body)
+	assert.Equal(t, "gzip", resp.Headers["Content-Encoding"])
+	assert.Equal(t, "gzip", resp.Headers[serverEncodingHeader])
+	assert.Equal(t, storedSum, resp.Headers["X-Checksum-SHA256"])
+	assert.NotContains(t, resp.Headers, "X-Meta-"+metaEncoding)
+
+	// other clients get the payload as written
+	s.EXPECT().GetObject(hc.Ctx, gomock.Any()).DoAndReturn(func(ctx context.Context, input *s3.GetObjectInput, _ ...func(*s3.Options)) (*s3.GetObjectOutput, error) {
+		return getStored()
+	})
+	resp, err = getObj(hc, events.APIGatewayV2HTTPRequest{Headers: map[string]string{"accept-encoding": "gzip;q=0"}})
+	assert.NoError(t, err)
+	body, _ = base64.StdEncoding.DecodeString(resp.Body)
+	assert.Equal(t, payload, body)
+	assert.Empty(t, resp.Headers["Content-Encoding"])
+	assert.Equal(t, sha256Base64(payload), resp.Headers["X-Checksum-SHA256"])
+	assert.Equal(t, strconv.Itoa(len(payload)), resp.Headers["X-Object-Size"])
+
+	// ranges address the payload as written, so only the range is decompressed
+	gomock.InOrder(
+		s.EXPECT().GetObject(hc.Ctx, gomock.Any()).DoAndReturn(func(ctx context.Context, input *s3.GetObjectInput, _ ...func(*s3.Options)) (*s3.GetObjectOutput, error) {
+			assert.NotNil(t, input.Range)
+			return getStored()
+		}),
+		s.EXPECT().GetObject(hc.Ctx, gomock.Any()).DoAndReturn(func(ctx context.Context, input *s3.GetObjectInput, _ ...func(*s3.Options)) (*s3.GetObjectOutput, error) {
+			assert.Nil(t, input.Range)
+			return getStored()
+		}),
+	)
+	resp, err = getObj(hc, events.APIGatewayV2HTTPRequest{Headers: map[string]string{"range": "bytes=0-9", "accept-encoding": "gzip"}})
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusPartialContent, resp.StatusCode)
+	assert.Equal(t, "bytes 0-9/"+strconv.Itoa(len(payload)), resp.Headers["Content-Range"])
+	assert.Empty(t, resp.Headers["Content-Encoding"])
+	body, _ = base64.StdEncoding.DecodeString(resp.Body)
+	assert.Equal(t, payload[:10], body)
+
+	// internal metadata cannot be written by clients
+	resp, err = putObj(hc, events.APIGatewayV2HTTPRequest{
+		Body:    "{}",
+		Headers: map[string]string{"x-meta-" + metaEncoding: "none"},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+}
+
+func TestCompressedRange(t *testing.T) {
+	bucket = "test-bucket"
+	ctrl := gomock.NewController(t)
+	s := m.NewMockS3(ctrl)
+
+	hc := &auth.HandlerCtx{
+		Ctx:     context.Background(),
+		BotID:   "0xbotId",
+		PathKey: "log.txt",
+		Scope:   auth.ScopeBot,
+		Logger:  log.WithField("test", true),
+		Store:   s,
+	}
+	// 20 MB as written, far more than a response can carry
+	line := "0123456789abcdef"
+	payload := []byte(strings.Repeat(line, 20*1024*1024/len(line)))
+	stored, ok := compressPayload(payload)
+	assert.True(t, ok)
+	meta := map[string]string{metaEncoding: encodingGzip, metaSize: strconv.Itoa(len(payload)), metaSHA256: sha256Base64(payload)}
+	s.EXPECT().GetObject(hc.Ctx, gomock.Any()).DoAndReturn(func(ctx context.Context, input *s3.GetObjectInput, _ ...func(*s3.Options)) (*s3.GetObjectOutput, error) {
+		return &s3.GetObjectOutput{Body: io.NopCloser(bytes.NewReader(stored)), ContentLength: int64(len(stored)), Metadata: meta}, nil
+	}).AnyTimes()
+	get := func(rng string) events.APIGatewayV2HTTPResponse {
+		resp, err := getObj(hc, events.APIGatewayV2HTTPRequest{Headers: map[string]string{"range": rng}})
+		assert.NoError(t, err)
+		return resp
+	}
+
+	resp := get("bytes=10000000-10000019")
+	assert.Equal(t, http.StatusPartialContent, resp.StatusCode)
+	assert.Equal(t, "bytes 10000000-10000019/20971520", resp.Headers["Content-Range"])
+	body, _ := base64.StdEncoding.DecodeString(resp.Body)
+	assert.Equal(t, payload[10000000:10000020], body)
+
+	resp = get("bytes=-5")
+	assert.Equal(t, http.StatusPartialContent, resp.StatusCode)
+	body, _ = base64.StdEncoding.DecodeString(resp.Body)
+	assert.Equal(t, payload[len(payload)-5:], body)
+
+	assert.Equal(t, http.StatusBadRequest, get("bytes=0-").StatusCode)
+	assert.Equal(t, http.StatusBadRequest, get("bytes=0-1,5-6").StatusCode)
+	assert.Equal(t, http.StatusRequestedRangeNotSatisfiable, get("bytes=30000000-").StatusCode)
+}
+
+func TestCompressible(t *testing.T) {
+	assert.True(t, compressible(map[string]string{"content-type": "application/json"}))
+	assert.True(t, compressible(map[string]string{}))
+	assert.False(t, compressible(map[string]string{"content-type": "application/octet-stream"}))
+	assert.False(t, compressible(map[string]string{"cache-control": "no-transform"}))
+}
+
+func TestAcceptsEncoding(t *testing.T) {
+	assert.True(t, acceptsEncoding("gzip", "gzip"))
+	assert.True(t, acceptsEncoding("identity, gzip, snappy, zstd", "gzip"))
+	assert.True(t, acceptsEncoding("br;q=1.0, gzip;q=0.8", "gzip"))
+	assert.True(t, acceptsEncoding("*", "gzip"))
+	assert.False(t, acceptsEncoding("", "gzip"))
+	assert.False(t, acceptsEncoding("br, deflate", "gzip"))
+	assert.False(t, acceptsEncoding("gzip;q=0", "gzip"))
+	assert.False(t, acceptsEncoding("gzip; q=0.000", "gzip"))
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+
+	"forta-bot-db/api"
+	"forta-bot-db/auth"
+)
+
+const copyRoute = "POST /database/{scope}/{key}/copy"
+const moveRoute = "POST /database/{scope}/{key}/move"
+
+// copySource url-encodes bucket/key for CopyObject, keeping the path separators
+func copySource(key string) string {
+	parts := strings.Split(bucket+"/"+key, "/")
+	for i, p := range parts {
+		parts[i] = url.PathEscape(p)
+	}
+	return strings.Join(parts, "/")
+}
+
+// copyObj copies the object to the ?to={scope}/{key} destination without it passing through the lambda.
+// Content type, encoding and metadata are copied with it.  A move deletes the source once the copy exists.
+func copyObj(hc *auth.HandlerCtx, move bool) (events.APIGatewayV2HTTPResponse, error) {
+	key, err := hc.GetObjectKey()
+	if err != nil {
+		return api.NotFound(), nil
+	}
+	destKey, err := hc.GetDestObjectKey()
+	if err != nil {
+		return api.BadRequest(err.Error()), nil
+	}
+	if reservedKey(hc.DestScope, hc.DestKey) {
+		return api.BadRequest(fmt.Sprintf("%q is a reserved key", hc.DestKey)), nil
+	}
+	if scannerKey(hc.DestScope, hc.DestKey) {
+		return api.BadRequest("the bot scope cannot address scanner objects"), nil
+	}
+	if key == destKey {
+		return api.BadRequest("destination must differ from source"), nil
+	}
+
+	src := copySource(key)
+	out, err := hc.Store.CopyObject(hc.Ctx, &s3.CopyObjectInput{
+		Bucket:            &bucket,
+		Key:               &destKey,
+		CopySource:        &src,
+		ChecksumAlgorithm: types.ChecksumAlgorithmSha256,
+	})
+	if s3Status(err) == http.StatusNotFound {
+		return api.NotFound(), nil
+	}
+	if err != nil {
+		hc.Logger.WithError(err).Error("could not copy object")
+		return api.InternalError(), nil
+	}
+	destCtx := *hc
+	destCtx.Scope = hc.DestScope
+	var etag string
+	if out.CopyObjectResult != nil {
+		etag = aws.ToString(out.CopyObjectResult.ETag)
+	}
+	recordChange(&destCtx, changePut, hc.DestKey, etag)
+
+	if move {
+		_, err = hc.Store.DeleteObject(hc.Ctx, &s3.DeleteObjectInput{
+			Bucket: &bucket,
+			Key:    &key,
+		})
+		if err != nil {
+			hc.Logger.WithError(err).Error("could not delete moved object")
+			return api.InternalError(), nil
+		}
+		recordChange(hc, changeDelete, hc.PathKey, "")
+	}
+	return api.OK(), nil
+}
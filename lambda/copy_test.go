@@ -0,0 +1,65 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/golang/mock/gomock"
+	log "github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+
+	"forta-bot-db/auth"
+	m "forta-bot-db/store/mocks"
+)
+
+func TestCopyObj(t *testing.T) {
+	bucket = "test-bucket"
+	ctrl := gomock.NewController(t)
+	s := m.NewMockS3(ctrl)
+
+	hc := &auth.HandlerCtx{
+		Ctx:       context.Background(),
+		BotID:     "0xbotId",
+		Scanner:   "0xscanner",
+		Owner:     "0xowner",
+		PathKey:   "result.json",
+		Scope:     auth.ScopeScanner,
+		DestKey:   "shared.json",
+		DestScope: auth.ScopeOwner,
+		Logger:    log.WithField("test", true),
+		Store:     s,
+	}
+
+	s.EXPECT().CopyObject(hc.Ctx, gomock.Any()).DoAndReturn(func(ctx context.Context, input *s3.CopyObjectInput, _ ...func(*s3.Options)) (*s3.CopyObjectOutput, error) {
+		assert.Equal(t, "test-bucket/0xbotId/0xscanner/result.json", *input.CopySource)
+		assert.Equal(t, "owner/0xowner/shared.json", *input.Key)
+		return &s3.CopyObjectOutput{}, nil
+	}).Times(2)
+	s.EXPECT().DeleteObject(hc.Ctx, gomock.Any()).DoAndReturn(func(ctx context.Context, input *s3.DeleteObjectInput, _ ...func(*s3.Options)) (*s3.DeleteObjectOutput, error) {
+		assert.Equal(t, "0xbotId/0xscanner/result.json", *input.Key)
+		return &s3.DeleteObjectOutput{}, nil
+	}).Times(1)
+
+	resp, err := route(hc, events.APIGatewayV2HTTPRequest{RouteKey: copyRoute})
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+	resp, err = route(hc, events.APIGatewayV2HTTPRequest{RouteKey: moveRoute})
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+	// moving onto itself would delete the object
+	hc.DestKey, hc.DestScope = hc.PathKey, hc.Scope
+	resp, err = route(hc, events.APIGatewayV2HTTPRequest{RouteKey: moveRoute})
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+
+	// the bot scope cannot reach into a scanner's directory
+	hc.DestKey, hc.DestScope = "0x1111111111111111111111111111111111111111/state.json", auth.ScopeBot
+	resp, err = route(hc, events.APIGatewayV2HTTPRequest{RouteKey: copyRoute})
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+}
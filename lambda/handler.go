@@ -4,14 +4,21 @@ import (
 	"bytes"
 	"context"
 	"encoding/base64"
+	"errors"
+	"fmt"
 	"io/ioutil"
+	"net/http"
 	"os"
+	"strconv"
 	"strings"
 	"time"
 
 	"github.com/aws/aws-lambda-go/events"
 	"github.com/aws/aws-lambda-go/lambda"
+	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	smithyhttp "github.com/aws/smithy-go/transport/http"
 	log "github.com/sirupsen/logrus"
 
 	"forta-bot-db/api"
@@ -20,54 +27,312 @@ import (
 
 var bucket = os.Getenv("bucket")
 
-func getObj(hc *auth.HandlerCtx) (events.APIGatewayV2HTTPResponse, error) {
+// s3Status returns the http status code of an s3 error, or 0 if there is none
+func s3Status(err error) int {
+	var re interface{ HTTPStatusCode() int }
+	if errors.As(err, &re) {
+		return re.HTTPStatusCode()
+	}
+	return 0
+}
+
+// objectInfo is the metadata shared by GetObject and HeadObject outputs
+type objectInfo struct {
+	Size            int64
+	ETag            *string
+	LastModified    *time.Time
+	ContentType     *string
+	ContentEncoding *string
+	ChecksumSHA256  *string
+	Metadata        map[string]string
+}
+
+// objectHeaders describes the object as written, so compression at rest is not visible
+func objectHeaders(info *objectInfo) map[string]string {
+	size := strconv.FormatInt(info.Size, 10)
+	if info.Metadata[metaEncoding] != "" {
+		size = info.Metadata[metaSize]
+		sha := info.Metadata[metaSHA256]
+		info.ChecksumSHA256 = &sha
+	}
+	h := map[string]string{
+		"X-Object-Size": size,
+	}
+	if info.ETag != nil {
+		h["ETag"] = *info.ETag
+	}
+	if info.LastModified != nil {
+		h["Last-Modified"] = info.LastModified.UTC().Format(http.TimeFormat)
+	}
+	if info.ContentType != nil {
+		h["Content-Type"] = *info.ContentType
+	}
+	if info.ContentEncoding != nil {
+		h[objectEncodingHeader] = *info.ContentEncoding
+	}
+	if info.ChecksumSHA256 != nil {
+		h["X-Checksum-SHA256"] = *info.ChecksumSHA256
+	}
+	for k, v := range info.Metadata {
+		if strings.HasPrefix(k, internalMetaPrefix) {
+			continue
+		}
+		h["X-Meta-"+k] = v
+	}
+	return h
+}
+
+func getObj(hc *auth.HandlerCtx, r events.APIGatewayV2HTTPRequest) (events.APIGatewayV2HTTPResponse, error) {
 	key, err := hc.GetObjectKey()
 	if err != nil {
 		return api.NotFound(), nil
 	}
 
-	res, err := hc.Store.GetObject(hc.Ctx, &s3.GetObjectInput{
-		Bucket: &bucket,
-		Key:    &key,
-	})
+	input := &s3.GetObjectInput{
+		Bucket:       &bucket,
+		Key:          &key,
+		ChecksumMode: types.ChecksumModeEnabled,
+	}
+	if etag, ok := r.Headers["if-none-match"]; ok && etag != "" {
+		input.IfNoneMatch = &etag
+	}
+	if rng, ok := r.Headers["range"]; ok && rng != "" {
+		input.Range = &rng
+		// stored checksums cover the whole object, not a slice of it
+		input.ChecksumMode = ""
+	}
+	res, err := hc.Store.GetObject(hc.Ctx, input)
 
+	switch s3Status(err) {
+	case http.StatusNotFound:
+		return api.NotFound(), nil
+	case http.StatusNotModified:
+		return api.NotModified(), nil
+	case http.StatusRequestedRangeNotSatisfiable:
+		return api.RangeNotSatisfiable(), nil
+	}
 	if err != nil {
 		hc.Logger.WithError(err).Error("error getting object from s3")
 		return api.InternalError(), nil
 	}
 
+	encoding := res.Metadata[metaEncoding]
+	ranged := input.Range != nil
+	if encoding != "" && ranged {
+		// the range addresses the compressed bytes, so read the whole object and decompress the range from it
+		res.Body.Close()
+		input.Range = nil
+		input.ChecksumMode = types.ChecksumModeEnabled
+		res, err = hc.Store.GetObject(hc.Ctx, input)
+		if err != nil {
+			hc.Logger.WithError(err).Error("error getting object from s3")
+			return api.InternalError(), nil
+		}
+	}
+
 	b, err := ioutil.ReadAll(res.Body)
 	if err != nil {
 		hc.Logger.WithError(err).Error("error reading body from object")
 		return api.InternalError(), nil
 	}
-	return api.OKBytes(b), nil
+	headers := objectHeaders(&objectInfo{
+		Size:            res.ContentLength,
+		ETag:            res.ETag,
+		LastModified:    res.LastModified,
+		ContentType:     res.ContentType,
+		ContentEncoding: res.ContentEncoding,
+		ChecksumSHA256:  res.ChecksumSHA256,
+		Metadata:        res.Metadata,
+	})
+	if encoding != "" && ranged {
+		return compressedRange(hc, r.Headers["range"], b, headers, res.Metadata)
+	}
+	if encoding != "" {
+		if acceptsEncoding(r.Headers["accept-encoding"], encoding) {
+			// pass the compressed bytes through; the checksum describes the body as sent
+			headers["Content-Encoding"] = encoding
+			headers[serverEncodingHeader] = encoding
+			delete(headers, "X-Checksum-SHA256")
+			if res.ChecksumSHA256 != nil {
+				headers["X-Checksum-SHA256"] = *res.ChecksumSHA256
+			}
+		} else {
+			b, err = decompressPayload(encoding, b)
+			if err != nil {
+				hc.Logger.WithError(err).Error("error decompressing object")
+				return api.InternalError(), nil
+			}
+		}
+	}
+	resp := api.OKBytes(b)
+	resp.Headers = headers
+	if res.ContentRange != nil {
+		resp.StatusCode = http.StatusPartialContent
+		resp.Headers["Content-Range"] = *res.ContentRange
+		delete(resp.Headers, "X-Checksum-SHA256")
+	}
+	return resp, nil
 }
-func putObj(hc *auth.HandlerCtx, r events.APIGatewayV2HTTPRequest) (events.APIGatewayV2HTTPResponse, error) {
-	var b []byte
-	bodyStr := r.Body
-	b = []byte(bodyStr)
+
+// compressedRange returns a range of an object compressed at rest, addressed in the payload as written
+func compressedRange(hc *auth.HandlerCtx, rng string, b []byte, headers map[string]string, meta map[string]string) (events.APIGatewayV2HTTPResponse, error) {
+	size, err := strconv.ParseInt(meta[metaSize], 10, 64)
+	if err != nil {
+		hc.Logger.WithError(err).Error("invalid size of compressed object")
+		return api.InternalError(), nil
+	}
+	first, last, err := parseRange(rng, size)
+	if errors.Is(err, errRangeNotSatisfiable) {
+		return api.RangeNotSatisfiable(), nil
+	}
+	if err != nil {
+		return api.BadRequest(err.Error()), nil
+	}
+	if last-first+1 > maxRangeSize {
+		return api.BadRequest(fmt.Sprintf("ranges of compressed objects must not exceed %d bytes", maxRangeSize)), nil
+	}
+	b, err = decompressRange(meta[metaEncoding], b, first, last)
+	if err != nil {
+		hc.Logger.WithError(err).Error("error decompressing object")
+		return api.InternalError(), nil
+	}
+	resp := api.OKBytes(b)
+	resp.StatusCode = http.StatusPartialContent
+	resp.Headers = headers
+	resp.Headers["Content-Range"] = fmt.Sprintf("bytes %d-%d/%d", first, last, size)
+	delete(resp.Headers, "X-Checksum-SHA256")
+	return resp, nil
+}
+
+// statObj returns the object's metadata as headers, without the body
+func statObj(hc *auth.HandlerCtx) (events.APIGatewayV2HTTPResponse, error) {
+	key, err := hc.GetObjectKey()
+	if err != nil {
+		return api.NotFound(), nil
+	}
+
+	res, err := hc.Store.HeadObject(hc.Ctx, &s3.HeadObjectInput{
+		Bucket:       &bucket,
+		Key:          &key,
+		ChecksumMode: types.ChecksumModeEnabled,
+	})
+	if s3Status(err) == http.StatusNotFound {
+		return api.NotFound(), nil
+	}
+	if err != nil {
+		hc.Logger.WithError(err).Error("error getting object metadata from s3")
+		return api.InternalError(), nil
+	}
+
+	return api.OKHead(objectHeaders(&objectInfo{
+		Size:            res.ContentLength,
+		ETag:            res.ETag,
+		LastModified:    res.LastModified,
+		ContentType:     res.ContentType,
+		ContentEncoding: res.ContentEncoding,
+		ChecksumSHA256:  res.ChecksumSHA256,
+		Metadata:        res.Metadata,
+	})), nil
+}
+
+// requestBody returns the request payload, which lambda base64 encodes when it is binary
+func requestBody(r events.APIGatewayV2HTTPRequest) ([]byte, error) {
 	if r.IsBase64Encoded {
-		bs, err := base64.StdEncoding.DecodeString(bodyStr)
-		if err != nil {
-			hc.Logger.WithError(err).Error("could not decode body")
-			return api.InternalError(), nil
+		return base64.StdEncoding.DecodeString(r.Body)
+	}
+	return []byte(r.Body), nil
+}
+
+// conditionalPut passes If-Match and If-None-Match: * on to S3, which checks them atomically with the write.
+// The S3 SDK in use predates the input fields for them, so they are sent as headers.
+func conditionalPut(headers map[string]string) ([]func(*s3.Options), error) {
+	var opts []func(*s3.Options)
+	if v := headers["if-none-match"]; v != "" && v != "*" {
+		return nil, errors.New("if-none-match on writes must be *")
+	}
+	for _, name := range []string{"If-Match", "If-None-Match"} {
+		if v := headers[strings.ToLower(name)]; v != "" {
+			mutator := smithyhttp.SetHeaderValue(name, v)
+			opts = append(opts, func(o *s3.Options) {
+				o.APIOptions = append(o.APIOptions, mutator)
+			})
 		}
-		b = bs
+	}
+	return opts, nil
+}
+
+// reservedKey reports whether key addresses an endpoint rather than an object, so it cannot be written
+func reservedKey(scope auth.Scope, key string) bool {
+	return key == batchKey || (scope == auth.ScopePublic && reservedPublicKeys[key])
+}
+
+// scannerKey reports whether a bot scope key reaches into one of the scanner directories nested in the bot's prefix.
+// Path keys are single segments, but keys from a batch body or a copy destination can hold "/".
+func scannerKey(scope auth.Scope, key string) bool {
+	return scope == auth.ScopeBot && scannerDir.MatchString(key)
+}
+
+func putObj(hc *auth.HandlerCtx, r events.APIGatewayV2HTTPRequest) (events.APIGatewayV2HTTPResponse, error) {
+	b, err := requestBody(r)
+	if err != nil {
+		hc.Logger.WithError(err).Error("could not decode body")
+		return api.InternalError(), nil
+	}
+	if reservedKey(hc.Scope, hc.PathKey) {
+		return api.BadRequest(fmt.Sprintf("%q is a reserved key", hc.PathKey)), nil
 	}
 	key, err := hc.GetObjectKey()
 	if err != nil {
 		return api.NotFound(), nil
 	}
-	_, err = hc.Store.PutObject(hc.Ctx, &s3.PutObjectInput{
-		Bucket: &bucket,
-		Key:    &key,
-		Body:   bytes.NewReader(b),
-	})
+	meta, err := requestMetadata(r.Headers)
+	if err != nil {
+		return api.BadRequest(err.Error()), nil
+	}
+	contentMD5, sha, err := payloadChecksums(r.Headers, b)
+	if err != nil {
+		return api.BadRequest(err.Error()), nil
+	}
+	conditions, err := conditionalPut(r.Headers)
+	if err != nil {
+		return api.BadRequest(err.Error()), nil
+	}
+	input := &s3.PutObjectInput{
+		Bucket:            &bucket,
+		Key:               &key,
+		Body:              bytes.NewReader(b),
+		Metadata:          meta,
+		ContentMD5:        contentMD5,
+		ChecksumAlgorithm: types.ChecksumAlgorithmSha256,
+		ChecksumSHA256:    sha,
+	}
+	// records how the client encoded the payload, so readers can decode it regardless of key name.
+	// Content-Encoding is still read from writers that sent the codec that way.
+	enc := r.Headers[strings.ToLower(objectEncodingHeader)]
+	if enc == "" {
+		enc = r.Headers["content-encoding"]
+	}
+	if enc != "" {
+		input.ContentEncoding = &enc
+	} else if len(b) >= compressThreshold && compressible(r.Headers) {
+		compressAtRest(input, b, *sha)
+	}
+	if ct, ok := r.Headers["content-type"]; ok && ct != "" {
+		input.ContentType = &ct
+	}
+	out, err := hc.Store.PutObject(hc.Ctx, input, conditions...)
+	if isChecksumError(err) {
+		return api.BadRequest(errChecksumMismatch.Error()), nil
+	}
+	// 409 means a concurrent conditional write to the object is in progress, which will change its ETag
+	if status := s3Status(err); status == http.StatusPreconditionFailed || status == http.StatusConflict {
+		return api.PreconditionFailed(), nil
+	}
 	if err != nil {
 		hc.Logger.WithError(err).Error("could not write object")
 		return api.InternalError(), nil
 	}
+	recordChange(hc, changePut, hc.PathKey, aws.ToString(out.ETag))
 	return api.OK(), nil
 }
 func delObj(hc *auth.HandlerCtx) (events.APIGatewayV2HTTPResponse, error) {
@@ -83,18 +348,63 @@ func delObj(hc *auth.HandlerCtx) (events.APIGatewayV2HTTPResponse, error) {
 		hc.Logger.WithError(err).Error("could not delete object")
 		return api.InternalError(), nil
 	}
+	recordChange(hc, changeDelete, hc.PathKey, "")
 	return api.OK(), nil
 }
 
+// routePath returns the path template of the matched route, e.g. /kv/{scope}/{key}
+func routePath(r events.APIGatewayV2HTTPRequest) string {
+	if i := strings.Index(r.RouteKey, " "); i >= 0 {
+		return r.RouteKey[i+1:]
+	}
+	return r.RouteKey
+}
+
 func route(hc *auth.HandlerCtx, r events.APIGatewayV2HTTPRequest) (events.APIGatewayV2HTTPResponse, error) {
+	switch r.RouteKey {
+	case copyRoute:
+		return copyObj(hc, false)
+	case moveRoute:
+		return copyObj(hc, true)
+	}
+	switch routePath(r) {
+	case kvPath, kvIncrPath, kvCASPath:
+		return kvRoute(hc, r)
+	case lockPath, lockRenewPath:
+		return lockRoute(hc, r)
+	case streamPath:
+		return streamRoute(hc, r)
+	case changesPath:
+		return getChanges(hc, r)
+	case aclPath:
+		return aclRoute(hc, r)
+	case publicPath:
+		return publicRoute(hc, r)
+	case inboxPath, inboxListPath:
+		return inboxRoute(hc, r)
+	case rateLimitPath:
+		return rateLimitRoute(hc, r)
+	}
 	switch strings.ToLower(r.RequestContext.HTTP.Method) {
 	case "get":
-		return getObj(hc)
+		if r.QueryStringParameters["watch"] == "true" {
+			return watchObj(hc, r)
+		}
+		return getObj(hc, r)
+	case "head":
+		return statObj(hc)
 	case "put":
 		return putObj(hc, r)
 	case "post":
+		if hc.PathKey == batchKey {
+			return batchObj(hc, r)
+		}
 		return putObj(hc, r)
 	case "delete":
+		// only DELETE /database/{scope}?prefix= has no key
+		if hc.PathKey == "" {
+			return delPrefix(hc, r)
+		}
 		return delObj(hc)
 	default:
 		hc.Logger.Warn("method not allowed")
@@ -119,6 +429,10 @@ func Handler(r events.APIGatewayV2HTTPRequest) (events.APIGatewayV2HTTPResponse,
 	}
 
 	hc, err := a.Authorize(ctx, r)
+	if errors.Is(err, auth.ErrNoGrant) {
+		log.WithError(err).Warn("forbidden")
+		return api.Forbidden(), nil
+	}
 	if err != nil {
 		log.WithError(err).Error("unauthorized")
 		return api.Unauthorized(), nil
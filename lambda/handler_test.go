@@ -2,12 +2,19 @@ package main
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/base64"
 	"forta-bot-db/auth"
 	"io"
+	"net/http"
 	"strings"
 	"testing"
+	"time"
 
+	"github.com/aws/aws-lambda-go/events"
+	awshttp "github.com/aws/aws-sdk-go-v2/aws/transport/http"
 	"github.com/aws/aws-sdk-go-v2/service/s3"
+	smithyhttp "github.com/aws/smithy-go/transport/http"
 	"github.com/golang/mock/gomock"
 	log "github.com/sirupsen/logrus"
 	"github.com/stretchr/testify/assert"
@@ -32,7 +39,286 @@ func TestRoute(t *testing.T) {
 	body := "test"
 	s.EXPECT().GetObject(hc.Ctx, gomock.Any()).Return(&s3.GetObjectOutput{Body: io.NopCloser(strings.NewReader(body))}, nil)
 
-	_, err := getObj(hc)
+	_, err := getObj(hc, events.APIGatewayV2HTTPRequest{})
 
 	assert.NoError(t, err)
 }
+
+func TestContentEncoding(t *testing.T) {
+	bucket = "test-bucket"
+	ctrl := gomock.NewController(t)
+	s := m.NewMockS3(ctrl)
+
+	hc := &auth.HandlerCtx{
+		Ctx:     context.Background(),
+		BotID:   "0xbotId",
+		Scanner: "0xscanner",
+		PathKey: "cache.json",
+		Scope:   auth.ScopeBot,
+		Logger:  log.WithField("test", true),
+		Store:   s,
+	}
+
+	s.EXPECT().PutObject(hc.Ctx, gomock.Any()).DoAndReturn(func(ctx context.Context, input *s3.PutObjectInput, _ ...func(*s3.Options)) (*s3.PutObjectOutput, error) {
+		assert.Equal(t, "zstd", *input.ContentEncoding)
+		return &s3.PutObjectOutput{}, nil
+	})
+	resp, err := putObj(hc, events.APIGatewayV2HTTPRequest{
+		Body:    "test",
+		Headers: map[string]string{"x-object-encoding": "zstd"},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, 200, resp.StatusCode)
+
+	enc := "zstd"
+	s.EXPECT().GetObject(hc.Ctx, gomock.Any()).Return(&s3.GetObjectOutput{
+		Body:            io.NopCloser(strings.NewReader("test")),
+		ContentEncoding: &enc,
+	}, nil)
+	resp, err = getObj(hc, events.APIGatewayV2HTTPRequest{})
+	assert.NoError(t, err)
+	// the codec is not an HTTP content coding, which clients would undo before the reader decodes it again
+	assert.Equal(t, "zstd", resp.Headers["X-Object-Encoding"])
+	assert.Empty(t, resp.Headers["Content-Encoding"])
+}
+
+func TestGetObjNotModified(t *testing.T) {
+	bucket = "test-bucket"
+	ctrl := gomock.NewController(t)
+	s := m.NewMockS3(ctrl)
+
+	hc := &auth.HandlerCtx{
+		Ctx:     context.Background(),
+		BotID:   "0xbotId",
+		Scanner: "0xscanner",
+		PathKey: "config.json",
+		Scope:   auth.ScopeOwner,
+		Logger:  log.WithField("test", true),
+		Store:   s,
+	}
+
+	notModified := &awshttp.ResponseError{
+		ResponseError: &smithyhttp.ResponseError{
+			Response: &smithyhttp.Response{Response: &http.Response{StatusCode: http.StatusNotModified}},
+		},
+	}
+	s.EXPECT().GetObject(hc.Ctx, gomock.Any()).DoAndReturn(func(ctx context.Context, input *s3.GetObjectInput, _ ...func(*s3.Options)) (*s3.GetObjectOutput, error) {
+		assert.Equal(t, `"abc"`, *input.IfNoneMatch)
+		return nil, notModified
+	})
+
+	resp, err := getObj(hc, events.APIGatewayV2HTTPRequest{
+		Headers: map[string]string{"if-none-match": `"abc"`},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusNotModified, resp.StatusCode)
+}
+
+func TestStatObj(t *testing.T) {
+	bucket = "test-bucket"
+	ctrl := gomock.NewController(t)
+	s := m.NewMockS3(ctrl)
+
+	hc := &auth.HandlerCtx{
+		Ctx:     context.Background(),
+		BotID:   "0xbotId",
+		Scanner: "0xscanner",
+		PathKey: "snapshot.json",
+		Scope:   auth.ScopeBot,
+		Logger:  log.WithField("test", true),
+		Store:   s,
+	}
+
+	etag := `"abc"`
+	modified := time.Date(2023, 3, 1, 12, 0, 0, 0, time.UTC)
+	s.EXPECT().HeadObject(hc.Ctx, gomock.Any()).DoAndReturn(func(ctx context.Context, input *s3.HeadObjectInput, _ ...func(*s3.Options)) (*s3.HeadObjectOutput, error) {
+		assert.Equal(t, "0xbotId/snapshot.json", *input.Key)
+		return &s3.HeadObjectOutput{
+			ContentLength: 1024,
+			ETag:          &etag,
+			LastModified:  &modified,
+			Metadata:      map[string]string{"schema": "2"},
+		}, nil
+	})
+	resp, err := statObj(hc)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Empty(t, resp.Body)
+	assert.Equal(t, "1024", resp.Headers["X-Object-Size"])
+	assert.Equal(t, etag, resp.Headers["ETag"])
+	assert.Equal(t, "Wed, 01 Mar 2023 12:00:00 GMT", resp.Headers["Last-Modified"])
+	assert.Equal(t, "2", resp.Headers["X-Meta-schema"])
+
+	notFound := &awshttp.ResponseError{
+		ResponseError: &smithyhttp.ResponseError{
+			Response: &smithyhttp.Response{Response: &http.Response{StatusCode: http.StatusNotFound}},
+		},
+	}
+	s.EXPECT().HeadObject(hc.Ctx, gomock.Any()).Return(nil, notFound)
+	resp, err = statObj(hc)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+}
+
+func TestPutObjMetadata(t *testing.T) {
+	bucket = "test-bucket"
+	ctrl := gomock.NewController(t)
+	s := m.NewMockS3(ctrl)
+
+	hc := &auth.HandlerCtx{
+		Ctx:     context.Background(),
+		BotID:   "0xbotId",
+		Scanner: "0xscanner",
+		PathKey: "state.json",
+		Scope:   auth.ScopeBot,
+		Logger:  log.WithField("test", true),
+		Store:   s,
+	}
+
+	s.EXPECT().PutObject(hc.Ctx, gomock.Any()).DoAndReturn(func(ctx context.Context, input *s3.PutObjectInput, _ ...func(*s3.Options)) (*s3.PutObjectOutput, error) {
+		assert.Equal(t, "application/json", *input.ContentType)
+		assert.Equal(t, map[string]string{"schema": "2", "writer": "1.4.0"}, input.Metadata)
+		return &s3.PutObjectOutput{}, nil
+	})
+	resp, err := putObj(hc, events.APIGatewayV2HTTPRequest{
+		Body: "{}",
+		Headers: map[string]string{
+			"content-type":  "application/json",
+			"x-meta-schema": "2",
+			"x-meta-writer": "1.4.0",
+		},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+	resp, err = putObj(hc, events.APIGatewayV2HTTPRequest{
+		Body:    "{}",
+		Headers: map[string]string{"x-meta-big": strings.Repeat("x", maxMetaSize)},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+}
+
+func TestPutObjConditional(t *testing.T) {
+	bucket = "test-bucket"
+	ctrl := gomock.NewController(t)
+	s := m.NewMockS3(ctrl)
+
+	hc := &auth.HandlerCtx{
+		Ctx:     context.Background(),
+		BotID:   "0xbotId",
+		Scanner: "0xscanner",
+		PathKey: "state.json",
+		Scope:   auth.ScopeBot,
+		Logger:  log.WithField("test", true),
+		Store:   s,
+	}
+
+	preconditionFailed := &awshttp.ResponseError{
+		ResponseError: &smithyhttp.ResponseError{
+			Response: &smithyhttp.Response{Response: &http.Response{StatusCode: http.StatusPreconditionFailed}},
+		},
+	}
+	s.EXPECT().PutObject(hc.Ctx, gomock.Any(), gomock.Any()).DoAndReturn(func(ctx context.Context, input *s3.PutObjectInput, optFns ...func(*s3.Options)) (*s3.PutObjectOutput, error) {
+		// the condition is added to the request
+		assert.Len(t, optFns, 1)
+		var o s3.Options
+		optFns[0](&o)
+		assert.Len(t, o.APIOptions, 1)
+		return nil, preconditionFailed
+	})
+	resp, err := putObj(hc, events.APIGatewayV2HTTPRequest{
+		Body:    "{}",
+		Headers: map[string]string{"if-match": `"abc"`},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusPreconditionFailed, resp.StatusCode)
+
+	// only * is meaningful for writes
+	resp, err = putObj(hc, events.APIGatewayV2HTTPRequest{
+		Body:    "{}",
+		Headers: map[string]string{"if-none-match": `"abc"`},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+}
+
+func TestPutObjChecksum(t *testing.T) {
+	bucket = "test-bucket"
+	ctrl := gomock.NewController(t)
+	s := m.NewMockS3(ctrl)
+
+	hc := &auth.HandlerCtx{
+		Ctx:     context.Background(),
+		BotID:   "0xbotId",
+		Scanner: "0xscanner",
+		PathKey: "state.json",
+		Scope:   auth.ScopeBot,
+		Logger:  log.WithField("test", true),
+		Store:   s,
+	}
+
+	body := "payload"
+	sum := sha256.Sum256([]byte(body))
+	sha := base64.StdEncoding.EncodeToString(sum[:])
+
+	s.EXPECT().PutObject(hc.Ctx, gomock.Any()).DoAndReturn(func(ctx context.Context, input *s3.PutObjectInput, _ ...func(*s3.Options)) (*s3.PutObjectOutput, error) {
+		assert.Equal(t, sha, *input.ChecksumSHA256)
+		return &s3.PutObjectOutput{}, nil
+	})
+	resp, err := putObj(hc, events.APIGatewayV2HTTPRequest{
+		Body:    body,
+		Headers: map[string]string{"x-checksum-sha256": sha},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+	// truncated bodies are rejected before they reach s3
+	resp, err = putObj(hc, events.APIGatewayV2HTTPRequest{
+		Body:    body[:3],
+		Headers: map[string]string{"x-checksum-sha256": sha},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+
+	resp, err = putObj(hc, events.APIGatewayV2HTTPRequest{
+		Body:    body,
+		Headers: map[string]string{"content-md5": "AAAAAAAAAAAAAAAAAAAAAA=="},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+}
+
+func TestGetObjRange(t *testing.T) {
+	bucket = "test-bucket"
+	ctrl := gomock.NewController(t)
+	s := m.NewMockS3(ctrl)
+
+	hc := &auth.HandlerCtx{
+		Ctx:     context.Background(),
+		BotID:   "0xbotId",
+		Scanner: "0xscanner",
+		PathKey: "index.bin",
+		Scope:   auth.ScopeBot,
+		Logger:  log.WithField("test", true),
+		Store:   s,
+	}
+
+	contentRange := "bytes 4-7/1024"
+	s.EXPECT().GetObject(hc.Ctx, gomock.Any()).DoAndReturn(func(ctx context.Context, input *s3.GetObjectInput, _ ...func(*s3.Options)) (*s3.GetObjectOutput, error) {
+		assert.Equal(t, "bytes=4-7", *input.Range)
+		return &s3.GetObjectOutput{
+			Body:          io.NopCloser(strings.NewReader("4567")),
+			ContentLength: 4,
+			ContentRange:  &contentRange,
+		}, nil
+	})
+
+	resp, err := getObj(hc, events.APIGatewayV2HTTPRequest{
+		Headers: map[string]string{"range": "bytes=4-7"},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusPartialContent, resp.StatusCode)
+	assert.Equal(t, contentRange, resp.Headers["Content-Range"])
+	assert.Equal(t, base64.StdEncoding.EncodeToString([]byte("4567")), resp.Body)
+}
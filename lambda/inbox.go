@@ -0,0 +1,255 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+
+	"forta-bot-db/api"
+	"forta-bot-db/auth"
+)
+
+// inboxPath sends to the bot named by {key} (POST) or acknowledges message {key} (DELETE)
+const inboxPath = "/inbox/{key}"
+const inboxListPath = "/inbox"
+
+// inboxPartitionPrefix keeps messages apart from other items; each bot's inbox is one partition
+const inboxPartitionPrefix = "inbox#"
+
+// inboxRetention is how long unacknowledged messages are kept before DynamoDB expires them
+const inboxRetention = 7 * 24 * time.Hour
+
+// senderLimit bounds how fast one bot can send to another, so a single sender cannot use up an inbox's limit and
+// lock the other senders out
+var senderLimit = bucketLimit{rate: 1, burst: 100}
+
+// inboxLimit bounds how fast messages can be sent to one bot by all senders together, so an inbox cannot be flooded
+var inboxLimit = bucketLimit{rate: 10, burst: 1000}
+
+var botIDPattern = regexp.MustCompile(`^0x[0-9a-f]+$`)
+
+type inboxMessage struct {
+	PK      string `dynamodbav:"pk"`
+	SK      string `dynamodbav:"sk"`
+	From    string `dynamodbav:"from"`
+	Scanner string `dynamodbav:"scanner"`
+	Body    []byte `dynamodbav:"body"`
+	SentAt  int64  `dynamodbav:"sentAt"`
+	// TTL is in seconds, as DynamoDB's time to live expects
+	TTL int64 `dynamodbav:"ttl"`
+}
+
+type sendResponse struct {
+	ID string `json:"id"`
+}
+
+type messageResponse struct {
+	ID      string `json:"id"`
+	From    string `json:"from"`
+	Scanner string `json:"scanner"`
+	Body    []byte `json:"body"`
+	SentAt  int64  `json:"sentAt"`
+}
+
+type inboxResponse struct {
+	Messages []messageResponse `json:"messages"`
+	Next     string            `json:"next,omitempty"`
+}
+
+func inboxPartition(botID string) string {
+	return inboxPartitionPrefix + strings.ToLower(botID)
+}
+
+// inboxBucketKey addresses a token bucket that limits messages to a bot: "messages" for all senders together, or
+// "from#{botId}" for one sender
+func inboxBucketKey(botID, bucket string) map[string]types.AttributeValue {
+	return map[string]types.AttributeValue{
+		"pk": &types.AttributeValueMemberS{Value: rateLimitPartitionPrefix + inboxPartition(botID)},
+		"sk": &types.AttributeValueMemberS{Value: bucket},
+	}
+}
+
+// takeInboxTokens takes from the sender's bucket for the target and then from the target's total, returning how
+// long to wait if either is empty.  A sender that is over its own limit does not touch the total.
+func takeInboxTokens(hc *auth.HandlerCtx, target string) (time.Duration, error) {
+	for _, b := range []struct {
+		key   map[string]types.AttributeValue
+		limit bucketLimit
+	}{
+		{inboxBucketKey(target, "from#"+strings.ToLower(hc.BotID)), senderLimit},
+		{inboxBucketKey(target, "messages"), inboxLimit},
+	} {
+		_, wait, err := takeTokens(hc, b.key, 1, b.limit)
+		if err != nil || wait > 0 {
+			return wait, err
+		}
+	}
+	return 0, nil
+}
+
+// messageID orders messages by the time they were sent; the random suffix keeps ids sent in the same millisecond apart
+func messageID(t time.Time) (string, error) {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%013d%s", t.UnixMilli(), hex.EncodeToString(b)), nil
+}
+
+// sendMessage delivers the body to the inbox of bot {key}, stamped with the sender's verified bot and scanner
+func sendMessage(hc *auth.HandlerCtx, r events.APIGatewayV2HTTPRequest) (events.APIGatewayV2HTTPResponse, error) {
+	target := strings.ToLower(hc.PathKey)
+	if !botIDPattern.MatchString(target) {
+		return api.BadRequest("inbox must be a bot id"), nil
+	}
+	b, err := requestBody(r)
+	if err != nil {
+		hc.Logger.WithError(err).Error("could not decode body")
+		return api.InternalError(), nil
+	}
+	if len(b) > maxKVValueSize {
+		return api.BadRequest(fmt.Sprintf("message must not exceed %d bytes", maxKVValueSize)), nil
+	}
+	wait, err := takeInboxTokens(hc, target)
+	if errors.Is(err, errTakeContention) {
+		return api.Conflict(err.Error()), nil
+	}
+	if err != nil {
+		hc.Logger.WithError(err).Error("could not take from the inbox limit")
+		return api.InternalError(), nil
+	}
+	if wait > 0 {
+		return api.TooManyRequests(wait), nil
+	}
+
+	t := now()
+	id, err := messageID(t)
+	if err != nil {
+		hc.Logger.WithError(err).Error("could not generate message id")
+		return api.InternalError(), nil
+	}
+	item, err := attributevalue.MarshalMap(&inboxMessage{
+		PK:      inboxPartition(target),
+		SK:      id,
+		From:    strings.ToLower(hc.BotID),
+		Scanner: strings.ToLower(hc.Scanner),
+		Body:    b,
+		SentAt:  t.UnixMilli(),
+		TTL:     t.Add(inboxRetention).Unix(),
+	})
+	if err != nil {
+		hc.Logger.WithError(err).Error("could not marshal message")
+		return api.InternalError(), nil
+	}
+	_, err = hc.DB.PutItem(hc.Ctx, &dynamodb.PutItemInput{
+		TableName:           &kvTable,
+		Item:                item,
+		ConditionExpression: aws.String("attribute_not_exists(pk)"),
+	})
+	if err != nil {
+		hc.Logger.WithError(err).Error("could not send message")
+		return api.InternalError(), nil
+	}
+	return api.OKJSON(&sendResponse{ID: id}), nil
+}
+
+// listMessages returns the caller's messages, oldest first, after ?after= (a message id).
+// Next is set while there may be more to list.
+func listMessages(hc *auth.HandlerCtx, r events.APIGatewayV2HTTPRequest) (events.APIGatewayV2HTTPResponse, error) {
+	limit := defaultReadLimit
+	if v, ok := r.QueryStringParameters["limit"]; ok {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > maxReadLimit {
+			return api.BadRequest(fmt.Sprintf("limit must be between 1 and %d", maxReadLimit)), nil
+		}
+		limit = n
+	}
+	pk := inboxPartition(hc.BotID)
+	input := &dynamodb.QueryInput{
+		TableName:              &kvTable,
+		KeyConditionExpression: aws.String("pk = :pk"),
+		// expired messages linger until DynamoDB gets to deleting them
+		FilterExpression: aws.String("#ttl > :now"),
+		ExpressionAttributeNames: map[string]string{
+			"#ttl": "ttl",
+		},
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":pk":  &types.AttributeValueMemberS{Value: pk},
+			":now": &types.AttributeValueMemberN{Value: strconv.FormatInt(now().Unix(), 10)},
+		},
+		Limit:          aws.Int32(int32(limit)),
+		ConsistentRead: aws.Bool(true),
+	}
+	if after := r.QueryStringParameters["after"]; after != "" {
+		input.ExclusiveStartKey = map[string]types.AttributeValue{
+			"pk": &types.AttributeValueMemberS{Value: pk},
+			"sk": &types.AttributeValueMemberS{Value: after},
+		}
+	}
+	out, err := hc.DB.Query(hc.Ctx, input)
+	if err != nil {
+		hc.Logger.WithError(err).Error("could not list messages")
+		return api.InternalError(), nil
+	}
+	var msgs []inboxMessage
+	if err := attributevalue.UnmarshalListOfMaps(out.Items, &msgs); err != nil {
+		hc.Logger.WithError(err).Error("could not unmarshal messages")
+		return api.InternalError(), nil
+	}
+
+	res := &inboxResponse{Messages: []messageResponse{}}
+	for _, msg := range msgs {
+		res.Messages = append(res.Messages, messageResponse{
+			ID:      msg.SK,
+			From:    msg.From,
+			Scanner: msg.Scanner,
+			Body:    msg.Body,
+			SentAt:  msg.SentAt,
+		})
+	}
+	if sk, ok := out.LastEvaluatedKey["sk"].(*types.AttributeValueMemberS); ok {
+		res.Next = sk.Value
+	}
+	return api.OKJSON(res), nil
+}
+
+// ackMessage removes message {key} from the caller's inbox
+func ackMessage(hc *auth.HandlerCtx) (events.APIGatewayV2HTTPResponse, error) {
+	_, err := hc.DB.DeleteItem(hc.Ctx, &dynamodb.DeleteItemInput{
+		TableName: &kvTable,
+		Key: map[string]types.AttributeValue{
+			"pk": &types.AttributeValueMemberS{Value: inboxPartition(hc.BotID)},
+			"sk": &types.AttributeValueMemberS{Value: hc.PathKey},
+		},
+	})
+	if err != nil {
+		hc.Logger.WithError(err).Error("could not acknowledge message")
+		return api.InternalError(), nil
+	}
+	return api.OK(), nil
+}
+
+func inboxRoute(hc *auth.HandlerCtx, r events.APIGatewayV2HTTPRequest) (events.APIGatewayV2HTTPResponse, error) {
+	switch strings.ToLower(r.RequestContext.HTTP.Method) {
+	case "get":
+		return listMessages(hc, r)
+	case "post":
+		return sendMessage(hc, r)
+	case "delete":
+		return ackMessage(hc)
+	default:
+		hc.Logger.Warn("method not allowed")
+		return api.MethodNotAllowed(), nil
+	}
+}
@@ -0,0 +1,147 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/golang/mock/gomock"
+	log "github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+
+	"forta-bot-db/auth"
+	m "forta-bot-db/store/mocks"
+)
+
+func TestSendMessage(t *testing.T) {
+	kvTable = "test-kv"
+	ctrl := gomock.NewController(t)
+	d := m.NewMockDynamoDB(ctrl)
+	sentAt := time.Date(2023, 3, 1, 12, 0, 0, 0, time.UTC)
+	now = func() time.Time { return sentAt }
+	defer func() { now = time.Now }()
+
+	hc := &auth.HandlerCtx{
+		Ctx:     context.Background(),
+		BotID:   "0xCAFE02",
+		Scanner: "0xscanner",
+		PathKey: "0xBEEF01",
+		Logger:  log.WithField("test", true),
+		DB:      d,
+	}
+
+	// every message takes from the sender's bucket for the bot and then from the bot's total, new ones start full
+	gomock.InOrder(
+		d.EXPECT().GetItem(hc.Ctx, gomock.Any()).DoAndReturn(func(ctx context.Context, input *dynamodb.GetItemInput, _ ...func(*dynamodb.Options)) (*dynamodb.GetItemOutput, error) {
+			assert.Equal(t, "ratelimit#inbox#0xbeef01", input.Key["pk"].(*types.AttributeValueMemberS).Value)
+			assert.Equal(t, "from#0xcafe02", input.Key["sk"].(*types.AttributeValueMemberS).Value)
+			return &dynamodb.GetItemOutput{}, nil
+		}),
+		d.EXPECT().UpdateItem(hc.Ctx, gomock.Any()).DoAndReturn(func(ctx context.Context, input *dynamodb.UpdateItemInput, _ ...func(*dynamodb.Options)) (*dynamodb.UpdateItemOutput, error) {
+			assert.Equal(t, "99", input.ExpressionAttributeValues[":tokens"].(*types.AttributeValueMemberN).Value)
+			return &dynamodb.UpdateItemOutput{}, nil
+		}),
+		d.EXPECT().GetItem(hc.Ctx, gomock.Any()).DoAndReturn(func(ctx context.Context, input *dynamodb.GetItemInput, _ ...func(*dynamodb.Options)) (*dynamodb.GetItemOutput, error) {
+			assert.Equal(t, "ratelimit#inbox#0xbeef01", input.Key["pk"].(*types.AttributeValueMemberS).Value)
+			assert.Equal(t, "messages", input.Key["sk"].(*types.AttributeValueMemberS).Value)
+			return &dynamodb.GetItemOutput{}, nil
+		}),
+		d.EXPECT().UpdateItem(hc.Ctx, gomock.Any()).DoAndReturn(func(ctx context.Context, input *dynamodb.UpdateItemInput, _ ...func(*dynamodb.Options)) (*dynamodb.UpdateItemOutput, error) {
+			assert.Equal(t, "999", input.ExpressionAttributeValues[":tokens"].(*types.AttributeValueMemberN).Value)
+			return &dynamodb.UpdateItemOutput{}, nil
+		}),
+	)
+	d.EXPECT().PutItem(hc.Ctx, gomock.Any()).DoAndReturn(func(ctx context.Context, input *dynamodb.PutItemInput, _ ...func(*dynamodb.Options)) (*dynamodb.PutItemOutput, error) {
+		assert.Equal(t, "inbox#0xbeef01", input.Item["pk"].(*types.AttributeValueMemberS).Value)
+		assert.True(t, strings.HasPrefix(input.Item["sk"].(*types.AttributeValueMemberS).Value, "1677672000000"))
+		assert.Equal(t, "0xcafe02", input.Item["from"].(*types.AttributeValueMemberS).Value)
+		assert.Equal(t, "0xscanner", input.Item["scanner"].(*types.AttributeValueMemberS).Value)
+		assert.Equal(t, "1678276800", input.Item["ttl"].(*types.AttributeValueMemberN).Value)
+		return &dynamodb.PutItemOutput{}, nil
+	})
+	resp, err := route(hc, apiReq("POST", inboxPath, `{"candidate":"0xabc"}`, nil))
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Contains(t, resp.Body, `"id":"1677672000000`)
+
+	// once the sender's bucket is empty, nothing is stored until it refills, and the total is left alone
+	empty := &dynamodb.GetItemOutput{Item: map[string]types.AttributeValue{
+		"tokens":    &types.AttributeValueMemberN{Value: "0"},
+		"updatedAt": &types.AttributeValueMemberN{Value: "1677672000000"},
+		"version":   &types.AttributeValueMemberN{Value: "100"},
+	}}
+	d.EXPECT().GetItem(hc.Ctx, gomock.Any()).Return(empty, nil)
+	resp, err = route(hc, apiReq("POST", inboxPath, `{"candidate":"0xabc"}`, nil))
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusTooManyRequests, resp.StatusCode)
+	assert.Equal(t, "1", resp.Headers["Retry-After"])
+
+	// other senders are only held back once the bot's total is used up
+	hc.BotID = "0xCAFE03"
+	gomock.InOrder(
+		d.EXPECT().GetItem(hc.Ctx, gomock.Any()).DoAndReturn(func(ctx context.Context, input *dynamodb.GetItemInput, _ ...func(*dynamodb.Options)) (*dynamodb.GetItemOutput, error) {
+			assert.Equal(t, "from#0xcafe03", input.Key["sk"].(*types.AttributeValueMemberS).Value)
+			return &dynamodb.GetItemOutput{}, nil
+		}),
+		d.EXPECT().UpdateItem(hc.Ctx, gomock.Any()).Return(&dynamodb.UpdateItemOutput{}, nil),
+		d.EXPECT().GetItem(hc.Ctx, gomock.Any()).Return(empty, nil),
+	)
+	resp, err = route(hc, apiReq("POST", inboxPath, `{"candidate":"0xabc"}`, nil))
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusTooManyRequests, resp.StatusCode)
+
+	hc.PathKey = "scanner"
+	resp, err = route(hc, apiReq("POST", inboxPath, "{}", nil))
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+}
+
+func TestListAndAckMessages(t *testing.T) {
+	kvTable = "test-kv"
+	ctrl := gomock.NewController(t)
+	d := m.NewMockDynamoDB(ctrl)
+
+	hc := &auth.HandlerCtx{
+		Ctx:     context.Background(),
+		BotID:   "0xBEEF01",
+		Scanner: "0xscanner",
+		Logger:  log.WithField("test", true),
+		DB:      d,
+	}
+
+	d.EXPECT().Query(hc.Ctx, gomock.Any()).DoAndReturn(func(ctx context.Context, input *dynamodb.QueryInput, _ ...func(*dynamodb.Options)) (*dynamodb.QueryOutput, error) {
+		assert.Equal(t, "inbox#0xbeef01", input.ExpressionAttributeValues[":pk"].(*types.AttributeValueMemberS).Value)
+		assert.Equal(t, "1", input.ExclusiveStartKey["sk"].(*types.AttributeValueMemberS).Value)
+		assert.Equal(t, int32(1), *input.Limit)
+		return &dynamodb.QueryOutput{
+			Items: []map[string]types.AttributeValue{{
+				"sk":      &types.AttributeValueMemberS{Value: "2"},
+				"from":    &types.AttributeValueMemberS{Value: "0xcafe02"},
+				"scanner": &types.AttributeValueMemberS{Value: "0xscanner"},
+				"body":    &types.AttributeValueMemberB{Value: []byte("hi")},
+				"sentAt":  &types.AttributeValueMemberN{Value: "1677672000000"},
+			}},
+			LastEvaluatedKey: map[string]types.AttributeValue{
+				"pk": &types.AttributeValueMemberS{Value: "inbox#0xbeef01"},
+				"sk": &types.AttributeValueMemberS{Value: "2"},
+			},
+		}, nil
+	})
+	resp, err := route(hc, apiReq("GET", inboxListPath, "", map[string]string{"after": "1", "limit": "1"}))
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"messages":[{"id":"2","from":"0xcafe02","scanner":"0xscanner","body":"aGk=","sentAt":1677672000000}],"next":"2"}`, resp.Body)
+
+	hc.PathKey = "2"
+	d.EXPECT().DeleteItem(hc.Ctx, gomock.Any()).DoAndReturn(func(ctx context.Context, input *dynamodb.DeleteItemInput, _ ...func(*dynamodb.Options)) (*dynamodb.DeleteItemOutput, error) {
+		assert.Equal(t, "inbox#0xbeef01", input.Key["pk"].(*types.AttributeValueMemberS).Value)
+		assert.Equal(t, "2", input.Key["sk"].(*types.AttributeValueMemberS).Value)
+		return &dynamodb.DeleteItemOutput{}, nil
+	})
+	resp, err = route(hc, apiReq("DELETE", inboxPath, "", nil))
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+}
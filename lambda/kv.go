@@ -0,0 +1,293 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+
+	"forta-bot-db/api"
+	"forta-bot-db/auth"
+)
+
+var kvTable = os.Getenv("kvTable")
+
+const kvPath = "/kv/{scope}/{key}"
+const kvIncrPath = kvPath + "/incr"
+const kvCASPath = kvPath + "/cas"
+
+// kvPartitionPrefix namespaces key-value items, so other item types can share the table
+const kvPartitionPrefix = "kv#"
+
+// maxKVValueSize keeps items small; larger values belong in the object store
+const maxKVValueSize = 64 * 1024
+
+// maxKVKeySize is DynamoDB's limit on sort keys
+const maxKVKeySize = 1024
+
+type kvItem struct {
+	PK      string `dynamodbav:"pk"`
+	SK      string `dynamodbav:"sk"`
+	Value   []byte `dynamodbav:"value"`
+	Counter *int64 `dynamodbav:"counter"`
+	Version int64  `dynamodbav:"version"`
+}
+
+// kvNames maps the placeholders of update expressions to attributes, several of which are reserved words
+var kvNames = map[string]string{
+	"#value":   "value",
+	"#counter": "counter",
+	"#version": "version",
+}
+
+// kvItemKey partitions items by the same scope prefix as objects
+func kvItemKey(hc *auth.HandlerCtx) (map[string]types.AttributeValue, error) {
+	prefix, err := hc.GetScopePrefix()
+	if err != nil {
+		return nil, err
+	}
+	return map[string]types.AttributeValue{
+		"pk": &types.AttributeValueMemberS{Value: kvPartitionPrefix + prefix},
+		"sk": &types.AttributeValueMemberS{Value: hc.PathKey},
+	}, nil
+}
+
+func versionHeader(version int64) map[string]string {
+	return map[string]string{"X-Version": strconv.FormatInt(version, 10)}
+}
+
+func kvGet(hc *auth.HandlerCtx) (events.APIGatewayV2HTTPResponse, error) {
+	key, err := kvItemKey(hc)
+	if err != nil {
+		return api.NotFound(), nil
+	}
+	out, err := hc.DB.GetItem(hc.Ctx, &dynamodb.GetItemInput{
+		TableName:      &kvTable,
+		Key:            key,
+		ConsistentRead: aws.Bool(true),
+	})
+	if err != nil {
+		hc.Logger.WithError(err).Error("error getting item")
+		return api.InternalError(), nil
+	}
+	if out.Item == nil {
+		return api.NotFound(), nil
+	}
+	var item kvItem
+	if err := attributevalue.UnmarshalMap(out.Item, &item); err != nil {
+		hc.Logger.WithError(err).Error("error reading item")
+		return api.InternalError(), nil
+	}
+	// counters read as their decimal value
+	value := item.Value
+	if item.Counter != nil {
+		value = []byte(strconv.FormatInt(*item.Counter, 10))
+	}
+	resp := api.OKBytes(value)
+	resp.Headers = versionHeader(item.Version)
+	return resp, nil
+}
+
+// kvPut stores the value, replacing a counter, and bumps the item's version, which is returned in X-Version
+func kvPut(hc *auth.HandlerCtx, r events.APIGatewayV2HTTPRequest) (events.APIGatewayV2HTTPResponse, error) {
+	b, err := requestBody(r)
+	if err != nil {
+		hc.Logger.WithError(err).Error("could not decode body")
+		return api.InternalError(), nil
+	}
+	if len(b) > maxKVValueSize {
+		return api.BadRequest(fmt.Sprintf("value must not exceed %d bytes", maxKVValueSize)), nil
+	}
+	key, err := kvItemKey(hc)
+	if err != nil {
+		return api.NotFound(), nil
+	}
+	out, err := hc.DB.UpdateItem(hc.Ctx, &dynamodb.UpdateItemInput{
+		TableName:                &kvTable,
+		Key:                      key,
+		UpdateExpression:         aws.String("SET #value = :value REMOVE #counter ADD #version :one"),
+		ExpressionAttributeNames: kvNames,
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":value": &types.AttributeValueMemberB{Value: b},
+			":one":   &types.AttributeValueMemberN{Value: "1"},
+		},
+		ReturnValues: types.ReturnValueUpdatedNew,
+	})
+	if err != nil {
+		hc.Logger.WithError(err).Error("could not write item")
+		return api.InternalError(), nil
+	}
+	var item kvItem
+	if err := attributevalue.UnmarshalMap(out.Attributes, &item); err != nil {
+		hc.Logger.WithError(err).Error("error reading item")
+		return api.InternalError(), nil
+	}
+	resp := api.OK()
+	resp.Headers = versionHeader(item.Version)
+	return resp, nil
+}
+
+func kvDel(hc *auth.HandlerCtx) (events.APIGatewayV2HTTPResponse, error) {
+	key, err := kvItemKey(hc)
+	if err != nil {
+		return api.NotFound(), nil
+	}
+	_, err = hc.DB.DeleteItem(hc.Ctx, &dynamodb.DeleteItemInput{
+		TableName: &kvTable,
+		Key:       key,
+	})
+	if err != nil {
+		hc.Logger.WithError(err).Error("could not delete item")
+		return api.InternalError(), nil
+	}
+	return api.OK(), nil
+}
+
+func isConditionFailed(err error) bool {
+	var ccf *types.ConditionalCheckFailedException
+	return errors.As(err, &ccf)
+}
+
+type counterResponse struct {
+	Value int64 `json:"value"`
+}
+
+// kvIncr atomically adds ?by= (default 1, may be negative) to a counter, creating it at 0
+func kvIncr(hc *auth.HandlerCtx, r events.APIGatewayV2HTTPRequest) (events.APIGatewayV2HTTPResponse, error) {
+	by := "1"
+	if v, ok := r.QueryStringParameters["by"]; ok {
+		if _, err := strconv.ParseInt(v, 10, 64); err != nil {
+			return api.BadRequest("by must be an integer"), nil
+		}
+		by = v
+	}
+	key, err := kvItemKey(hc)
+	if err != nil {
+		return api.NotFound(), nil
+	}
+	out, err := hc.DB.UpdateItem(hc.Ctx, &dynamodb.UpdateItemInput{
+		TableName:                &kvTable,
+		Key:                      key,
+		UpdateExpression:         aws.String("ADD #counter :by, #version :one"),
+		ConditionExpression:      aws.String("attribute_not_exists(#value)"),
+		ExpressionAttributeNames: kvNames,
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":by":  &types.AttributeValueMemberN{Value: by},
+			":one": &types.AttributeValueMemberN{Value: "1"},
+		},
+		ReturnValues: types.ReturnValueUpdatedNew,
+	})
+	if isConditionFailed(err) {
+		return api.Conflict("item holds a value, not a counter"), nil
+	}
+	if err != nil {
+		hc.Logger.WithError(err).Error("could not increment item")
+		return api.InternalError(), nil
+	}
+	var item kvItem
+	if err := attributevalue.UnmarshalMap(out.Attributes, &item); err != nil || item.Counter == nil {
+		hc.Logger.WithError(err).Error("error reading item")
+		return api.InternalError(), nil
+	}
+	resp := api.OKJSON(&counterResponse{Value: *item.Counter})
+	resp.Headers = versionHeader(item.Version)
+	return resp, nil
+}
+
+// casRequest swaps Old for New; a missing Old only succeeds if the item does not exist
+type casRequest struct {
+	Old *[]byte `json:"old"`
+	New []byte  `json:"new"`
+}
+
+// kvCAS writes the new value only if the item still holds the old one, returning 412 otherwise
+func kvCAS(hc *auth.HandlerCtx, r events.APIGatewayV2HTTPRequest) (events.APIGatewayV2HTTPResponse, error) {
+	b, err := requestBody(r)
+	if err != nil {
+		return api.BadRequest("could not decode body"), nil
+	}
+	var req casRequest
+	if err := json.Unmarshal(b, &req); err != nil {
+		return api.BadRequest("invalid compare-and-swap request"), nil
+	}
+	if len(req.New) > maxKVValueSize {
+		return api.BadRequest(fmt.Sprintf("value must not exceed %d bytes", maxKVValueSize)), nil
+	}
+	key, err := kvItemKey(hc)
+	if err != nil {
+		return api.NotFound(), nil
+	}
+
+	values := map[string]types.AttributeValue{
+		":value": &types.AttributeValueMemberB{Value: req.New},
+		":one":   &types.AttributeValueMemberN{Value: "1"},
+	}
+	names := map[string]string{}
+	for k, v := range kvNames {
+		names[k] = v
+	}
+	// expressions must use every name and value they are given
+	var condition string
+	if req.Old != nil {
+		condition = "#value = :old"
+		values[":old"] = &types.AttributeValueMemberB{Value: *req.Old}
+	} else {
+		condition = "attribute_not_exists(#pk)"
+		names["#pk"] = "pk"
+	}
+	out, err := hc.DB.UpdateItem(hc.Ctx, &dynamodb.UpdateItemInput{
+		TableName:                 &kvTable,
+		Key:                       key,
+		UpdateExpression:          aws.String("SET #value = :value REMOVE #counter ADD #version :one"),
+		ConditionExpression:       &condition,
+		ExpressionAttributeNames:  names,
+		ExpressionAttributeValues: values,
+		ReturnValues:              types.ReturnValueUpdatedNew,
+	})
+	if isConditionFailed(err) {
+		return api.PreconditionFailed(), nil
+	}
+	if err != nil {
+		hc.Logger.WithError(err).Error("could not swap item")
+		return api.InternalError(), nil
+	}
+	var item kvItem
+	if err := attributevalue.UnmarshalMap(out.Attributes, &item); err != nil {
+		hc.Logger.WithError(err).Error("error reading item")
+		return api.InternalError(), nil
+	}
+	resp := api.OK()
+	resp.Headers = versionHeader(item.Version)
+	return resp, nil
+}
+
+func kvRoute(hc *auth.HandlerCtx, r events.APIGatewayV2HTTPRequest) (events.APIGatewayV2HTTPResponse, error) {
+	if len(hc.PathKey) > maxKVKeySize {
+		return api.BadRequest(fmt.Sprintf("key must not exceed %d bytes", maxKVKeySize)), nil
+	}
+	switch routePath(r) {
+	case kvIncrPath:
+		return kvIncr(hc, r)
+	case kvCASPath:
+		return kvCAS(hc, r)
+	}
+	switch strings.ToLower(r.RequestContext.HTTP.Method) {
+	case "get":
+		return kvGet(hc)
+	case "put":
+		return kvPut(hc, r)
+	case "delete":
+		return kvDel(hc)
+	default:
+		hc.Logger.Warn("method not allowed")
+		return api.MethodNotAllowed(), nil
+	}
+}
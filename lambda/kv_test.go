@@ -0,0 +1,169 @@
+package main
+
+import (
+	"context"
+	"encoding/base64"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/golang/mock/gomock"
+	log "github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+
+	"forta-bot-db/auth"
+	m "forta-bot-db/store/mocks"
+)
+
+func TestKV(t *testing.T) {
+	kvTable = "test-kv"
+	ctrl := gomock.NewController(t)
+	d := m.NewMockDynamoDB(ctrl)
+
+	hc := &auth.HandlerCtx{
+		Ctx:     context.Background(),
+		BotID:   "0xbotId",
+		Scanner: "0xscanner",
+		PathKey: "checkpoint",
+		Scope:   auth.ScopeScanner,
+		Logger:  log.WithField("test", true),
+		DB:      d,
+	}
+
+	expectKey := func(key map[string]types.AttributeValue) {
+		assert.Equal(t, "kv#0xbotId/0xscanner/", key["pk"].(*types.AttributeValueMemberS).Value)
+		assert.Equal(t, "checkpoint", key["sk"].(*types.AttributeValueMemberS).Value)
+	}
+
+	d.EXPECT().UpdateItem(hc.Ctx, gomock.Any()).DoAndReturn(func(ctx context.Context, input *dynamodb.UpdateItemInput, _ ...func(*dynamodb.Options)) (*dynamodb.UpdateItemOutput, error) {
+		assert.Equal(t, "test-kv", *input.TableName)
+		expectKey(input.Key)
+		assert.Equal(t, []byte("12345"), input.ExpressionAttributeValues[":value"].(*types.AttributeValueMemberB).Value)
+		return &dynamodb.UpdateItemOutput{Attributes: map[string]types.AttributeValue{
+			"version": &types.AttributeValueMemberN{Value: "3"},
+		}}, nil
+	})
+	resp, err := route(hc, apiReq("PUT", kvPath, "12345", nil))
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "3", resp.Headers["X-Version"])
+
+	d.EXPECT().GetItem(hc.Ctx, gomock.Any()).DoAndReturn(func(ctx context.Context, input *dynamodb.GetItemInput, _ ...func(*dynamodb.Options)) (*dynamodb.GetItemOutput, error) {
+		expectKey(input.Key)
+		assert.True(t, *input.ConsistentRead)
+		return &dynamodb.GetItemOutput{Item: map[string]types.AttributeValue{
+			"value":   &types.AttributeValueMemberB{Value: []byte("12345")},
+			"version": &types.AttributeValueMemberN{Value: "3"},
+		}}, nil
+	})
+	resp, err = route(hc, apiReq("GET", kvPath, "", nil))
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, base64.StdEncoding.EncodeToString([]byte("12345")), resp.Body)
+	assert.Equal(t, "3", resp.Headers["X-Version"])
+
+	d.EXPECT().GetItem(hc.Ctx, gomock.Any()).Return(&dynamodb.GetItemOutput{}, nil)
+	resp, err = route(hc, apiReq("GET", kvPath, "", nil))
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+
+	d.EXPECT().DeleteItem(hc.Ctx, gomock.Any()).DoAndReturn(func(ctx context.Context, input *dynamodb.DeleteItemInput, _ ...func(*dynamodb.Options)) (*dynamodb.DeleteItemOutput, error) {
+		expectKey(input.Key)
+		return &dynamodb.DeleteItemOutput{}, nil
+	})
+	resp, err = route(hc, apiReq("DELETE", kvPath, "", nil))
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+	resp, err = route(hc, apiReq("PUT", kvPath, strings.Repeat("x", maxKVValueSize+1), nil))
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+}
+
+func TestKVIncr(t *testing.T) {
+	kvTable = "test-kv"
+	ctrl := gomock.NewController(t)
+	d := m.NewMockDynamoDB(ctrl)
+
+	hc := &auth.HandlerCtx{
+		Ctx:     context.Background(),
+		BotID:   "0xbotId",
+		Scanner: "0xscanner",
+		PathKey: "alerts-0xdeadbeef",
+		Scope:   auth.ScopeBot,
+		Logger:  log.WithField("test", true),
+		DB:      d,
+	}
+
+	d.EXPECT().UpdateItem(hc.Ctx, gomock.Any()).DoAndReturn(func(ctx context.Context, input *dynamodb.UpdateItemInput, _ ...func(*dynamodb.Options)) (*dynamodb.UpdateItemOutput, error) {
+		assert.Equal(t, "-2", input.ExpressionAttributeValues[":by"].(*types.AttributeValueMemberN).Value)
+		assert.Equal(t, "attribute_not_exists(#value)", *input.ConditionExpression)
+		return &dynamodb.UpdateItemOutput{Attributes: map[string]types.AttributeValue{
+			"counter": &types.AttributeValueMemberN{Value: "8"},
+			"version": &types.AttributeValueMemberN{Value: "10"},
+		}}, nil
+	})
+	req := apiReq("POST", kvPath, "", nil)
+	req.RouteKey = "POST " + kvIncrPath
+	req.QueryStringParameters = map[string]string{"by": "-2"}
+	resp, err := route(hc, req)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.JSONEq(t, `{"value":8}`, resp.Body)
+
+	d.EXPECT().UpdateItem(hc.Ctx, gomock.Any()).Return(nil, &types.ConditionalCheckFailedException{})
+	req.QueryStringParameters = nil
+	resp, err = route(hc, req)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusConflict, resp.StatusCode)
+
+	req.QueryStringParameters = map[string]string{"by": "one"}
+	resp, err = route(hc, req)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+}
+
+func TestKVCAS(t *testing.T) {
+	kvTable = "test-kv"
+	ctrl := gomock.NewController(t)
+	d := m.NewMockDynamoDB(ctrl)
+
+	hc := &auth.HandlerCtx{
+		Ctx:     context.Background(),
+		BotID:   "0xbotId",
+		Scanner: "0xscanner",
+		PathKey: "leader",
+		Scope:   auth.ScopeBot,
+		Logger:  log.WithField("test", true),
+		DB:      d,
+	}
+	req := apiReq("POST", kvPath, `{"old":"YQ==","new":"Yg=="}`, nil)
+	req.RouteKey = "POST " + kvCASPath
+
+	d.EXPECT().UpdateItem(hc.Ctx, gomock.Any()).DoAndReturn(func(ctx context.Context, input *dynamodb.UpdateItemInput, _ ...func(*dynamodb.Options)) (*dynamodb.UpdateItemOutput, error) {
+		assert.Equal(t, "#value = :old", *input.ConditionExpression)
+		assert.Equal(t, []byte("a"), input.ExpressionAttributeValues[":old"].(*types.AttributeValueMemberB).Value)
+		assert.Equal(t, []byte("b"), input.ExpressionAttributeValues[":value"].(*types.AttributeValueMemberB).Value)
+		assert.NotContains(t, input.ExpressionAttributeNames, "#pk")
+		return &dynamodb.UpdateItemOutput{Attributes: map[string]types.AttributeValue{
+			"version": &types.AttributeValueMemberN{Value: "2"},
+		}}, nil
+	})
+	resp, err := route(hc, req)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "2", resp.Headers["X-Version"])
+
+	// without an old value the item must not exist yet
+	d.EXPECT().UpdateItem(hc.Ctx, gomock.Any()).DoAndReturn(func(ctx context.Context, input *dynamodb.UpdateItemInput, _ ...func(*dynamodb.Options)) (*dynamodb.UpdateItemOutput, error) {
+		assert.Equal(t, "attribute_not_exists(#pk)", *input.ConditionExpression)
+		assert.NotContains(t, input.ExpressionAttributeValues, ":old")
+		return nil, &types.ConditionalCheckFailedException{}
+	})
+	req.Body = `{"new":"Yg=="}`
+	resp, err = route(hc, req)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusPreconditionFailed, resp.StatusCode)
+}
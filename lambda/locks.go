@@ -0,0 +1,229 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+
+	"forta-bot-db/api"
+	"forta-bot-db/auth"
+)
+
+const lockPath = "/locks/{scope}/{key}"
+const lockRenewPath = lockPath + "/renew"
+
+// lockPartitionPrefix keeps locks apart from key-value items in the same table
+const lockPartitionPrefix = "lock#"
+
+const defaultLeaseTTL = 30 * time.Second
+const maxLeaseTTL = time.Hour
+
+// now is replaced in tests
+var now = time.Now
+
+// lockItem is never deleted: releasing only expires the lease, so fencing tokens keep increasing
+type lockItem struct {
+	Holder         string `dynamodbav:"holder"`
+	Token          int64  `dynamodbav:"token"`
+	LeaseExpiresAt int64  `dynamodbav:"leaseExpiresAt"`
+}
+
+type leaseResponse struct {
+	Holder    string `json:"holder"`
+	Token     int64  `json:"token"`
+	ExpiresAt int64  `json:"expiresAt"`
+}
+
+var lockNames = map[string]string{
+	"#holder":  "holder",
+	"#token":   "token",
+	"#expires": "leaseExpiresAt",
+}
+
+func lockItemKey(hc *auth.HandlerCtx) (map[string]types.AttributeValue, error) {
+	prefix, err := hc.GetScopePrefix()
+	if err != nil {
+		return nil, err
+	}
+	return map[string]types.AttributeValue{
+		"pk": &types.AttributeValueMemberS{Value: lockPartitionPrefix + prefix},
+		"sk": &types.AttributeValueMemberS{Value: hc.PathKey},
+	}, nil
+}
+
+func leaseTTL(r events.APIGatewayV2HTTPRequest) (time.Duration, error) {
+	v, ok := r.QueryStringParameters["ttl"]
+	if !ok {
+		return defaultLeaseTTL, nil
+	}
+	secs, err := strconv.Atoi(v)
+	if err != nil || secs < 1 || time.Duration(secs)*time.Second > maxLeaseTTL {
+		return 0, fmt.Errorf("ttl must be between 1 and %d seconds", int(maxLeaseTTL.Seconds()))
+	}
+	return time.Duration(secs) * time.Second, nil
+}
+
+func leaseToken(r events.APIGatewayV2HTTPRequest) (string, error) {
+	v := r.QueryStringParameters["token"]
+	if _, err := strconv.ParseInt(v, 10, 64); err != nil {
+		return "", fmt.Errorf("token must be an integer")
+	}
+	return v, nil
+}
+
+func ms(t time.Time) string {
+	return strconv.FormatInt(t.UnixMilli(), 10)
+}
+
+// updateLock applies a conditional update on the lock, returning 409 with conflict when the condition does not hold
+func updateLock(hc *auth.HandlerCtx, update, condition string, values map[string]types.AttributeValue, conflict string) (events.APIGatewayV2HTTPResponse, error) {
+	key, err := lockItemKey(hc)
+	if err != nil {
+		return api.NotFound(), nil
+	}
+	// expressions must use every name they are given
+	names := map[string]string{}
+	for k, v := range lockNames {
+		if strings.Contains(update, k) || strings.Contains(condition, k) {
+			names[k] = v
+		}
+	}
+	if strings.Contains(condition, "#pk") {
+		names["#pk"] = "pk"
+	}
+	out, err := hc.DB.UpdateItem(hc.Ctx, &dynamodb.UpdateItemInput{
+		TableName:                 &kvTable,
+		Key:                       key,
+		UpdateExpression:          &update,
+		ConditionExpression:       &condition,
+		ExpressionAttributeNames:  names,
+		ExpressionAttributeValues: values,
+		ReturnValues:              types.ReturnValueAllNew,
+	})
+	if isConditionFailed(err) {
+		return api.Conflict(conflict), nil
+	}
+	if err != nil {
+		hc.Logger.WithError(err).Error("could not update lock")
+		return api.InternalError(), nil
+	}
+	var item lockItem
+	if err := attributevalue.UnmarshalMap(out.Attributes, &item); err != nil {
+		hc.Logger.WithError(err).Error("error reading lock")
+		return api.InternalError(), nil
+	}
+	return api.OKJSON(&leaseResponse{Holder: item.Holder, Token: item.Token, ExpiresAt: item.LeaseExpiresAt}), nil
+}
+
+// acquireLock takes the lock when it is free, expired or already held by this scanner.
+// Every acquisition gets a new, higher fencing token.
+func acquireLock(hc *auth.HandlerCtx, r events.APIGatewayV2HTTPRequest) (events.APIGatewayV2HTTPResponse, error) {
+	ttl, err := leaseTTL(r)
+	if err != nil {
+		return api.BadRequest(err.Error()), nil
+	}
+	t := now()
+	return updateLock(hc,
+		"SET #holder = :me, #expires = :expires ADD #token :one",
+		"attribute_not_exists(#pk) OR #expires <= :now OR #holder = :me",
+		map[string]types.AttributeValue{
+			":me":      &types.AttributeValueMemberS{Value: hc.Scanner},
+			":expires": &types.AttributeValueMemberN{Value: ms(t.Add(ttl))},
+			":now":     &types.AttributeValueMemberN{Value: ms(t)},
+			":one":     &types.AttributeValueMemberN{Value: "1"},
+		}, "lock is held by another scanner")
+}
+
+// renewLock extends an unexpired lease, as long as it has not been taken over since
+func renewLock(hc *auth.HandlerCtx, r events.APIGatewayV2HTTPRequest) (events.APIGatewayV2HTTPResponse, error) {
+	ttl, err := leaseTTL(r)
+	if err != nil {
+		return api.BadRequest(err.Error()), nil
+	}
+	token, err := leaseToken(r)
+	if err != nil {
+		return api.BadRequest(err.Error()), nil
+	}
+	t := now()
+	return updateLock(hc,
+		"SET #expires = :expires",
+		"#holder = :me AND #token = :token AND #expires > :now",
+		map[string]types.AttributeValue{
+			":me":      &types.AttributeValueMemberS{Value: hc.Scanner},
+			":token":   &types.AttributeValueMemberN{Value: token},
+			":expires": &types.AttributeValueMemberN{Value: ms(t.Add(ttl))},
+			":now":     &types.AttributeValueMemberN{Value: ms(t)},
+		}, "lease is no longer held")
+}
+
+// releaseLock expires the lease so another scanner can take it right away
+func releaseLock(hc *auth.HandlerCtx, r events.APIGatewayV2HTTPRequest) (events.APIGatewayV2HTTPResponse, error) {
+	token, err := leaseToken(r)
+	if err != nil {
+		return api.BadRequest(err.Error()), nil
+	}
+	return updateLock(hc,
+		"SET #expires = :zero",
+		"#holder = :me AND #token = :token",
+		map[string]types.AttributeValue{
+			":me":    &types.AttributeValueMemberS{Value: hc.Scanner},
+			":token": &types.AttributeValueMemberN{Value: token},
+			":zero":  &types.AttributeValueMemberN{Value: "0"},
+		}, "lease is no longer held")
+}
+
+// getLock returns the current lease, or 404 if the lock is free
+func getLock(hc *auth.HandlerCtx) (events.APIGatewayV2HTTPResponse, error) {
+	key, err := lockItemKey(hc)
+	if err != nil {
+		return api.NotFound(), nil
+	}
+	out, err := hc.DB.GetItem(hc.Ctx, &dynamodb.GetItemInput{
+		TableName:      &kvTable,
+		Key:            key,
+		ConsistentRead: aws.Bool(true),
+	})
+	if err != nil {
+		hc.Logger.WithError(err).Error("error getting lock")
+		return api.InternalError(), nil
+	}
+	if out.Item == nil {
+		return api.NotFound(), nil
+	}
+	var item lockItem
+	if err := attributevalue.UnmarshalMap(out.Item, &item); err != nil {
+		hc.Logger.WithError(err).Error("error reading lock")
+		return api.InternalError(), nil
+	}
+	if item.LeaseExpiresAt <= now().UnixMilli() {
+		return api.NotFound(), nil
+	}
+	return api.OKJSON(&leaseResponse{Holder: item.Holder, Token: item.Token, ExpiresAt: item.LeaseExpiresAt}), nil
+}
+
+func lockRoute(hc *auth.HandlerCtx, r events.APIGatewayV2HTTPRequest) (events.APIGatewayV2HTTPResponse, error) {
+	if len(hc.PathKey) > maxKVKeySize {
+		return api.BadRequest(fmt.Sprintf("key must not exceed %d bytes", maxKVKeySize)), nil
+	}
+	if routePath(r) == lockRenewPath {
+		return renewLock(hc, r)
+	}
+	switch strings.ToLower(r.RequestContext.HTTP.Method) {
+	case "get":
+		return getLock(hc)
+	case "post":
+		return acquireLock(hc, r)
+	case "delete":
+		return releaseLock(hc, r)
+	default:
+		hc.Logger.Warn("method not allowed")
+		return api.MethodNotAllowed(), nil
+	}
+}
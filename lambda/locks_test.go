@@ -0,0 +1,134 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/golang/mock/gomock"
+	log "github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+
+	"forta-bot-db/auth"
+	m "forta-bot-db/store/mocks"
+)
+
+func TestLocks(t *testing.T) {
+	kvTable = "test-kv"
+	t0 := time.UnixMilli(1_700_000_000_000)
+	now = func() time.Time { return t0 }
+	defer func() { now = time.Now }()
+
+	ctrl := gomock.NewController(t)
+	d := m.NewMockDynamoDB(ctrl)
+	hc := &auth.HandlerCtx{
+		Ctx:     context.Background(),
+		BotID:   "0xbotId",
+		Scanner: "0xscanner",
+		PathKey: "refresh-labels",
+		Scope:   auth.ScopeBot,
+		Logger:  log.WithField("test", true),
+		DB:      d,
+	}
+	held := func(token, expiresAt string) *dynamodb.UpdateItemOutput {
+		return &dynamodb.UpdateItemOutput{Attributes: map[string]types.AttributeValue{
+			"holder":         &types.AttributeValueMemberS{Value: "0xscanner"},
+			"token":          &types.AttributeValueMemberN{Value: token},
+			"leaseExpiresAt": &types.AttributeValueMemberN{Value: expiresAt},
+		}}
+	}
+
+	// acquire
+	d.EXPECT().UpdateItem(hc.Ctx, gomock.Any()).DoAndReturn(func(ctx context.Context, input *dynamodb.UpdateItemInput, _ ...func(*dynamodb.Options)) (*dynamodb.UpdateItemOutput, error) {
+		assert.Equal(t, "lock#0xbotId/", input.Key["pk"].(*types.AttributeValueMemberS).Value)
+		assert.Equal(t, "refresh-labels", input.Key["sk"].(*types.AttributeValueMemberS).Value)
+		assert.Equal(t, "0xscanner", input.ExpressionAttributeValues[":me"].(*types.AttributeValueMemberS).Value)
+		assert.Equal(t, "1700000060000", input.ExpressionAttributeValues[":expires"].(*types.AttributeValueMemberN).Value)
+		assert.Contains(t, *input.ConditionExpression, "attribute_not_exists(#pk)")
+		assert.Equal(t, "pk", input.ExpressionAttributeNames["#pk"])
+		return held("7", "1700000060000"), nil
+	})
+	resp, err := route(hc, apiReq("POST", lockPath, "", map[string]string{"ttl": "60"}))
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	var lease leaseResponse
+	assert.NoError(t, json.Unmarshal([]byte(resp.Body), &lease))
+	assert.Equal(t, leaseResponse{Holder: "0xscanner", Token: 7, ExpiresAt: 1700000060000}, lease)
+
+	// held elsewhere
+	d.EXPECT().UpdateItem(hc.Ctx, gomock.Any()).Return(nil, &types.ConditionalCheckFailedException{})
+	resp, err = route(hc, apiReq("POST", lockPath, "", nil))
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusConflict, resp.StatusCode)
+
+	// renew
+	d.EXPECT().UpdateItem(hc.Ctx, gomock.Any()).DoAndReturn(func(ctx context.Context, input *dynamodb.UpdateItemInput, _ ...func(*dynamodb.Options)) (*dynamodb.UpdateItemOutput, error) {
+		assert.Equal(t, "7", input.ExpressionAttributeValues[":token"].(*types.AttributeValueMemberN).Value)
+		assert.Equal(t, "1700000030000", input.ExpressionAttributeValues[":expires"].(*types.AttributeValueMemberN).Value)
+		assert.NotContains(t, input.ExpressionAttributeNames, "#pk")
+		return held("7", "1700000030000"), nil
+	})
+	resp, err = route(hc, apiReq("POST", lockRenewPath, "", map[string]string{"token": "7"}))
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+	// release
+	d.EXPECT().UpdateItem(hc.Ctx, gomock.Any()).DoAndReturn(func(ctx context.Context, input *dynamodb.UpdateItemInput, _ ...func(*dynamodb.Options)) (*dynamodb.UpdateItemOutput, error) {
+		assert.True(t, strings.HasPrefix(*input.UpdateExpression, "SET #expires = :zero"))
+		return held("7", "0"), nil
+	})
+	resp, err = route(hc, apiReq("DELETE", lockPath, "", map[string]string{"token": "7"}))
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+	resp, err = route(hc, apiReq("DELETE", lockPath, "", nil))
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+
+	resp, err = route(hc, apiReq("POST", lockPath, "", map[string]string{"ttl": "86400"}))
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+}
+
+func TestGetLock(t *testing.T) {
+	kvTable = "test-kv"
+	t0 := time.UnixMilli(1_700_000_000_000)
+	now = func() time.Time { return t0 }
+	defer func() { now = time.Now }()
+
+	ctrl := gomock.NewController(t)
+	d := m.NewMockDynamoDB(ctrl)
+	hc := &auth.HandlerCtx{
+		Ctx:     context.Background(),
+		BotID:   "0xbotId",
+		Scanner: "0xfollower",
+		PathKey: "leader",
+		Scope:   auth.ScopeBot,
+		Logger:  log.WithField("test", true),
+		DB:      d,
+	}
+	item := func(expiresAt string) *dynamodb.GetItemOutput {
+		return &dynamodb.GetItemOutput{Item: map[string]types.AttributeValue{
+			"holder":         &types.AttributeValueMemberS{Value: "0xleader"},
+			"token":          &types.AttributeValueMemberN{Value: "3"},
+			"leaseExpiresAt": &types.AttributeValueMemberN{Value: expiresAt},
+		}}
+	}
+
+	d.EXPECT().GetItem(hc.Ctx, gomock.Any()).Return(item("1700000010000"), nil)
+	resp, err := route(hc, apiReq("GET", lockPath, "", nil))
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.JSONEq(t, `{"holder":"0xleader","token":3,"expiresAt":1700000010000}`, resp.Body)
+
+	// expired and released leases are free
+	d.EXPECT().GetItem(hc.Ctx, gomock.Any()).Return(item("0"), nil)
+	resp, err = route(hc, apiReq("GET", lockPath, "", nil))
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+}
@@ -0,0 +1,38 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// metaHeaderPrefix marks custom metadata headers (lambda lowercases header names)
+const metaHeaderPrefix = "x-meta-"
+
+// limits on custom metadata; S3 allows 2 KB of user metadata per object
+const maxMetaEntries = 16
+const maxMetaKeyLength = 64
+const maxMetaSize = 2048
+
+// requestMetadata extracts custom X-Meta-* headers as S3 user metadata
+func requestMetadata(headers map[string]string) (map[string]string, error) {
+	meta := map[string]string{}
+	size := 0
+	for k, v := range headers {
+		if !strings.HasPrefix(k, metaHeaderPrefix) {
+			continue
+		}
+		name := strings.TrimPrefix(k, metaHeaderPrefix)
+		if name == "" || len(name) > maxMetaKeyLength || strings.HasPrefix(name, internalMetaPrefix) {
+			return nil, fmt.Errorf("invalid metadata key %q", name)
+		}
+		meta[name] = v
+		size += len(name) + len(v)
+	}
+	if len(meta) > maxMetaEntries {
+		return nil, fmt.Errorf("at most %d metadata entries are allowed", maxMetaEntries)
+	}
+	if size > maxMetaSize-maxInternalMetaSize {
+		return nil, fmt.Errorf("metadata must not exceed %d bytes", maxMetaSize-maxInternalMetaSize)
+	}
+	return meta, nil
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+
+	"forta-bot-db/api"
+	"forta-bot-db/auth"
+)
+
+// maxPrefixPages bounds the objects removed per request (1000 per page) to stay inside the lambda timeout.
+// Callers repeat the request while the response is truncated.
+const maxPrefixPages = 5
+
+// scannerDir matches the scanner directory that scanner scope objects are nested under in the bot's prefix
+var scannerDir = regexp.MustCompile(`(?i)^0x[0-9a-f]{40}/`)
+
+type prefixDeleteResponse struct {
+	Deleted   int  `json:"deleted"`
+	Truncated bool `json:"truncated"`
+	// Next is the last key examined, which a truncated delete continues after (?after=)
+	Next string `json:"next,omitempty"`
+}
+
+// delPrefix removes every object of the scope whose key starts with the prefix.
+// An empty prefix wipes the scope, so the request must carry confirm=true.  The bot scope never reaches into the
+// scanner directories nested in it; scanner objects are deleted through the scanner scope.  A truncated response
+// names the last key it examined, so the next request continues from there rather than listing skipped objects again.
+func delPrefix(hc *auth.HandlerCtx, r events.APIGatewayV2HTTPRequest) (events.APIGatewayV2HTTPResponse, error) {
+	if r.QueryStringParameters["confirm"] != "true" {
+		return api.BadRequest("deleting by prefix requires confirm=true"), nil
+	}
+	scopePrefix, err := hc.GetScopePrefix()
+	if err != nil {
+		return api.NotFound(), nil
+	}
+	prefix := scopePrefix + hc.Prefix
+
+	var startAfter *string
+	if after := r.QueryStringParameters["after"]; after != "" {
+		startAfter = aws.String(scopePrefix + after)
+	}
+
+	res := &prefixDeleteResponse{}
+	var token *string
+	var last string
+	for page := 0; page < maxPrefixPages; page++ {
+		list, err := hc.Store.ListObjectsV2(hc.Ctx, &s3.ListObjectsV2Input{
+			Bucket:            &bucket,
+			Prefix:            &prefix,
+			StartAfter:        startAfter,
+			ContinuationToken: token,
+		})
+		if err != nil {
+			hc.Logger.WithError(err).Error("could not list objects")
+			return api.InternalError(), nil
+		}
+
+		var objects []types.ObjectIdentifier
+		for _, obj := range list.Contents {
+			last = *obj.Key
+			if hc.Scope == auth.ScopeBot && scannerDir.MatchString(strings.TrimPrefix(*obj.Key, scopePrefix)) {
+				continue
+			}
+			objects = append(objects, types.ObjectIdentifier{Key: obj.Key})
+		}
+		if len(objects) > 0 {
+			out, err := hc.Store.DeleteObjects(hc.Ctx, &s3.DeleteObjectsInput{
+				Bucket: &bucket,
+				Delete: &types.Delete{Objects: objects, Quiet: true},
+			})
+			if err != nil {
+				hc.Logger.WithError(err).Error("could not delete objects")
+				return api.InternalError(), nil
+			}
+			if len(out.Errors) > 0 {
+				hc.Logger.WithField("errors", len(out.Errors)).Error("could not delete some objects")
+				return api.InternalError(), nil
+			}
+			res.Deleted += len(objects)
+		}
+
+		if !list.IsTruncated {
+			if res.Deleted > 0 {
+				recordChange(hc, changeDelPrefix, hc.Prefix, "")
+			}
+			return api.OKJSON(res), nil
+		}
+		token = list.NextContinuationToken
+	}
+	res.Truncated = true
+	res.Next = strings.TrimPrefix(last, scopePrefix)
+	recordChange(hc, changeDelPrefix, hc.Prefix, "")
+	return api.OKJSON(res), nil
+}
@@ -0,0 +1,165 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/golang/mock/gomock"
+	log "github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+
+	"forta-bot-db/auth"
+	m "forta-bot-db/store/mocks"
+)
+
+func TestDelPrefix(t *testing.T) {
+	bucket = "test-bucket"
+	ctrl := gomock.NewController(t)
+	s := m.NewMockS3(ctrl)
+
+	hc := &auth.HandlerCtx{
+		Ctx:     context.Background(),
+		BotID:   "0xbotId",
+		Scanner: "0xscanner",
+		Scope:   auth.ScopeScanner,
+		Prefix:  "state/",
+		Logger:  log.WithField("test", true),
+		Store:   s,
+	}
+	req := events.APIGatewayV2HTTPRequest{
+		RequestContext: events.APIGatewayV2HTTPRequestContext{
+			HTTP: events.APIGatewayV2HTTPRequestContextHTTPDescription{Method: "DELETE"},
+		},
+		QueryStringParameters: map[string]string{"prefix": "state/"},
+	}
+
+	// confirmation is required
+	resp, err := route(hc, req)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+
+	page1, page2 := "0xbotId/0xscanner/state/a", "0xbotId/0xscanner/state/b"
+	next := "next"
+	gomock.InOrder(
+		s.EXPECT().ListObjectsV2(hc.Ctx, gomock.Any()).DoAndReturn(func(ctx context.Context, input *s3.ListObjectsV2Input, _ ...func(*s3.Options)) (*s3.ListObjectsV2Output, error) {
+			assert.Equal(t, "0xbotId/0xscanner/state/", *input.Prefix)
+			assert.Nil(t, input.ContinuationToken)
+			return &s3.ListObjectsV2Output{Contents: []types.Object{{Key: &page1}}, IsTruncated: true, NextContinuationToken: &next}, nil
+		}),
+		s.EXPECT().DeleteObjects(hc.Ctx, gomock.Any()).DoAndReturn(func(ctx context.Context, input *s3.DeleteObjectsInput, _ ...func(*s3.Options)) (*s3.DeleteObjectsOutput, error) {
+			assert.Equal(t, page1, *input.Delete.Objects[0].Key)
+			return &s3.DeleteObjectsOutput{}, nil
+		}),
+		s.EXPECT().ListObjectsV2(hc.Ctx, gomock.Any()).DoAndReturn(func(ctx context.Context, input *s3.ListObjectsV2Input, _ ...func(*s3.Options)) (*s3.ListObjectsV2Output, error) {
+			assert.Equal(t, next, *input.ContinuationToken)
+			return &s3.ListObjectsV2Output{Contents: []types.Object{{Key: &page2}}}, nil
+		}),
+		s.EXPECT().DeleteObjects(hc.Ctx, gomock.Any()).Return(&s3.DeleteObjectsOutput{}, nil),
+	)
+
+	req.QueryStringParameters["confirm"] = "true"
+	resp, err = route(hc, req)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+	var res prefixDeleteResponse
+	assert.NoError(t, json.Unmarshal([]byte(resp.Body), &res))
+	assert.Equal(t, 2, res.Deleted)
+	assert.False(t, res.Truncated)
+}
+
+func TestDelPrefixKeepsScannerObjects(t *testing.T) {
+	bucket = "test-bucket"
+	ctrl := gomock.NewController(t)
+	s := m.NewMockS3(ctrl)
+
+	hc := &auth.HandlerCtx{
+		Ctx:     context.Background(),
+		BotID:   "0xbotId",
+		Scanner: "0xscanner",
+		Scope:   auth.ScopeBot,
+		Logger:  log.WithField("test", true),
+		Store:   s,
+	}
+	req := events.APIGatewayV2HTTPRequest{
+		RequestContext: events.APIGatewayV2HTTPRequestContext{
+			HTTP: events.APIGatewayV2HTTPRequestContextHTTPDescription{Method: "DELETE"},
+		},
+		QueryStringParameters: map[string]string{"confirm": "true"},
+	}
+
+	botObj := "0xbotId/labels/a"
+	scannerObj := "0xbotId/0x1c5d8ab2f4e2a1b7a1b45e1b8ff1b6b7c8d9e0fa/state.json"
+	s.EXPECT().ListObjectsV2(hc.Ctx, gomock.Any()).Return(&s3.ListObjectsV2Output{Contents: []types.Object{{Key: &botObj}, {Key: &scannerObj}}}, nil)
+	s.EXPECT().DeleteObjects(hc.Ctx, gomock.Any()).DoAndReturn(func(ctx context.Context, input *s3.DeleteObjectsInput, _ ...func(*s3.Options)) (*s3.DeleteObjectsOutput, error) {
+		assert.Len(t, input.Delete.Objects, 1)
+		assert.Equal(t, botObj, *input.Delete.Objects[0].Key)
+		return &s3.DeleteObjectsOutput{}, nil
+	})
+
+	resp, err := route(hc, req)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	var res prefixDeleteResponse
+	assert.NoError(t, json.Unmarshal([]byte(resp.Body), &res))
+	assert.Equal(t, 1, res.Deleted)
+}
+
+func TestDelPrefixContinuesAfterSkippedObjects(t *testing.T) {
+	bucket = "test-bucket"
+	ctrl := gomock.NewController(t)
+	s := m.NewMockS3(ctrl)
+
+	hc := &auth.HandlerCtx{
+		Ctx:     context.Background(),
+		BotID:   "0xbotId",
+		Scanner: "0xscanner",
+		Scope:   auth.ScopeBot,
+		Logger:  log.WithField("test", true),
+		Store:   s,
+	}
+	req := events.APIGatewayV2HTTPRequest{
+		RequestContext: events.APIGatewayV2HTTPRequestContext{
+			HTTP: events.APIGatewayV2HTTPRequestContextHTTPDescription{Method: "DELETE"},
+		},
+		QueryStringParameters: map[string]string{"confirm": "true"},
+	}
+
+	// every page holds only scanner objects, so nothing is deleted but the response says where to continue
+	scannerObj := "0xbotId/0x1c5d8ab2f4e2a1b7a1b45e1b8ff1b6b7c8d9e0fa/state.json"
+	next := "next"
+	s.EXPECT().ListObjectsV2(hc.Ctx, gomock.Any()).DoAndReturn(func(ctx context.Context, input *s3.ListObjectsV2Input, _ ...func(*s3.Options)) (*s3.ListObjectsV2Output, error) {
+		assert.Nil(t, input.StartAfter)
+		return &s3.ListObjectsV2Output{Contents: []types.Object{{Key: &scannerObj}}, IsTruncated: true, NextContinuationToken: &next}, nil
+	}).Times(maxPrefixPages)
+
+	resp, err := route(hc, req)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	var res prefixDeleteResponse
+	assert.NoError(t, json.Unmarshal([]byte(resp.Body), &res))
+	assert.Equal(t, 0, res.Deleted)
+	assert.True(t, res.Truncated)
+	assert.Equal(t, "0x1c5d8ab2f4e2a1b7a1b45e1b8ff1b6b7c8d9e0fa/state.json", res.Next)
+
+	botObj := "0xbotId/labels/a"
+	s.EXPECT().ListObjectsV2(hc.Ctx, gomock.Any()).DoAndReturn(func(ctx context.Context, input *s3.ListObjectsV2Input, _ ...func(*s3.Options)) (*s3.ListObjectsV2Output, error) {
+		assert.Equal(t, scannerObj, *input.StartAfter)
+		return &s3.ListObjectsV2Output{Contents: []types.Object{{Key: &botObj}}}, nil
+	})
+	s.EXPECT().DeleteObjects(hc.Ctx, gomock.Any()).Return(&s3.DeleteObjectsOutput{}, nil)
+
+	req.QueryStringParameters["after"] = res.Next
+	resp, err = route(hc, req)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	res = prefixDeleteResponse{}
+	assert.NoError(t, json.Unmarshal([]byte(resp.Body), &res))
+	assert.Equal(t, 1, res.Deleted)
+	assert.False(t, res.Truncated)
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"strings"
+
+	"github.com/aws/aws-lambda-go/events"
+
+	"forta-bot-db/api"
+	"forta-bot-db/auth"
+)
+
+// publicPath reads the public scope of any bot; a bot writes its own through /database/public/{key}
+const publicPath = "/database/public/{publisher}/{key}"
+
+// reservedPublicKeys cannot be written to the public scope: POST /database/public/{publisher}/copy would also match
+// the copy route of object {publisher}, so the two could not be told apart
+var reservedPublicKeys = map[string]bool{"copy": true, "move": true}
+
+// publicRoute serves another bot's public scope.  Every authenticated bot can read it, but only the publisher writes it.
+func publicRoute(hc *auth.HandlerCtx, r events.APIGatewayV2HTTPRequest) (events.APIGatewayV2HTTPResponse, error) {
+	if reservedPublicKeys[hc.PathKey] {
+		return api.BadRequest("copy and move are reserved keys in the public scope"), nil
+	}
+	switch strings.ToLower(r.RequestContext.HTTP.Method) {
+	case "get":
+		if r.QueryStringParameters["watch"] == "true" {
+			return watchObj(hc, r)
+		}
+		return getObj(hc, r)
+	case "head":
+		return statObj(hc)
+	}
+	if !strings.EqualFold(hc.Publisher, hc.BotID) {
+		hc.Logger.Warn("write to another bot's public scope")
+		return api.Forbidden(), nil
+	}
+	switch strings.ToLower(r.RequestContext.HTTP.Method) {
+	case "put", "post":
+		return putObj(hc, r)
+	case "delete":
+		return delObj(hc)
+	default:
+		hc.Logger.Warn("method not allowed")
+		return api.MethodNotAllowed(), nil
+	}
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/golang/mock/gomock"
+	log "github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+
+	"forta-bot-db/auth"
+	m "forta-bot-db/store/mocks"
+)
+
+func publicReq(method, body string) events.APIGatewayV2HTTPRequest {
+	return events.APIGatewayV2HTTPRequest{
+		RouteKey: "ANY " + publicPath,
+		RequestContext: events.APIGatewayV2HTTPRequestContext{
+			HTTP: events.APIGatewayV2HTTPRequestContextHTTPDescription{Method: method},
+		},
+		Body: body,
+	}
+}
+
+func TestPublicRoute(t *testing.T) {
+	bucket = "test-bucket"
+	ctrl := gomock.NewController(t)
+	s := m.NewMockS3(ctrl)
+
+	hc := &auth.HandlerCtx{
+		Ctx:       context.Background(),
+		BotID:     "0xbotId",
+		Scanner:   "0xscanner",
+		PathKey:   "scam-addresses.json",
+		Scope:     auth.ScopePublic,
+		Publisher: "0xpublisher",
+		Logger:    log.WithField("test", true),
+		Store:     s,
+	}
+
+	s.EXPECT().GetObject(hc.Ctx, gomock.Any()).DoAndReturn(func(ctx context.Context, input *s3.GetObjectInput, _ ...func(*s3.Options)) (*s3.GetObjectOutput, error) {
+		assert.Equal(t, "public/0xpublisher/scam-addresses.json", *input.Key)
+		return &s3.GetObjectOutput{Body: io.NopCloser(strings.NewReader("[]"))}, nil
+	})
+	resp, err := route(hc, publicReq("GET", ""))
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+	// other bots only read
+	resp, err = route(hc, publicReq("PUT", "[]"))
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusForbidden, resp.StatusCode)
+	resp, err = route(hc, publicReq("DELETE", ""))
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusForbidden, resp.StatusCode)
+
+	hc.Publisher = "0xBotId"
+	s.EXPECT().PutObject(hc.Ctx, gomock.Any()).DoAndReturn(func(ctx context.Context, input *s3.PutObjectInput, _ ...func(*s3.Options)) (*s3.PutObjectOutput, error) {
+		assert.Equal(t, "public/0xbotid/scam-addresses.json", *input.Key)
+		return &s3.PutObjectOutput{}, nil
+	})
+	resp, err = route(hc, publicReq("PUT", "[]"))
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+	// the bot's own writes through /database/public/{key} land under the same prefix
+	own := *hc
+	own.Publisher = ""
+	s.EXPECT().PutObject(hc.Ctx, gomock.Any()).DoAndReturn(func(ctx context.Context, input *s3.PutObjectInput, _ ...func(*s3.Options)) (*s3.PutObjectOutput, error) {
+		assert.Equal(t, "public/0xbotid/scam-addresses.json", *input.Key)
+		return &s3.PutObjectOutput{}, nil
+	})
+	resp, err = putObj(&own, events.APIGatewayV2HTTPRequest{Body: "[]"})
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+}
+
+func TestPublicReservedKeys(t *testing.T) {
+	hc := &auth.HandlerCtx{
+		Ctx:       context.Background(),
+		BotID:     "0xbotId",
+		Scanner:   "0xscanner",
+		Scope:     auth.ScopePublic,
+		Publisher: "0xbotId",
+		Logger:    log.WithField("test", true),
+	}
+	for _, key := range []string{"copy", "move"} {
+		hc.PathKey = key
+		resp, err := route(hc, publicReq("GET", ""))
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+
+		own := *hc
+		own.Publisher = ""
+		resp, err = putObj(&own, events.APIGatewayV2HTTPRequest{Body: "[]"})
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	}
+}
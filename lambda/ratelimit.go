@@ -0,0 +1,227 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+
+	"forta-bot-db/api"
+	"forta-bot-db/auth"
+)
+
+const rateLimitPath = "/ratelimit/{scope}/{key}/take"
+
+// rateLimitPartitionPrefix keeps token buckets apart from other items in the same table
+const rateLimitPartitionPrefix = "ratelimit#"
+
+// maxTakeAttempts bounds retries when concurrent takes update the bucket between its read and write
+const maxTakeAttempts = 10
+
+const maxBurst = 1_000_000
+
+// minRate keeps the longest wait, maxBurst tokens at minRate, well within a time.Duration
+const minRate = 0.001
+
+// bucketItem is a token bucket as of UpdatedAt (millis); Version changes on every write, so a take only
+// succeeds if nobody took tokens since the bucket was read.  The bucket expires once it is full again,
+// since a missing bucket counts as full.  Rate and Burst are the limit the bucket was created with, which every
+// take must ask for, so one caller cannot loosen the limit for everyone else.
+type bucketItem struct {
+	Tokens    float64 `dynamodbav:"tokens"`
+	UpdatedAt int64   `dynamodbav:"updatedAt"`
+	Version   int64   `dynamodbav:"version"`
+	Rate      float64 `dynamodbav:"rate"`
+	Burst     int     `dynamodbav:"burst"`
+	// TTL is in seconds, as DynamoDB's time to live expects
+	TTL int64 `dynamodbav:"ttl"`
+}
+
+type takeResponse struct {
+	Remaining float64 `json:"remaining"`
+}
+
+// bucketLimit is the refill rate in tokens per second and the bucket's capacity
+type bucketLimit struct {
+	rate  float64
+	burst int
+}
+
+// refill returns the tokens in the bucket at t
+func (b *bucketItem) refill(t time.Time, limit bucketLimit) float64 {
+	elapsed := float64(t.UnixMilli()-b.UpdatedAt) / 1000
+	if elapsed < 0 {
+		// another lambda's clock is ahead
+		elapsed = 0
+	}
+	return math.Min(float64(limit.burst), b.Tokens+elapsed*limit.rate)
+}
+
+// fullAt returns when a bucket holding tokens at t is full again
+func fullAt(t time.Time, tokens float64, limit bucketLimit) time.Time {
+	return t.Add(time.Duration(math.Ceil((float64(limit.burst) - tokens) / limit.rate * float64(time.Second))))
+}
+
+// takeParams parses ?n= (default 1), ?rate= and ?burst= (default the rate, rounded up)
+func takeParams(r events.APIGatewayV2HTTPRequest) (int, bucketLimit, error) {
+	rate, err := strconv.ParseFloat(r.QueryStringParameters["rate"], 64)
+	if err != nil || !(rate >= minRate) || math.IsInf(rate, 0) {
+		return 0, bucketLimit{}, fmt.Errorf("rate must be at least %g tokens per second", minRate)
+	}
+	limit := bucketLimit{rate: rate, burst: int(math.Min(math.Ceil(rate), maxBurst))}
+	if v, ok := r.QueryStringParameters["burst"]; ok {
+		burst, err := strconv.Atoi(v)
+		if err != nil || burst < 1 || burst > maxBurst {
+			return 0, bucketLimit{}, fmt.Errorf("burst must be between 1 and %d", maxBurst)
+		}
+		limit.burst = burst
+	}
+	n := 1
+	if v, ok := r.QueryStringParameters["n"]; ok {
+		n, err = strconv.Atoi(v)
+		if err != nil || n < 1 || n > limit.burst {
+			return 0, bucketLimit{}, errors.New("n must be between 1 and burst")
+		}
+	}
+	return n, limit, nil
+}
+
+func bucketKey(hc *auth.HandlerCtx) (map[string]types.AttributeValue, error) {
+	prefix, err := hc.GetScopePrefix()
+	if err != nil {
+		return nil, err
+	}
+	return map[string]types.AttributeValue{
+		"pk": &types.AttributeValueMemberS{Value: rateLimitPartitionPrefix + prefix},
+		"sk": &types.AttributeValueMemberS{Value: hc.PathKey},
+	}, nil
+}
+
+// errTakeContention means concurrent takes kept updating the bucket between reading and writing it
+var errTakeContention = errors.New("too many concurrent takes, retry")
+
+// errLimitMismatch means a take asked for another limit than the one the bucket was created with
+var errLimitMismatch = errors.New("the bucket has a different limit")
+
+// takeTokens removes n tokens from the bucket if it holds them, returning what remains.  Otherwise nothing is written
+// and it returns how long until the bucket will hold n tokens.  A bucket that does not exist yet is full.
+func takeTokens(hc *auth.HandlerCtx, key map[string]types.AttributeValue, n int, limit bucketLimit) (float64, time.Duration, error) {
+	for attempt := 0; attempt < maxTakeAttempts; attempt++ {
+		out, err := hc.DB.GetItem(hc.Ctx, &dynamodb.GetItemInput{
+			TableName:      &kvTable,
+			Key:            key,
+			ConsistentRead: aws.Bool(true),
+		})
+		if err != nil {
+			return 0, 0, err
+		}
+		t := now()
+		tokens := float64(limit.burst)
+		condition := "attribute_not_exists(#pk)"
+		names := map[string]string{
+			"#pk":      "pk",
+			"#tokens":  "tokens",
+			"#updated": "updatedAt",
+			"#version": "version",
+			"#ttl":     "ttl",
+			"#rate":    "rate",
+			"#burst":   "burst",
+		}
+		values := map[string]types.AttributeValue{
+			":now":   &types.AttributeValueMemberN{Value: ms(t)},
+			":one":   &types.AttributeValueMemberN{Value: "1"},
+			":rate":  &types.AttributeValueMemberN{Value: strconv.FormatFloat(limit.rate, 'f', -1, 64)},
+			":burst": &types.AttributeValueMemberN{Value: strconv.Itoa(limit.burst)},
+		}
+		if out.Item != nil {
+			var item bucketItem
+			if err := attributevalue.UnmarshalMap(out.Item, &item); err != nil {
+				return 0, 0, err
+			}
+			// buckets written before the limit was stored take it from this take
+			if item.Rate != 0 && (item.Rate != limit.rate || item.Burst != limit.burst) {
+				return 0, 0, fmt.Errorf("%w: rate %g, burst %d", errLimitMismatch, item.Rate, item.Burst)
+			}
+			tokens = item.refill(t, limit)
+			// expressions must use every name they are given
+			delete(names, "#pk")
+			condition = "#version = :version"
+			values[":version"] = &types.AttributeValueMemberN{Value: strconv.FormatInt(item.Version, 10)}
+		}
+		if tokens < float64(n) {
+			wait := time.Duration(math.Ceil((float64(n) - tokens) / limit.rate * 1000))
+			return tokens, wait * time.Millisecond, nil
+		}
+		tokens -= float64(n)
+		values[":tokens"] = &types.AttributeValueMemberN{Value: strconv.FormatFloat(tokens, 'f', -1, 64)}
+		// rounded up, so the bucket is never deleted before it is full
+		values[":ttl"] = &types.AttributeValueMemberN{Value: strconv.FormatInt(fullAt(t, tokens, limit).Unix()+1, 10)}
+		_, err = hc.DB.UpdateItem(hc.Ctx, &dynamodb.UpdateItemInput{
+			TableName:                 &kvTable,
+			Key:                       key,
+			UpdateExpression:          aws.String("SET #tokens = :tokens, #updated = :now, #ttl = :ttl, #rate = :rate, #burst = :burst ADD #version :one"),
+			ConditionExpression:       &condition,
+			ExpressionAttributeNames:  names,
+			ExpressionAttributeValues: values,
+		})
+		if isConditionFailed(err) {
+			// another take got in first, read the bucket again
+			continue
+		}
+		if err != nil {
+			return 0, 0, err
+		}
+		return tokens, 0, nil
+	}
+	return 0, 0, errTakeContention
+}
+
+// take returns 429 with the time to wait when the bucket does not hold ?n= tokens.
+// Callers sharing a bucket must pass the rate and burst it was created with, otherwise 412 is returned.
+func take(hc *auth.HandlerCtx, r events.APIGatewayV2HTTPRequest) (events.APIGatewayV2HTTPResponse, error) {
+	n, limit, err := takeParams(r)
+	if err != nil {
+		return api.BadRequest(err.Error()), nil
+	}
+	key, err := bucketKey(hc)
+	if err != nil {
+		return api.NotFound(), nil
+	}
+	remaining, wait, err := takeTokens(hc, key, n, limit)
+	if errors.Is(err, errTakeContention) {
+		return api.Conflict(err.Error()), nil
+	}
+	if errors.Is(err, errLimitMismatch) {
+		hc.Logger.WithError(err).Warn("take with another limit than the bucket's")
+		return api.PreconditionFailed(), nil
+	}
+	if err != nil {
+		hc.Logger.WithError(err).Error("could not take tokens")
+		return api.InternalError(), nil
+	}
+	if wait > 0 {
+		return api.TooManyRequests(wait), nil
+	}
+	return api.OKJSON(&takeResponse{Remaining: remaining}), nil
+}
+
+func rateLimitRoute(hc *auth.HandlerCtx, r events.APIGatewayV2HTTPRequest) (events.APIGatewayV2HTTPResponse, error) {
+	if len(hc.PathKey) > maxKVKeySize {
+		return api.BadRequest(fmt.Sprintf("key must not exceed %d bytes", maxKVKeySize)), nil
+	}
+	switch strings.ToLower(r.RequestContext.HTTP.Method) {
+	case "post":
+		return take(hc, r)
+	default:
+		hc.Logger.Warn("method not allowed")
+		return api.MethodNotAllowed(), nil
+	}
+}
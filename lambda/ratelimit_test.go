@@ -0,0 +1,144 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/golang/mock/gomock"
+	log "github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+
+	"forta-bot-db/api"
+	"forta-bot-db/auth"
+	m "forta-bot-db/store/mocks"
+)
+
+func bucketOut(tokens, updatedAt, version string) *dynamodb.GetItemOutput {
+	return &dynamodb.GetItemOutput{Item: map[string]types.AttributeValue{
+		"tokens":    &types.AttributeValueMemberN{Value: tokens},
+		"updatedAt": &types.AttributeValueMemberN{Value: updatedAt},
+		"version":   &types.AttributeValueMemberN{Value: version},
+	}}
+}
+
+func TestTake(t *testing.T) {
+	kvTable = "test-kv"
+	t0 := time.UnixMilli(1_700_000_000_000)
+	now = func() time.Time { return t0 }
+	defer func() { now = time.Now }()
+
+	ctrl := gomock.NewController(t)
+	d := m.NewMockDynamoDB(ctrl)
+	hc := &auth.HandlerCtx{
+		Ctx:     context.Background(),
+		BotID:   "0xbotId",
+		Owner:   "0xowner",
+		PathKey: "etherscan",
+		Scope:   auth.ScopeOwner,
+		Logger:  log.WithField("test", true),
+		DB:      d,
+	}
+	limit := map[string]string{"rate": "5", "burst": "10", "n": "2"}
+
+	// a new bucket starts full
+	d.EXPECT().GetItem(hc.Ctx, gomock.Any()).DoAndReturn(func(ctx context.Context, input *dynamodb.GetItemInput, _ ...func(*dynamodb.Options)) (*dynamodb.GetItemOutput, error) {
+		assert.Equal(t, "ratelimit#owner/0xowner/", input.Key["pk"].(*types.AttributeValueMemberS).Value)
+		assert.Equal(t, "etherscan", input.Key["sk"].(*types.AttributeValueMemberS).Value)
+		assert.True(t, *input.ConsistentRead)
+		return &dynamodb.GetItemOutput{}, nil
+	})
+	d.EXPECT().UpdateItem(hc.Ctx, gomock.Any()).DoAndReturn(func(ctx context.Context, input *dynamodb.UpdateItemInput, _ ...func(*dynamodb.Options)) (*dynamodb.UpdateItemOutput, error) {
+		assert.Equal(t, "attribute_not_exists(#pk)", *input.ConditionExpression)
+		assert.Equal(t, "8", input.ExpressionAttributeValues[":tokens"].(*types.AttributeValueMemberN).Value)
+		assert.Equal(t, "1700000000000", input.ExpressionAttributeValues[":now"].(*types.AttributeValueMemberN).Value)
+		// 2 tokens refill in 0.4s, after which the bucket can expire
+		assert.Equal(t, "1700000001", input.ExpressionAttributeValues[":ttl"].(*types.AttributeValueMemberN).Value)
+		assert.Equal(t, "ttl", input.ExpressionAttributeNames["#ttl"])
+		return &dynamodb.UpdateItemOutput{}, nil
+	})
+	resp, err := route(hc, apiReq("POST", rateLimitPath, "", limit))
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	var taken takeResponse
+	assert.NoError(t, json.Unmarshal([]byte(resp.Body), &taken))
+	assert.Equal(t, 8.0, taken.Remaining)
+
+	// buckets refill between takes; a concurrent take makes the handler read the bucket again
+	d.EXPECT().GetItem(hc.Ctx, gomock.Any()).Return(bucketOut("2", "1699999999900", "4"), nil)
+	d.EXPECT().UpdateItem(hc.Ctx, gomock.Any()).Return(nil, &types.ConditionalCheckFailedException{})
+	d.EXPECT().GetItem(hc.Ctx, gomock.Any()).Return(bucketOut("3", "1699999999900", "5"), nil)
+	d.EXPECT().UpdateItem(hc.Ctx, gomock.Any()).DoAndReturn(func(ctx context.Context, input *dynamodb.UpdateItemInput, _ ...func(*dynamodb.Options)) (*dynamodb.UpdateItemOutput, error) {
+		assert.Equal(t, "#version = :version", *input.ConditionExpression)
+		assert.Equal(t, "5", input.ExpressionAttributeValues[":version"].(*types.AttributeValueMemberN).Value)
+		assert.Equal(t, "1.5", input.ExpressionAttributeValues[":tokens"].(*types.AttributeValueMemberN).Value)
+		assert.NotContains(t, input.ExpressionAttributeNames, "#pk")
+		return &dynamodb.UpdateItemOutput{}, nil
+	})
+	resp, err = route(hc, apiReq("POST", rateLimitPath, "", limit))
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+	// not enough tokens: nothing is written, and the wait is until 2 tokens have refilled
+	d.EXPECT().GetItem(hc.Ctx, gomock.Any()).Return(bucketOut("0.5", "1700000000000", "6"), nil)
+	resp, err = route(hc, apiReq("POST", rateLimitPath, "", limit))
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusTooManyRequests, resp.StatusCode)
+	assert.Equal(t, "1", resp.Headers["Retry-After"])
+	var limited api.RetryResponse
+	assert.NoError(t, json.Unmarshal([]byte(resp.Body), &limited))
+	assert.Equal(t, int64(300), limited.RetryAfter)
+
+	// the bucket keeps the limit it was created with, so a caller cannot loosen it for everyone
+	created := bucketOut("10", "1700000000000", "7")
+	created.Item["rate"] = &types.AttributeValueMemberN{Value: "5"}
+	created.Item["burst"] = &types.AttributeValueMemberN{Value: "10"}
+	d.EXPECT().GetItem(hc.Ctx, gomock.Any()).Return(created, nil)
+	resp, err = route(hc, apiReq("POST", rateLimitPath, "", map[string]string{"rate": "500", "burst": "10"}))
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusPreconditionFailed, resp.StatusCode)
+
+	d.EXPECT().GetItem(hc.Ctx, gomock.Any()).Return(created, nil)
+	d.EXPECT().UpdateItem(hc.Ctx, gomock.Any()).DoAndReturn(func(ctx context.Context, input *dynamodb.UpdateItemInput, _ ...func(*dynamodb.Options)) (*dynamodb.UpdateItemOutput, error) {
+		assert.Equal(t, "5", input.ExpressionAttributeValues[":rate"].(*types.AttributeValueMemberN).Value)
+		assert.Equal(t, "10", input.ExpressionAttributeValues[":burst"].(*types.AttributeValueMemberN).Value)
+		return &dynamodb.UpdateItemOutput{}, nil
+	})
+	resp, err = route(hc, apiReq("POST", rateLimitPath, "", limit))
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+	// contention
+	d.EXPECT().GetItem(hc.Ctx, gomock.Any()).Return(bucketOut("10", "1700000000000", "7"), nil).Times(maxTakeAttempts)
+	d.EXPECT().UpdateItem(hc.Ctx, gomock.Any()).Return(nil, &types.ConditionalCheckFailedException{}).Times(maxTakeAttempts)
+	resp, err = route(hc, apiReq("POST", rateLimitPath, "", limit))
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusConflict, resp.StatusCode)
+}
+
+func TestTakeParams(t *testing.T) {
+	for _, q := range []map[string]string{
+		{},
+		{"rate": "0"},
+		{"rate": "NaN"},
+		{"rate": "0.0001"},
+		{"rate": "1", "burst": "0"},
+		{"rate": "1", "burst": "5", "n": "6"},
+	} {
+		_, _, err := takeParams(apiReq("POST", rateLimitPath, "", q))
+		assert.Error(t, err, q)
+	}
+	n, limit, err := takeParams(apiReq("POST", rateLimitPath, "", map[string]string{"rate": "0.5"}))
+	assert.NoError(t, err)
+	assert.Equal(t, 1, n)
+	assert.Equal(t, bucketLimit{rate: 0.5, burst: 1}, limit)
+
+	// the slowest, largest bucket still refills within a time.Duration
+	t0 := time.UnixMilli(1_700_000_000_000)
+	slowest := bucketLimit{rate: minRate, burst: maxBurst}
+	assert.True(t, fullAt(t0, 0, slowest).After(t0))
+}
@@ -0,0 +1,19 @@
+package main
+
+import (
+	"strings"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+// apiReq returns a request as API Gateway sends it for the route method path
+func apiReq(method, path, body string, query map[string]string) events.APIGatewayV2HTTPRequest {
+	return events.APIGatewayV2HTTPRequest{
+		RouteKey: strings.ToUpper(method) + " " + path,
+		RequestContext: events.APIGatewayV2HTTPRequestContext{
+			HTTP: events.APIGatewayV2HTTPRequestContextHTTPDescription{Method: method},
+		},
+		QueryStringParameters: query,
+		Body:                  body,
+	}
+}
@@ -0,0 +1,243 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+
+	"forta-bot-db/api"
+	"forta-bot-db/auth"
+)
+
+const streamPath = "/streams/{scope}/{key}"
+
+// streamPartitionPrefix keeps stream records apart from other items; each stream is one partition
+const streamPartitionPrefix = "stream#"
+
+// maxAppendAttempts bounds retries when concurrent appends race for the same sequence number
+const maxAppendAttempts = 10
+
+const defaultReadLimit = 100
+const maxReadLimit = 1000
+
+type streamRecord struct {
+	PK    string `dynamodbav:"pk"`
+	SK    string `dynamodbav:"sk"`
+	Seq   int64  `dynamodbav:"seq"`
+	Value []byte `dynamodbav:"value"`
+	Time  int64  `dynamodbav:"time"`
+	// TTL is in seconds, as DynamoDB's time to live expects; records of the stream API never expire
+	TTL int64 `dynamodbav:"ttl,omitempty"`
+}
+
+type appendResponse struct {
+	Seq int64 `json:"seq"`
+}
+
+type streamRecordResponse struct {
+	Seq   int64  `json:"seq"`
+	Value []byte `json:"value"`
+	Time  int64  `json:"time"`
+}
+
+type readResponse struct {
+	Records []streamRecordResponse `json:"records"`
+	Next    int64                  `json:"next"`
+}
+
+func streamPartition(hc *auth.HandlerCtx) (string, error) {
+	prefix, err := hc.GetScopePrefix()
+	if err != nil {
+		return "", err
+	}
+	return streamPartitionPrefix + prefix + hc.PathKey, nil
+}
+
+// seqKey zero-pads sequence numbers so the sort key orders them numerically
+func seqKey(seq int64) string {
+	return fmt.Sprintf("%020d", seq)
+}
+
+// lastSeq returns the sequence number of the newest record, or 0 for an empty stream
+func lastSeq(hc *auth.HandlerCtx, pk string) (int64, error) {
+	out, err := hc.DB.Query(hc.Ctx, &dynamodb.QueryInput{
+		TableName:              &kvTable,
+		KeyConditionExpression: aws.String("pk = :pk"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":pk": &types.AttributeValueMemberS{Value: pk},
+		},
+		ScanIndexForward: aws.Bool(false),
+		Limit:            aws.Int32(1),
+		ConsistentRead:   aws.Bool(true),
+	})
+	if err != nil || len(out.Items) == 0 {
+		return 0, err
+	}
+	var rec streamRecord
+	if err := attributevalue.UnmarshalMap(out.Items[0], &rec); err != nil {
+		return 0, err
+	}
+	return rec.Seq, nil
+}
+
+// errStreamContention means concurrent appends kept taking the sequence number an append tried to write
+var errStreamContention = errors.New("too many concurrent appends, retry")
+
+// appendToStream writes the record after the newest one in partition pk.  The write only succeeds if that sequence
+// number is free, so records are numbered without gaps and a record never becomes visible before the one preceding it.
+// A non-zero retention lets DynamoDB expire the record once it has passed.
+func appendToStream(hc *auth.HandlerCtx, pk string, b []byte, retention time.Duration) (int64, error) {
+	seq, err := lastSeq(hc, pk)
+	if err != nil {
+		return 0, err
+	}
+	for attempt := 0; attempt < maxAppendAttempts; attempt++ {
+		seq++
+		t := now()
+		rec := &streamRecord{
+			PK:    pk,
+			SK:    seqKey(seq),
+			Seq:   seq,
+			Value: b,
+			Time:  t.UnixMilli(),
+		}
+		if retention > 0 {
+			rec.TTL = t.Add(retention).Unix()
+		}
+		item, err := attributevalue.MarshalMap(rec)
+		if err != nil {
+			return 0, err
+		}
+		_, err = hc.DB.PutItem(hc.Ctx, &dynamodb.PutItemInput{
+			TableName:           &kvTable,
+			Item:                item,
+			ConditionExpression: aws.String("attribute_not_exists(pk)"),
+		})
+		if isConditionFailed(err) {
+			// another append took this number, try the next one
+			continue
+		}
+		if err != nil {
+			return 0, err
+		}
+		return seq, nil
+	}
+	return 0, errStreamContention
+}
+
+// readRange parses ?from= (inclusive, starting at 1) and ?limit=
+func readRange(r events.APIGatewayV2HTTPRequest) (int64, int, error) {
+	from := int64(1)
+	if v, ok := r.QueryStringParameters["from"]; ok {
+		n, err := strconv.ParseInt(v, 10, 64)
+		if err != nil || n < 0 {
+			return 0, 0, errors.New("from must be a sequence number")
+		}
+		if n > 1 {
+			from = n
+		}
+	}
+	limit := defaultReadLimit
+	if v, ok := r.QueryStringParameters["limit"]; ok {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > maxReadLimit {
+			return 0, 0, fmt.Errorf("limit must be between 1 and %d", maxReadLimit)
+		}
+		limit = n
+	}
+	return from, limit, nil
+}
+
+// queryStream returns up to limit records of partition pk from sequence number from onwards.
+// It reads a single page, which keeps the response under the lambda payload limit; callers continue after the last record.
+func queryStream(hc *auth.HandlerCtx, pk string, from int64, limit int) ([]streamRecord, error) {
+	out, err := hc.DB.Query(hc.Ctx, &dynamodb.QueryInput{
+		TableName:              &kvTable,
+		KeyConditionExpression: aws.String("pk = :pk AND sk >= :from"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":pk":   &types.AttributeValueMemberS{Value: pk},
+			":from": &types.AttributeValueMemberS{Value: seqKey(from)},
+		},
+		Limit:          aws.Int32(int32(limit)),
+		ConsistentRead: aws.Bool(true),
+	})
+	if err != nil {
+		return nil, err
+	}
+	var recs []streamRecord
+	if err := attributevalue.UnmarshalListOfMaps(out.Items, &recs); err != nil {
+		return nil, err
+	}
+	return recs, nil
+}
+
+func appendRecord(hc *auth.HandlerCtx, r events.APIGatewayV2HTTPRequest) (events.APIGatewayV2HTTPResponse, error) {
+	b, err := requestBody(r)
+	if err != nil {
+		hc.Logger.WithError(err).Error("could not decode body")
+		return api.InternalError(), nil
+	}
+	if len(b) > maxKVValueSize {
+		return api.BadRequest(fmt.Sprintf("record must not exceed %d bytes", maxKVValueSize)), nil
+	}
+	pk, err := streamPartition(hc)
+	if err != nil {
+		return api.NotFound(), nil
+	}
+	seq, err := appendToStream(hc, pk, b, 0)
+	if errors.Is(err, errStreamContention) {
+		return api.Conflict(err.Error()), nil
+	}
+	if err != nil {
+		hc.Logger.WithError(err).Error("could not append record")
+		return api.InternalError(), nil
+	}
+	return api.OKJSON(&appendResponse{Seq: seq}), nil
+}
+
+// readRecords returns records from ?from= onwards, and the sequence number to continue from
+func readRecords(hc *auth.HandlerCtx, r events.APIGatewayV2HTTPRequest) (events.APIGatewayV2HTTPResponse, error) {
+	from, limit, err := readRange(r)
+	if err != nil {
+		return api.BadRequest(err.Error()), nil
+	}
+	pk, err := streamPartition(hc)
+	if err != nil {
+		return api.NotFound(), nil
+	}
+	recs, err := queryStream(hc, pk, from, limit)
+	if err != nil {
+		hc.Logger.WithError(err).Error("could not read stream")
+		return api.InternalError(), nil
+	}
+
+	res := &readResponse{Records: []streamRecordResponse{}, Next: from}
+	for _, rec := range recs {
+		res.Records = append(res.Records, streamRecordResponse{Seq: rec.Seq, Value: rec.Value, Time: rec.Time})
+		res.Next = rec.Seq + 1
+	}
+	return api.OKJSON(res), nil
+}
+
+func streamRoute(hc *auth.HandlerCtx, r events.APIGatewayV2HTTPRequest) (events.APIGatewayV2HTTPResponse, error) {
+	if len(hc.PathKey) > maxKVKeySize {
+		return api.BadRequest(fmt.Sprintf("key must not exceed %d bytes", maxKVKeySize)), nil
+	}
+	switch strings.ToLower(r.RequestContext.HTTP.Method) {
+	case "get":
+		return readRecords(hc, r)
+	case "post":
+		return appendRecord(hc, r)
+	default:
+		hc.Logger.Warn("method not allowed")
+		return api.MethodNotAllowed(), nil
+	}
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/golang/mock/gomock"
+	log "github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+
+	"forta-bot-db/auth"
+	m "forta-bot-db/store/mocks"
+)
+
+func TestAppendRecord(t *testing.T) {
+	kvTable = "test-kv"
+	ctrl := gomock.NewController(t)
+	d := m.NewMockDynamoDB(ctrl)
+
+	hc := &auth.HandlerCtx{
+		Ctx:     context.Background(),
+		BotID:   "0xbotId",
+		Scanner: "0xscanner",
+		PathKey: "suspects",
+		Scope:   auth.ScopeBot,
+		Logger:  log.WithField("test", true),
+		DB:      d,
+	}
+
+	d.EXPECT().Query(hc.Ctx, gomock.Any()).DoAndReturn(func(ctx context.Context, input *dynamodb.QueryInput, _ ...func(*dynamodb.Options)) (*dynamodb.QueryOutput, error) {
+		assert.Equal(t, "stream#0xbotId/suspects", input.ExpressionAttributeValues[":pk"].(*types.AttributeValueMemberS).Value)
+		assert.False(t, *input.ScanIndexForward)
+		return &dynamodb.QueryOutput{Items: []map[string]types.AttributeValue{
+			{"seq": &types.AttributeValueMemberN{Value: "41"}},
+		}}, nil
+	})
+	// a concurrent append took 42, so this one lands on 43
+	d.EXPECT().PutItem(hc.Ctx, gomock.Any()).DoAndReturn(func(ctx context.Context, input *dynamodb.PutItemInput, _ ...func(*dynamodb.Options)) (*dynamodb.PutItemOutput, error) {
+		assert.Equal(t, seqKey(42), input.Item["sk"].(*types.AttributeValueMemberS).Value)
+		return nil, &types.ConditionalCheckFailedException{}
+	})
+	d.EXPECT().PutItem(hc.Ctx, gomock.Any()).DoAndReturn(func(ctx context.Context, input *dynamodb.PutItemInput, _ ...func(*dynamodb.Options)) (*dynamodb.PutItemOutput, error) {
+		assert.Equal(t, seqKey(43), input.Item["sk"].(*types.AttributeValueMemberS).Value)
+		assert.Equal(t, []byte("0xabc"), input.Item["value"].(*types.AttributeValueMemberB).Value)
+		assert.NotContains(t, input.Item, "ttl")
+		return &dynamodb.PutItemOutput{}, nil
+	})
+	resp, err := route(hc, apiReq("POST", streamPath, "0xabc", nil))
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.JSONEq(t, `{"seq":43}`, resp.Body)
+
+	resp, err = route(hc, apiReq("POST", streamPath, strings.Repeat("x", maxKVValueSize+1), nil))
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+}
+
+func TestReadRecords(t *testing.T) {
+	kvTable = "test-kv"
+	ctrl := gomock.NewController(t)
+	d := m.NewMockDynamoDB(ctrl)
+
+	hc := &auth.HandlerCtx{
+		Ctx:     context.Background(),
+		BotID:   "0xbotId",
+		Scanner: "0xscanner",
+		PathKey: "suspects",
+		Scope:   auth.ScopeBot,
+		Logger:  log.WithField("test", true),
+		DB:      d,
+	}
+
+	d.EXPECT().Query(hc.Ctx, gomock.Any()).DoAndReturn(func(ctx context.Context, input *dynamodb.QueryInput, _ ...func(*dynamodb.Options)) (*dynamodb.QueryOutput, error) {
+		assert.Equal(t, seqKey(7), input.ExpressionAttributeValues[":from"].(*types.AttributeValueMemberS).Value)
+		assert.Equal(t, int32(2), *input.Limit)
+		assert.True(t, *input.ConsistentRead)
+		return &dynamodb.QueryOutput{Items: []map[string]types.AttributeValue{
+			{"seq": &types.AttributeValueMemberN{Value: "7"}, "value": &types.AttributeValueMemberB{Value: []byte("a")}},
+			{"seq": &types.AttributeValueMemberN{Value: "8"}, "value": &types.AttributeValueMemberB{Value: []byte("b")}},
+		}}, nil
+	})
+	resp, err := route(hc, apiReq("GET", streamPath, "", map[string]string{"from": "7", "limit": "2"}))
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	var res readResponse
+	assert.NoError(t, json.Unmarshal([]byte(resp.Body), &res))
+	assert.Len(t, res.Records, 2)
+	assert.Equal(t, []byte("b"), res.Records[1].Value)
+	assert.Equal(t, int64(9), res.Next)
+
+	// reading past the end leaves the cursor where it is
+	d.EXPECT().Query(hc.Ctx, gomock.Any()).Return(&dynamodb.QueryOutput{}, nil)
+	resp, err = route(hc, apiReq("GET", streamPath, "", map[string]string{"from": "9"}))
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"records":[],"next":9}`, resp.Body)
+
+	resp, err = route(hc, apiReq("GET", streamPath, "", map[string]string{"limit": "5000"}))
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+}
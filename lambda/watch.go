@@ -0,0 +1,51 @@
+package main
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+
+	"forta-bot-db/auth"
+)
+
+// maxWatchWait bounds how long a watch holds the request, within the handler's timeout
+const maxWatchWait = 8 * time.Second
+
+// watchMargin is left of the handler's deadline to respond after the last check
+const watchMargin = time.Second
+
+// watchInterval is how often a watch checks the object
+var watchInterval = 500 * time.Millisecond
+
+// watchObj is getObj that waits until the object differs from ?since= (an etag), or until it exists if since is empty.
+// It returns the changed object, a 404 if it was deleted, or a 304 if nothing changed before the wait ran out.
+func watchObj(hc *auth.HandlerCtx, r events.APIGatewayV2HTTPRequest) (events.APIGatewayV2HTTPResponse, error) {
+	since := r.QueryStringParameters["since"]
+	if since == "" {
+		since = r.Headers["if-none-match"]
+	}
+	req := r
+	req.Headers = map[string]string{}
+	for k, v := range r.Headers {
+		req.Headers[k] = v
+	}
+	req.Headers["if-none-match"] = since
+
+	deadline := now().Add(maxWatchWait)
+	if d, ok := hc.Ctx.Deadline(); ok && d.Add(-watchMargin).Before(deadline) {
+		deadline = d.Add(-watchMargin)
+	}
+	for {
+		resp, err := getObj(hc, req)
+		unchanged := resp.StatusCode == http.StatusNotModified || (since == "" && resp.StatusCode == http.StatusNotFound)
+		if err != nil || !unchanged || !now().Add(watchInterval).Before(deadline) {
+			return resp, err
+		}
+		select {
+		case <-hc.Ctx.Done():
+			return resp, nil
+		case <-time.After(watchInterval):
+		}
+	}
+}
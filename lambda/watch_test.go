@@ -0,0 +1,78 @@
+package main
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	awshttp "github.com/aws/aws-sdk-go-v2/aws/transport/http"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	smithyhttp "github.com/aws/smithy-go/transport/http"
+	"github.com/golang/mock/gomock"
+	log "github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+
+	"forta-bot-db/auth"
+	m "forta-bot-db/store/mocks"
+)
+
+func watchReq(since string) events.APIGatewayV2HTTPRequest {
+	return events.APIGatewayV2HTTPRequest{
+		RequestContext: events.APIGatewayV2HTTPRequestContext{
+			HTTP: events.APIGatewayV2HTTPRequestContextHTTPDescription{Method: "GET"},
+		},
+		QueryStringParameters: map[string]string{"watch": "true", "since": since},
+	}
+}
+
+func TestWatchObj(t *testing.T) {
+	bucket = "test-bucket"
+	watchInterval = time.Millisecond
+	ctrl := gomock.NewController(t)
+	s := m.NewMockS3(ctrl)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 1200*time.Millisecond)
+	defer cancel()
+	hc := &auth.HandlerCtx{
+		Ctx:     ctx,
+		BotID:   "0xbotId",
+		Scanner: "0xscanner",
+		PathKey: "config.json",
+		Scope:   auth.ScopeOwner,
+		Logger:  log.WithField("test", true),
+		Store:   s,
+	}
+
+	notModified := &awshttp.ResponseError{
+		ResponseError: &smithyhttp.ResponseError{
+			Response: &smithyhttp.Response{Response: &http.Response{StatusCode: http.StatusNotModified}},
+		},
+	}
+	etag := `"def"`
+	gomock.InOrder(
+		s.EXPECT().GetObject(ctx, gomock.Any()).DoAndReturn(func(ctx context.Context, input *s3.GetObjectInput, _ ...func(*s3.Options)) (*s3.GetObjectOutput, error) {
+			assert.Equal(t, `"abc"`, *input.IfNoneMatch)
+			return nil, notModified
+		}).Times(2),
+		s.EXPECT().GetObject(ctx, gomock.Any()).Return(&s3.GetObjectOutput{
+			Body: io.NopCloser(strings.NewReader("{}")),
+			ETag: &etag,
+		}, nil),
+	)
+	resp, err := route(hc, watchReq(`"abc"`))
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, etag, resp.Headers["ETag"])
+
+	// nothing changes before the deadline, less the margin to respond
+	s.EXPECT().GetObject(ctx, gomock.Any()).Return(nil, notModified).MinTimes(1)
+	start := time.Now()
+	resp, err = route(hc, watchReq(etag))
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusNotModified, resp.StatusCode)
+	assert.Less(t, time.Since(start), time.Second)
+}